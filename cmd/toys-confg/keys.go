@@ -0,0 +1,399 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/kidstuff/toys/confg"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+func runGet(fs *flag.FlagSet, args []string) error {
+	format := fs.String("format", "", "name of the Configurator")
+	decrypt := fs.Bool("decrypt", false, "decrypt the value")
+	loadKey := keyFlags(fs, "", confg.KeyEnv)
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	c, err := open(*format, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if *decrypt {
+		key, err := loadKey()
+		if err != nil {
+			return err
+		}
+		c = confg.NewSecretConfg(c, key)
+	}
+	v := confg.Lookup(c, fs.Arg(1))
+	if v == nil {
+		return fmt.Errorf("key %s not found", fs.Arg(1))
+	}
+	return printValue(v)
+}
+
+// printValue prints strings as they are and the other values in JSON.
+func printValue(v interface{}) error {
+	if s, ok := v.(string); ok {
+		fmt.Println(s)
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
+func runSet(fs *flag.FlagSet, args []string) error {
+	format := fs.String("format", "", "name of the Configurator")
+	typ := fs.String("type", "auto", "type of the value: auto, string, int, float, bool or json")
+	encrypt := fs.Bool("encrypt", false, "encrypt the value")
+	loadKey := keyFlags(fs, "", confg.KeyEnv)
+	fs.Parse(args)
+	if fs.NArg() != 3 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	v, err := parseValue(*typ, fs.Arg(2))
+	if err != nil {
+		return err
+	}
+	if *encrypt {
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("only string values can be encrypted")
+		}
+		key, err := loadKey()
+		if err != nil {
+			return err
+		}
+		if v, err = confg.Encrypt(key, s); err != nil {
+			return err
+		}
+	}
+
+	c, err := open(*format, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	c.Set(fs.Arg(1), v)
+	return save(c)
+}
+
+// parseValue converts the command line text s to a value of type typ. The
+// auto type reads s as JSON and falls back to a plain string.
+func parseValue(typ, s string) (interface{}, error) {
+	switch typ {
+	case "string":
+		return s, nil
+	case "int":
+		return strconv.ParseInt(s, 10, 64)
+	case "float":
+		return strconv.ParseFloat(s, 64)
+	case "bool":
+		return strconv.ParseBool(s)
+	case "json", "auto":
+		var v interface{}
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			if typ == "auto" {
+				return s, nil
+			}
+			return nil, err
+		}
+		return normalize(v), nil
+	}
+	return nil, fmt.Errorf("unknown type %s", typ)
+}
+
+func runDel(fs *flag.FlagSet, args []string) error {
+	format := fs.String("format", "", "name of the Configurator")
+	fs.Parse(args)
+	if fs.NArg() < 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	c, err := open(*format, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	for _, k := range fs.Args()[1:] {
+		c.Del(k)
+	}
+	return save(c)
+}
+
+func runList(fs *flag.FlagSet, args []string) error {
+	format := fs.String("format", "", "name of the Configurator")
+	values := fs.Bool("values", false, "print the values too")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	c, err := open(*format, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	keys, err := keysOf(c)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if !*values {
+			fmt.Println(k)
+			continue
+		}
+		b, err := json.Marshal(confg.Lookup(c, k))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s = %s\n", k, b)
+	}
+	return nil
+}
+
+func keysOf(c confg.Configurator) ([]string, error) {
+	keyer, ok := c.(confg.Keyer)
+	if !ok {
+		return nil, fmt.Errorf("the format cannot list its keys")
+	}
+	return keyer.Keys(), nil
+}
+
+func loadSchema(path string) (*confg.Schema, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return confg.LoadSchema(f)
+}
+
+func runValidate(fs *flag.FlagSet, args []string) error {
+	format := fs.String("format", "", "name of the Configurator")
+	schema := fs.String("schema", "", "schema file in JSON format")
+	fs.Parse(args)
+	if fs.NArg() != 1 || *schema == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	s, err := loadSchema(*schema)
+	if err != nil {
+		return err
+	}
+	c, err := open(*format, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	err = s.Validate(c)
+	if verr, ok := err.(*confg.ValidationError); ok {
+		for _, v := range verr.Violations {
+			fmt.Printf("%s: %s\n", v.Key, v.Msg)
+		}
+		return fmt.Errorf("%s: %d violation(s)", fs.Arg(0), len(verr.Violations))
+	}
+	return err
+}
+
+func runDoc(fs *flag.FlagSet, args []string) error {
+	schema := fs.String("schema", "", "schema file in JSON format")
+	fs.Parse(args)
+	if fs.NArg() != 0 || *schema == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	s, err := loadSchema(*schema)
+	if err != nil {
+		return err
+	}
+	return s.WriteDoc(os.Stdout)
+}
+
+func runConvert(fs *flag.FlagSet, args []string) error {
+	from := fs.String("from", "", "name of the Configurator of the input file")
+	to := fs.String("to", "", "name of the Configurator of the output file")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	src, err := open(*from, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	format := *to
+	if format == "" {
+		if format = guess(fs.Arg(1)); format == "" {
+			return fmt.Errorf("cannot guess the format of %s, use -to", fs.Arg(1))
+		}
+	}
+	dst, err := confg.New(format)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	saver, ok := dst.(confg.Saver)
+	if !ok {
+		return fmt.Errorf("the format %s cannot be saved", format)
+	}
+
+	keys, err := keysOf(src)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		dst.Set(k, normalize(confg.Lookup(src, k)))
+	}
+	return saver.SaveAs(fs.Arg(1))
+}
+
+func runDiff(fs *flag.FlagSet, args []string) error {
+	formatA := fs.String("format-a", "", "name of the Configurator of the first file")
+	formatB := fs.String("format-b", "", "name of the Configurator of the second file")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	a, err := readAll(*formatA, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := readAll(*formatB, fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	if !diff(os.Stdout, a, b) {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// readAll returns the normalized values of the file by keys.
+func readAll(format, path string) (map[string]interface{}, error) {
+	c, err := open(format, path)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	keys, err := keysOf(c)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]interface{}, len(keys))
+	for _, k := range keys {
+		m[k] = normalize(confg.Lookup(c, k))
+	}
+	return m, nil
+}
+
+// diff prints to w the keys only in a with "-", only in b with "+" and the
+// changed ones with "~". It reports whether a and b are equal.
+func diff(w io.Writer, a, b map[string]interface{}) bool {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	same := true
+	for _, k := range keys {
+		va, inA := a[k]
+		vb, inB := b[k]
+		switch {
+		case !inB:
+			fmt.Fprintf(w, "- %s = %s\n", k, jsonText(va))
+		case !inA:
+			fmt.Fprintf(w, "+ %s = %s\n", k, jsonText(vb))
+		case !reflect.DeepEqual(va, vb):
+			fmt.Fprintf(w, "~ %s = %s -> %s\n", k, jsonText(va), jsonText(vb))
+		default:
+			continue
+		}
+		same = false
+	}
+	return same
+}
+
+func jsonText(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// normalize converts the numbers to int64 when they are integral and to
+// float64 otherwise, so the values read from different formats compare and
+// encode the same way.
+func normalize(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, item := range x {
+			m[k] = normalize(item)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(x))
+		for i, item := range x {
+			a[i] = normalize(item)
+		}
+		return a
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return int64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+			return int64(f)
+		}
+		return f
+	}
+	return v
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func tempFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "toys-confg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestConvert(t *testing.T) {
+	dir := tempFiles(t, map[string]string{
+		"app.toml":     "name = \"toys\"\n\n[db]\nhost = \"localhost\"\nport = 5432\nhosts = [\"a\", \"b\"]\nratio = 0.5\n",
+		"servers.toml": "[[servers]]\nn = 1\n",
+	})
+	defer os.RemoveAll(dir)
+
+	for _, out := range []string{"app.json", "app.ini", "app.xml"} {
+		err := runConvert(flag.NewFlagSet("convert", flag.ContinueOnError),
+			[]string{filepath.Join(dir, "app.toml"), filepath.Join(dir, out)})
+		if err != nil {
+			t.Errorf("convert to %s: %v", out, err)
+			continue
+		}
+		a, err := readAll("", filepath.Join(dir, "app.toml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := readAll("", filepath.Join(dir, out))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if !diff(&buf, a, b) {
+			t.Errorf("convert to %s changed the values:\n%s", out, buf.String())
+		}
+	}
+
+	// INI cannot hold an array of tables
+	err := runConvert(flag.NewFlagSet("convert", flag.ContinueOnError),
+		[]string{filepath.Join(dir, "servers.toml"), filepath.Join(dir, "servers.ini")})
+	if err == nil {
+		t.Error("convert wrote an array of tables to INI")
+	}
+	if err = runConvert(flag.NewFlagSet("convert", flag.ContinueOnError),
+		[]string{filepath.Join(dir, "app.toml"), filepath.Join(dir, "app.yaml")}); err == nil {
+		t.Error("convert guessed the format of a .yaml file")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	dir := tempFiles(t, map[string]string{
+		"a.json": `{"name": "toys", "db": {"port": 5432, "host": "localhost"}, "old": true}`,
+		"b.toml": "name = \"toys\"\nnew = 1\n\n[db]\nport = 5432.0\nhost = \"db.example.com\"\n",
+	})
+	defer os.RemoveAll(dir)
+
+	a, err := readAll("", filepath.Join(dir, "a.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := readAll("", filepath.Join(dir, "b.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if diff(&buf, a, b) {
+		t.Error("diff reported equal files")
+	}
+	want := "~ db.host = \"localhost\" -> \"db.example.com\"\n" +
+		"+ new = 1\n" +
+		"- old = true\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if !diff(&buf, a, a) || buf.Len() != 0 {
+		t.Errorf("diff of a file with itself printed:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Command toys-confg manages configuration files of toys applications.
+
+Usage:
+
+	toys-confg command [flags] [arguments]
+
+The commands are:
+
+	get       print the value of a key
+	set       set the value of a key
+	del       delete keys
+	list      list the keys
+	validate  check a file against a schema
+	doc       print the reference documentation of a schema
+	convert   convert a file to another format
+	diff      print the differences between two files
+	keygen    print a new random secret key
+	encrypt   encrypt the values of some keys in place
+	rotate    re-encrypt all encrypted values with a new key
+
+The format of a file is guessed from its extension (.json, .xml, .ini, .toml),
+use -format with the name of a registered Configurator to set it. Numbers are
+compared and converted by value, so 8080 in a JSON file equals 8080 in a TOML
+file. diff exits with status 1 if the files differ. The secret key
+is read from the TOYS_CONFG_KEY environment variable or the file given by
+-key-file, it must be base64 encoded as printed by keygen.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/kidstuff/toys/confg"
+	_ "github.com/kidstuff/toys/confg/iniconfg"
+	_ "github.com/kidstuff/toys/confg/jsonconfg"
+	_ "github.com/kidstuff/toys/confg/tomlconfg"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type command struct {
+	args  string
+	short string
+	run   func(fs *flag.FlagSet, args []string) error
+}
+
+var commands = map[string]*command{
+	"get":      {"file key", "print the value of a key", runGet},
+	"set":      {"file key value", "set the value of a key", runSet},
+	"del":      {"file key...", "delete keys", runDel},
+	"list":     {"file", "list the keys", runList},
+	"validate": {"file", "check a file against a schema", runValidate},
+	"doc":      {"", "print the reference documentation of a schema", runDoc},
+	"convert":  {"in out", "convert a file to another format", runConvert},
+	"diff":     {"a b", "print the differences between two files", runDiff},
+	"keygen":   {"", "print a new random secret key", runKeygen},
+	"encrypt":  {"file key...", "encrypt the values of some keys in place", runEncrypt},
+	"rotate":   {"file", "re-encrypt all encrypted values with a new key", runRotate},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: toys-confg command [flags] [arguments]\n\ncommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].short)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	name := os.Args[1]
+	cmd, ok := commands[name]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: toys-confg %s [flags] %s\n", name, cmd.args)
+		fs.PrintDefaults()
+	}
+	if err := cmd.run(fs, os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "toys-confg:", err)
+		os.Exit(1)
+	}
+}
+
+var formats = map[string]string{
+	".json": "jsonconfg",
+	".xml":  "xmlconfg",
+	".ini":  "iniconfg",
+	".toml": "tomlconfg",
+}
+
+// guess returns the name of the Configurator for the file extension of path.
+func guess(path string) string {
+	return formats[strings.ToLower(filepath.Ext(path))]
+}
+
+// open opens the file with the Configurator named format, or guessed from
+// the file extension if format is empty.
+func open(format, path string) (confg.Configurator, error) {
+	if format == "" {
+		if format = guess(path); format == "" {
+			return nil, fmt.Errorf("cannot guess the format of %s, use -format", path)
+		}
+	}
+	return confg.Open(format, path)
+}
+
+func save(c confg.Configurator) error {
+	saver, ok := c.(confg.Saver)
+	if !ok {
+		return fmt.Errorf("the format cannot be saved")
+	}
+	return saver.Save()
+}
+
+// keyFlags adds the flags to read a secret key, prefix is used for the
+// names of the flags.
+func keyFlags(fs *flag.FlagSet, prefix, env string) func() ([]byte, error) {
+	envName := fs.String(prefix+"key-env", env, "environment variable holding the "+prefix+"key")
+	file := fs.String(prefix+"key-file", "", "file holding the "+prefix+"key")
+	return func() ([]byte, error) {
+		return confg.LoadKey(*envName, *file)
+	}
+}
+
+func runKeygen(fs *flag.FlagSet, args []string) error {
+	fs.Parse(args)
+	fmt.Println(confg.NewKey())
+	return nil
+}
+
+func runEncrypt(fs *flag.FlagSet, args []string) error {
+	format := fs.String("format", "", "name of the Configurator")
+	loadKey := keyFlags(fs, "", confg.KeyEnv)
+	fs.Parse(args)
+	if fs.NArg() < 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	key, err := loadKey()
+	if err != nil {
+		return err
+	}
+	c, err := open(*format, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if err = confg.EncryptKeys(c, key, fs.Args()[1:]...); err != nil {
+		return err
+	}
+	return save(c)
+}
+
+func runRotate(fs *flag.FlagSet, args []string) error {
+	format := fs.String("format", "", "name of the Configurator")
+	loadKey := keyFlags(fs, "", confg.KeyEnv)
+	loadNewKey := keyFlags(fs, "new-", "")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	oldKey, err := loadKey()
+	if err != nil {
+		return err
+	}
+	newKey, err := loadNewKey()
+	if err != nil {
+		return err
+	}
+	c, err := open(*format, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if err = confg.Rotate(c, oldKey, newKey); err != nil {
+		return err
+	}
+	return save(c)
+}
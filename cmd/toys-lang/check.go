@@ -0,0 +1,505 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/kidstuff/toys/locale"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template/parse"
+)
+
+// use is a lang key found in the sources.
+type use struct {
+	set    string // empty for the calls using the current lang-set
+	file   string
+	key    string
+	plural bool
+	pos    string
+}
+
+// langFuncs are the template funcs of the view package, set tells whether
+// the lang-set is the first argument and plural whether the key has forms.
+var langFuncs = map[string]struct {
+	set    bool
+	plural bool
+}{
+	"lang":     {false, false},
+	"langn":    {false, true},
+	"langf":    {false, false},
+	"langset":  {true, false},
+	"langsetn": {true, true},
+	"langsetf": {true, false},
+}
+
+// langMethods are the methods of locale.Lang and locale.Localizer.
+var langMethods = map[string]struct {
+	set    bool
+	plural bool
+}{
+	"Load":      {false, false},
+	"Plural":    {false, true},
+	"Format":    {false, false},
+	"LoadSet":   {true, false},
+	"PluralSet": {true, true},
+	"FormatSet": {true, false},
+}
+
+// scanTemplate returns the lang calls of a .tmpl file.
+func scanTemplate(path string) ([]use, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t := parse.New(path)
+	t.Mode = parse.SkipFuncCheck
+	trees := make(map[string]*parse.Tree)
+	// Parse adds t to trees with the define'd templates
+	if _, err = t.Parse(string(b), "", "", trees); err != nil {
+		return nil, err
+	}
+
+	var uses []use
+	names := make([]string, 0, len(trees))
+	for name := range trees {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		uses = append(uses, templateUses(trees[name])...)
+	}
+	return uses, nil
+}
+
+// templateUses returns the lang calls of a template tree.
+func templateUses(t *parse.Tree) []use {
+	var uses []use
+	var walk func(n parse.Node)
+	walk = func(n parse.Node) {
+		switch n := n.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, c := range n.Nodes {
+				walk(c)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, c := range n.Cmds {
+				walk(c)
+			}
+		case *parse.CommandNode:
+			if id, ok := n.Args[0].(*parse.IdentifierNode); ok {
+				if fn, ok := langFuncs[id.Ident]; ok {
+					if u, ok := templateUse(n.Args[1:], fn.set); ok {
+						u.plural = fn.plural
+						u.pos, _ = t.ErrorContext(n)
+						uses = append(uses, u)
+					}
+				}
+			}
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			walk(n.Pipe)
+		}
+	}
+	if t.Root != nil {
+		walk(t.Root)
+	}
+	return uses
+}
+
+func templateUse(args []parse.Node, withSet bool) (use, bool) {
+	var strs []string
+	for _, arg := range args {
+		s, ok := arg.(*parse.StringNode)
+		if !ok {
+			break
+		}
+		strs = append(strs, s.Text)
+	}
+	if withSet {
+		if len(strs) < 3 {
+			return use{}, false
+		}
+		return use{set: strs[0], file: strs[1], key: strs[2]}, true
+	}
+	if len(strs) < 2 {
+		return use{}, false
+	}
+	return use{file: strs[0], key: strs[1]}, true
+}
+
+// scanGo returns the lang calls of a Go source file, the calls of the
+// Lang and Localizer methods with string literal arguments. The file is not
+// type checked, a call counts if its receiver is one of the names declared
+// in the file with the type locale.Lang, *locale.Lang or *locale.Localizer,
+// like a parameter, a variable or a struct field, or is set from
+// locale.NewLang, locale.NewLangFS or a Localizer call. The scopes are not
+// tracked, so a name declared with another type elsewhere in the file can
+// still match.
+func scanGo(path string) ([]use, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	pkg := localeName(f)
+	if pkg == "" {
+		return nil, nil
+	}
+	names := langNames(f, pkg)
+
+	var uses []use
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		m, ok := langMethods[sel.Sel.Name]
+		if !ok || !isLangRecv(sel.X, pkg, names) {
+			return true
+		}
+		want := 2
+		if m.set {
+			want++
+		}
+		if m.plural || sel.Sel.Name == "Format" || sel.Sel.Name == "FormatSet" {
+			want++
+		}
+		if len(call.Args) != want {
+			return true
+		}
+
+		var strs []string
+		for _, arg := range call.Args {
+			lit, ok := arg.(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				break
+			}
+			s, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				break
+			}
+			strs = append(strs, s)
+		}
+		u := use{plural: m.plural, pos: fset.Position(call.Pos()).String()}
+		switch {
+		case m.set && len(strs) >= 3:
+			u.set, u.file, u.key = strs[0], strs[1], strs[2]
+		case !m.set && len(strs) >= 2:
+			u.file, u.key = strs[0], strs[1]
+		default:
+			return true
+		}
+		uses = append(uses, u)
+		return true
+	})
+	return uses, nil
+}
+
+// localeName returns the name the locale package is imported as in f, or ""
+// if f does not import it.
+func localeName(f *ast.File) string {
+	for _, imp := range f.Imports {
+		if p, err := strconv.Unquote(imp.Path.Value); err != nil || p != "github.com/kidstuff/toys/locale" {
+			continue
+		}
+		if imp.Name != nil {
+			return imp.Name.Name
+		}
+		return "locale"
+	}
+	return ""
+}
+
+// langNames returns the names declared in f with a Lang or Localizer type,
+// or set from a Lang or Localizer value.
+func langNames(f *ast.File, pkg string) map[string]bool {
+	names := make(map[string]bool)
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Field:
+			if isLangType(n.Type, pkg) {
+				for _, id := range n.Names {
+					names[id.Name] = true
+				}
+			}
+		case *ast.ValueSpec:
+			for i, id := range n.Names {
+				if isLangType(n.Type, pkg) || i < len(n.Values) && isLangValue(n.Values[i], pkg) {
+					names[id.Name] = true
+				}
+			}
+		case *ast.AssignStmt:
+			if len(n.Lhs) != len(n.Rhs) {
+				break
+			}
+			for i, lhs := range n.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok && isLangValue(n.Rhs[i], pkg) {
+					names[id.Name] = true
+				}
+			}
+		}
+		return true
+	})
+	return names
+}
+
+// isLangType reports whether t is locale.Lang, *locale.Lang or
+// *locale.Localizer, with pkg the name of the locale package.
+func isLangType(t ast.Expr, pkg string) bool {
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	sel, ok := t.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	id, ok := sel.X.(*ast.Ident)
+	return ok && id.Name == pkg && (sel.Sel.Name == "Lang" || sel.Sel.Name == "Localizer")
+}
+
+// isLangValue reports whether x is a call returning a Lang or a Localizer.
+func isLangValue(x ast.Expr, pkg string) bool {
+	call, ok := x.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	if id, ok := sel.X.(*ast.Ident); ok && id.Name == pkg {
+		return sel.Sel.Name == "NewLang" || sel.Sel.Name == "NewLangFS"
+	}
+	return sel.Sel.Name == "Localizer"
+}
+
+// isLangRecv reports whether x, the receiver of a call, is a Lang or a
+// Localizer: one of the names, a field named so or a Lang or Localizer value.
+func isLangRecv(x ast.Expr, pkg string, names map[string]bool) bool {
+	switch x := x.(type) {
+	case *ast.Ident:
+		return names[x.Name]
+	case *ast.SelectorExpr:
+		return names[x.Sel.Name]
+	case *ast.ParenExpr:
+		return isLangRecv(x.X, pkg, names)
+	}
+	return isLangValue(x, pkg)
+}
+
+// scan returns the lang calls of the .tmpl and .go files under the paths.
+func scan(paths []string) ([]use, error) {
+	var uses []use
+	for _, root := range paths {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				if name := info.Name(); path != root && (strings.HasPrefix(name, ".") || name == "vendor" || name == "testdata") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			var found []use
+			switch filepath.Ext(path) {
+			case ".tmpl":
+				found, err = scanTemplate(path)
+			case ".go":
+				found, err = scanGo(path)
+			}
+			if err != nil {
+				return err
+			}
+			uses = append(uses, found...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return uses, nil
+}
+
+// pluralKey returns the key without its plural form suffix.
+func pluralKey(k string) string {
+	pos := strings.LastIndex(k, ".")
+	if pos < 0 {
+		return k
+	}
+	switch k[pos+1:] {
+	case "zero", "one", "two", "few", "many", "other":
+		return k[:pos]
+	}
+	return k
+}
+
+// has reports whether values holds the key, or one of its plural forms.
+func has(values map[string]string, u use) bool {
+	if _, ok := values[u.key]; ok {
+		return true
+	}
+	if u.plural {
+		for k := range values {
+			if pluralKey(k) == u.key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func runCheck(fs *flag.FlagSet, args []string) error {
+	open := langFlags(fs)
+	write := fs.Bool("write", false, "add stub entries for the missing keys to the .lang files")
+	fs.Parse(args)
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	uses, err := scan(paths)
+	if err != nil {
+		return err
+	}
+	l, sets, err := open(nil)
+	if err != nil {
+		return err
+	}
+	def := l.Localizer("").Set()
+
+	missing := 0
+	for _, set := range sets {
+		stubs, n := report(os.Stdout, l, set, def, uses)
+		missing += n
+		if *write {
+			if err = writeStubs(fs.Lookup("root").Value.String(), set, stubs); err != nil {
+				return err
+			}
+		}
+	}
+	if missing > 0 && !*write {
+		return fmt.Errorf("%d missing key(s)", missing)
+	}
+	return nil
+}
+
+// report writes to w the missing, unused and untranslated keys of set, def
+// is the default lang-set. It returns the stub lines of the missing keys by
+// file and the number of missing keys.
+func report(w io.Writer, l *locale.Lang, set, def string, uses []use) (map[string][]string, int) {
+	missing := 0
+	used := make(map[string]map[string]bool)
+	stubs := make(map[string][]string)
+	reported := make(map[string]bool)
+	for _, u := range uses {
+		if u.set != "" && u.set != set {
+			continue
+		}
+		if used[u.file] == nil {
+			used[u.file] = make(map[string]bool)
+		}
+		used[u.file][u.key] = true
+
+		values := l.Values(set, u.file)
+		if has(values, u) || reported[u.file+"\x00"+u.key] {
+			continue
+		}
+		reported[u.file+"\x00"+u.key] = true
+		missing++
+		fmt.Fprintf(w, "%s: missing %s %s (%s)\n", set, u.file, u.key, u.pos)
+
+		k, v := u.key, u.key
+		if u.plural {
+			k += ".other"
+		}
+		if set != def {
+			if dv, ok := l.Values(def, u.file)[k]; ok {
+				v = dv
+			}
+		}
+		stubs[u.file] = append(stubs[u.file], locale.EscapeLang(k, true)+"="+locale.EscapeLang(v, false))
+	}
+
+	for _, file := range l.Files(set) {
+		values := l.Values(set, file)
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if !used[file][k] && !used[file][pluralKey(k)] {
+				fmt.Fprintf(w, "%s: unused %s %s\n", set, file, k)
+			}
+			if set != def {
+				if dv, ok := l.Values(def, file)[k]; ok && dv == values[k] {
+					fmt.Fprintf(w, "%s: untranslated %s %s\n", set, file, k)
+				}
+			}
+		}
+	}
+	return stubs, missing
+}
+
+// writeStubs appends the stub lines to the .lang files of set.
+func writeStubs(root, set string, stubs map[string][]string) error {
+	for file, lines := range stubs {
+		if filepath.Ext(file) != ".lang" {
+			fmt.Fprintf(os.Stderr, "toys-lang: cannot write stubs to %s\n", file)
+			continue
+		}
+		path := filepath.Join(root, set, file)
+		b, err := ioutil.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if len(b) > 0 && b[len(b)-1] != '\n' {
+			b = append(b, '\n')
+		}
+		sort.Strings(lines)
+		b = append(b, strings.Join(lines, "\n")+"\n"...)
+		if err = ioutil.WriteFile(path, b, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"github.com/kidstuff/toys/locale"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func tempFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// noPos clears the positions of the uses.
+func noPos(uses []use) []use {
+	for i := range uses {
+		uses[i].pos = ""
+	}
+	return uses
+}
+
+func TestScanTemplate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "toys-lang")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		src  string
+		want []use
+	}{
+		{`{{lang "index.lang" "hi"}}`, []use{{file: "index.lang", key: "hi"}}},
+		{`{{langn "index.lang" "items" .N}}`, []use{{file: "index.lang", key: "items", plural: true}}},
+		{`{{langf "index.lang" "welcome" "name" .Name}}`, []use{{file: "index.lang", key: "welcome"}}},
+		{`{{langset "vi" "index.lang" "hi"}}`, []use{{set: "vi", file: "index.lang", key: "hi"}}},
+		{`{{langsetn "vi" "index.lang" "items" 2}}`, []use{{set: "vi", file: "index.lang", key: "items", plural: true}}},
+		{`{{lang "index.lang" .Key}}`, nil},
+		{`{{lang .File "hi"}}`, nil},
+		{`{{langset "vi" "index.lang" .Key}}`, nil},
+		{`{{"hi" | lang "index.lang"}}`, nil},
+		{`{{printf "%s!" (lang "index.lang" "nested")}}`, []use{{file: "index.lang", key: "nested"}}},
+		{`{{if .X}}{{lang "a.lang" "if"}}{{else}}{{lang "a.lang" "else"}}{{end}}` +
+			`{{range .L}}{{lang "a.lang" "range"}}{{end}}{{with .W}}{{lang "a.lang" "with"}}{{end}}`,
+			[]use{{file: "a.lang", key: "if"}, {file: "a.lang", key: "else"}, {file: "a.lang", key: "range"}, {file: "a.lang", key: "with"}}},
+		{`{{lang "a.lang" "root"}}{{define "sub"}}{{lang "a.lang" "sub"}}{{end}}`,
+			[]use{{file: "a.lang", key: "root"}, {file: "a.lang", key: "sub"}}},
+		{`{{.Name}}`, nil},
+	}
+	for i, test := range tests {
+		uses, err := scanTemplate(tempFile(t, dir, "page.tmpl", test.src))
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if got := noPos(uses); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%d: %s\ngot  %+v\nwant %+v", i, test.src, got, test.want)
+		}
+	}
+
+	uses, err := scanTemplate(tempFile(t, dir, "page.tmpl", "\n{{lang \"a.lang\" \"hi\"}}"))
+	if err != nil || len(uses) != 1 || uses[0].pos != filepath.Join(dir, "page.tmpl")+":2:2" {
+		t.Errorf("uses = %+v, %v", uses, err)
+	}
+	if _, err = scanTemplate(tempFile(t, dir, "page.tmpl", "{{lang")); err == nil {
+		t.Error("want error for an invalid template")
+	}
+}
+
+func TestScanGo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "toys-lang")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := `package p
+
+import (
+	"github.com/kidstuff/toys/confg"
+	"github.com/kidstuff/toys/locale"
+)
+
+const file = "index.lang"
+
+type server struct {
+	lang *locale.Lang
+}
+
+func f(l *locale.Lang, loc *locale.Localizer, key string, n int) {
+	l.Load("index.lang", "hi")
+	loc.Plural("index.lang", "items", n)
+	l.Format("index.lang", "welcome", nil)
+	l.LoadSet("vi", "index.lang", "hi")
+	l.PluralSet("vi", "index.lang", "items", 2)
+	l.FormatSet("vi", "index.lang", "welcome", nil)
+	l.Load("index.lang", key)
+	l.Load(file, "const")
+	l.LoadSet("vi", "index.lang", key)
+	l.Load("index.lang")
+	l.Plural("index.lang", "items")
+	cfg.Load("a", "b")
+	confg.Load("app.json", "x")
+	tmpl.Format("index.lang", "welcome", nil)
+}
+
+func (s *server) g() {
+	s.lang.Load("index.lang", "field")
+	lc := s.lang.Localizer("vi")
+	lc.Load("index.lang", "localizer")
+	locale.NewLang("languages").Load("index.lang", "new")
+}
+`
+	uses, err := scanGo(tempFile(t, dir, "p.go", src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uses) > 0 && uses[0].pos != filepath.Join(dir, "p.go")+":15:2" {
+		t.Errorf("pos = %s", uses[0].pos)
+	}
+	want := []use{
+		{file: "index.lang", key: "hi"},
+		{file: "index.lang", key: "items", plural: true},
+		{file: "index.lang", key: "welcome"},
+		{set: "vi", file: "index.lang", key: "hi"},
+		{set: "vi", file: "index.lang", key: "items", plural: true},
+		{set: "vi", file: "index.lang", key: "welcome"},
+		{file: "index.lang", key: "field"},
+		{file: "index.lang", key: "localizer"},
+		{file: "index.lang", key: "new"},
+	}
+	if got := noPos(uses); !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+
+	uses, err = scanGo(tempFile(t, dir, "q.go", "package q\n\nfunc f(l *Lang) { l.Load(\"index.lang\", \"hi\") }\n"))
+	if err != nil || len(uses) != 0 {
+		t.Errorf("without the locale import got %+v, %v", uses, err)
+	}
+
+	if _, err = scanGo(tempFile(t, dir, "bad.go", "package")); err == nil {
+		t.Error("want error for an invalid Go file")
+	}
+}
+
+func TestReport(t *testing.T) {
+	root, err := ioutil.TempDir("", "toys-lang")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	tempFile(t, root, "en/index.lang", "hi=Hello\nitems.one=item\nitems.other=items\nold=Old\n")
+	tempFile(t, root, "vi/index.lang", "hi=Hello\nitems.other=món\n")
+
+	l := locale.NewLang(root)
+	for _, set := range []string{"en", "vi"} {
+		if err = l.Parse(set); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = l.SetDefault("en"); err != nil {
+		t.Fatal(err)
+	}
+	uses := []use{
+		{file: "index.lang", key: "hi", pos: "a.tmpl:1:2"},
+		{file: "index.lang", key: "items", plural: true, pos: "a.tmpl:2:2"},
+		{file: "index.lang", key: "bye", pos: "a.tmpl:3:2"},
+		{file: "index.lang", key: "bye", pos: "b.tmpl:1:2"},
+		{set: "vi", file: "index.lang", key: "old", pos: "a.go:5:2"},
+		{set: "en", file: "index.lang", key: "vi only", pos: "a.go:6:2"},
+	}
+
+	tests := []struct {
+		set     string
+		out     string
+		stubs   []string
+		missing int
+	}{
+		{"en", "en: missing index.lang bye (a.tmpl:3:2)\n" +
+			"en: missing index.lang vi only (a.go:6:2)\n" +
+			"en: unused index.lang old\n",
+			[]string{"bye=bye", "vi only=vi only"}, 2},
+		{"vi", "vi: missing index.lang bye (a.tmpl:3:2)\n" +
+			"vi: missing index.lang old (a.go:5:2)\n" +
+			"vi: untranslated index.lang hi\n",
+			[]string{"bye=bye", "old=Old"}, 2},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		stubs, missing := report(&buf, l, test.set, "en", uses)
+		if buf.String() != test.out {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.set, buf.String(), test.out)
+		}
+		if missing != test.missing {
+			t.Errorf("%s: missing = %d, want %d", test.set, missing, test.missing)
+		}
+		if !reflect.DeepEqual(stubs["index.lang"], test.stubs) {
+			t.Errorf("%s: stubs = %q, want %q", test.set, stubs["index.lang"], test.stubs)
+		}
+
+		if err = writeStubs(root, test.set, stubs); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(root, "vi", "index.lang"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "hi=Hello\nitems.other=món\nbye=bye\nold=Old\n"; string(b) != want {
+		t.Errorf("vi/index.lang = %q, want %q", b, want)
+	}
+
+	l = locale.NewLang(root)
+	for _, set := range []string{"en", "vi"} {
+		if err = l.Parse(set); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.SetDefault("en")
+	var buf bytes.Buffer
+	if _, missing := report(&buf, l, "en", "en", uses); missing != 0 {
+		t.Errorf("missing after writing the stubs:\n%s", buf.String())
+	}
+}
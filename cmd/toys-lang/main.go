@@ -0,0 +1,167 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Command toys-lang manages the language folders of the locale package.
+
+Usage:
+
+	toys-lang command [flags] [arguments]
+
+The commands are:
+
+	check  report the missing, unused and untranslated keys
+	import import the translations of XLIFF files into the .lang files
+	po     export the .lang files of lang-sets to gettext .po catalogues
+	xliff  export a lang-set and its source to a XLIFF file
+
+The language folder is given by -root, the default lang-set by -default.
+
+check scans the .tmpl files for the lang, langn, langf and langset... calls
+and the Go files for the Load, Plural, Format and ...Set calls with string
+arguments on the values declared as a locale.Lang or a locale.Localizer in
+the same file, then for every lang-set reports the used keys it lacks, the
+keys never used and the keys with the same value as in the default lang-set.
+With -write it adds a stub entry for each missing key to the .lang files,
+holding the value of the default lang-set, and exits with status 0:
+
+	toys-lang check -root languages -write views/ handlers/
+
+po writes the values of the default lang-set as comments for the
+translators.
+
+xliff exports the default lang-set as source and the given lang-set as
+target, for the translators working with XLIFF 1.2 or 2.0:
+
+	toys-lang xliff -version 2.0 -o vi.xlf vi
+
+import writes the translations back to the .lang files of the target
+lang-set, with the notes and the source texts as comments. A translation is
+marked for review if it is not final or if its source text changed, xliff
+exports it with a needs review state.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/kidstuff/toys/locale"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type command struct {
+	args  string
+	short string
+	run   func(fs *flag.FlagSet, args []string) error
+}
+
+var commands = map[string]*command{
+	"check":  {"[path...]", "report the missing, unused and untranslated keys", runCheck},
+	"import": {"file.xlf...", "import the translations of XLIFF files into the .lang files", runImport},
+	"po":     {"[set...]", "export the .lang files of lang-sets to gettext .po catalogues", runPO},
+	"xliff":  {"set [file...]", "export a lang-set and its source to a XLIFF file", runXLIFF},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: toys-lang command [flags] [arguments]\n\ncommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-9s %s\n", name, commands[name].short)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	name := os.Args[1]
+	cmd, ok := commands[name]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: toys-lang %s [flags] %s\n", name, cmd.args)
+		fs.PrintDefaults()
+	}
+	if err := cmd.run(fs, os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "toys-lang:", err)
+		os.Exit(1)
+	}
+}
+
+// langFlags adds the flags of the language folder and returns a func to
+// open it. The returned sets are the ones given, or all the sets of root.
+func langFlags(fs *flag.FlagSet) func(sets []string) (*locale.Lang, []string, error) {
+	root := fs.String("root", "languages", "the language folder")
+	def := fs.String("default", "en", "the default lang-set")
+	return func(sets []string) (*locale.Lang, []string, error) {
+		if len(sets) == 0 {
+			dirs, err := os.ReadDir(*root)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, d := range dirs {
+				if d.IsDir() && !strings.HasPrefix(d.Name(), ".") {
+					sets = append(sets, d.Name())
+				}
+			}
+		}
+		l := locale.NewLang(*root)
+		for _, set := range sets {
+			if err := l.Parse(set); err != nil {
+				return nil, nil, fmt.Errorf("%s: %s", set, err.Error())
+			}
+		}
+		if err := l.SetDefault(*def); err != nil {
+			return nil, nil, fmt.Errorf("%s: %s", *def, err.Error())
+		}
+		return l, sets, nil
+	}
+}
+
+func runPO(fs *flag.FlagSet, args []string) error {
+	open := langFlags(fs)
+	out := fs.String("o", "po", "output folder, the catalogues are written to out/set/file.po")
+	fs.Parse(args)
+
+	l, sets, err := open(fs.Args())
+	if err != nil {
+		return err
+	}
+	for _, set := range sets {
+		for _, file := range l.Files(set) {
+			if filepath.Ext(file) != ".lang" {
+				continue
+			}
+			path := filepath.Join(*out, set, strings.TrimSuffix(file, ".lang")+".po")
+			if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			f, err := os.Create(path)
+			if err != nil {
+				return err
+			}
+			err = l.ExportPO(f, set, file)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Println(path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/kidstuff/toys/locale"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func runXLIFF(fs *flag.FlagSet, args []string) error {
+	open := langFlags(fs)
+	version := fs.String("version", locale.XLIFF12, "the XLIFF version, 1.2 or 2.0")
+	out := fs.String("o", "", "output file, the standard output if empty")
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	target, files := fs.Arg(0), fs.Args()[1:]
+
+	l, _, err := open([]string{target})
+	if err != nil {
+		return err
+	}
+	source := l.Localizer("").Set()
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return l.ExportXLIFF(w, *version, source, target, files...)
+}
+
+func runImport(fs *flag.FlagSet, args []string) error {
+	open := langFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	for _, name := range fs.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		x, err := locale.ReadXLIFF(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err.Error())
+		}
+		if x.TargetLang == "" {
+			return errors.New(name + ": no target language")
+		}
+
+		root := fs.Lookup("root").Value.String()
+		source := x.SourceLang
+		if source == "" {
+			source = fs.Lookup("default").Value.String()
+		}
+		sets := []string{source}
+		if _, err = os.Stat(filepath.Join(root, x.TargetLang)); err == nil {
+			sets = append(sets, x.TargetLang)
+		}
+		l, _, err := open(sets)
+		if err != nil {
+			return err
+		}
+		files, err := l.ImportXLIFF(x, source, x.TargetLang)
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err.Error())
+		}
+
+		for file, entries := range files {
+			var buf bytes.Buffer
+			if err = locale.WriteLang(&buf, entries); err != nil {
+				return err
+			}
+			path := filepath.Join(root, x.TargetLang, file)
+			if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err = ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+				return err
+			}
+			review := 0
+			for _, e := range entries {
+				if e.Review {
+					review++
+				}
+			}
+			fmt.Printf("%s: %d entries, %d to review\n", path, len(entries), review)
+		}
+	}
+	return nil
+}
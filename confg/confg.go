@@ -4,7 +4,13 @@
 package confg
 
 import (
+	"fmt"
 	"github.com/kidstuff/toys/util/errs"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
 )
 
 type Configurator interface {
@@ -15,34 +21,177 @@ type Configurator interface {
 	Del(k string)
 }
 
-var configurators = make(map[string]Configurator)
+// Factory returns a new Configurator with nothing loaded.
+type Factory func() Configurator
+
+// ReaderLoader is implemented by the Configurators which can load the
+// settings from an io.Reader.
+type ReaderLoader interface {
+	LoadReader(r io.Reader) error
+}
+
+var (
+	configurators = make(map[string]Factory)
+	mux           sync.RWMutex
+)
 
 var (
 	ErrConfiguratorNotFound = errs.New("confg: Configurator not found")
+	ErrNotSupported         = errs.New("confg: operation not supported")
+	ErrNoLayer              = errs.New("confg: no layer to write")
 )
 
-func Register(name string, configurator Configurator) {
-	if configurator == nil {
-		panic("confg: Register configurator is nil")
+// Register makes a Configurator available by the provided name. Each Open
+// calls factory for a new instance. If Register is called twice with the
+// same name or if factory is nil, it panics.
+func Register(name string, factory Factory) {
+	mux.Lock()
+	defer mux.Unlock()
+
+	if factory == nil {
+		panic("confg: Register factory is nil")
 	}
 
 	if _, dup := configurators[name]; dup {
 		panic("confg: Register called twice for " + name)
 	}
 
-	configurators[name] = configurator
+	configurators[name] = factory
 }
 
-func Open(name, path string) (Configurator, error) {
-	config, ok := configurators[name]
+// Formats returns the sorted names of the registered Configurators.
+func Formats() []string {
+	mux.RLock()
+	defer mux.RUnlock()
+
+	names := make([]string, 0, len(configurators))
+	for name := range configurators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New returns a new Configurator of the named format with nothing loaded.
+func New(name string) (Configurator, error) {
+	mux.RLock()
+	factory, ok := configurators[name]
+	mux.RUnlock()
+
 	if !ok {
 		return nil, ErrConfiguratorNotFound
 	}
+	return factory(), nil
+}
 
-	err := config.Load(path)
+// Open returns a new Configurator of the named format loaded from path.
+// The loaded settings are validated with the schemas, if any; the error is a
+// *ValidationError holding every violation if they do not match. The
+// Configurator decrypts the encrypted values if a key source is set, see
+// SetKeySource.
+func Open(name, path string, schemas ...*Schema) (Configurator, error) {
+	config, err := New(name)
+	if err != nil {
+		return nil, err
+	}
+
+	err = config.Load(path)
 	if err != nil {
 		return nil, errs.Err(err, "confg: cannot Open")
 	}
 
+	return opened(config, schemas)
+}
+
+// OpenReader returns a new Configurator of the named format loaded from r
+// and validated with the schemas, see Open. The Configurator must be a
+// ReaderLoader.
+func OpenReader(name string, r io.Reader, schemas ...*Schema) (Configurator, error) {
+	config, err := New(name)
+	if err != nil {
+		return nil, err
+	}
+
+	loader, ok := config.(ReaderLoader)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	if err = loader.LoadReader(r); err != nil {
+		return nil, errs.Err(err, "confg: cannot Open")
+	}
+
+	return opened(config, schemas)
+}
+
+// OpenFS likes OpenReader but reads the file at path in fsys, for example
+// a file embedded in the binary.
+func OpenFS(name string, fsys fs.FS, path string, schemas ...*Schema) (Configurator, error) {
+	f, err := fsys.Open(path)
+	if err != nil {
+		return nil, errs.Err(err, "confg: cannot Open "+path)
+	}
+	defer f.Close()
+
+	return OpenReader(name, f, schemas...)
+}
+
+// opened returns config, decrypting its values if a key source is set, if
+// it matches the schemas. Otherwise config is closed.
+func opened(config Configurator, schemas []*Schema) (Configurator, error) {
+	c, err := withSecrets(config)
+	if err != nil {
+		config.Close()
+		return nil, err
+	}
+	config = c
+	for _, s := range schemas {
+		if err := s.Validate(config); err != nil {
+			config.Close()
+			return nil, err
+		}
+	}
 	return config, nil
 }
+
+// Lookup returns the value of k from c. If c has no value for a dotted key
+// like "db.host", Lookup walks down the nested maps, so a JSON file with
+// {"db": {"host": "x"}} gives "x" too.
+func Lookup(c Configurator, k string) interface{} {
+	if v := c.Get(k); v != nil {
+		return v
+	}
+	parts := strings.Split(k, ".")
+	for i := len(parts) - 1; i > 0; i-- {
+		if m, ok := c.Get(strings.Join(parts[:i], ".")).(map[string]interface{}); ok {
+			if v := lookupMap(m, parts[i:]); v != nil {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+func lookupMap(m map[string]interface{}, parts []string) interface{} {
+	for i := len(parts); i > 0; i-- {
+		v, ok := m[strings.Join(parts[:i], ".")]
+		if !ok {
+			continue
+		}
+		if i == len(parts) {
+			return v
+		}
+		if sub, ok := v.(map[string]interface{}); ok {
+			if v := lookupMap(sub, parts[i:]); v != nil {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+func toString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,76 @@
+package confg
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+// lineConfg loads "key=value" lines, for testing the registry.
+type lineConfg struct {
+	*MapConfg
+}
+
+func (c *lineConfg) Load(path string) error {
+	c.Set("path", path)
+	return nil
+}
+
+func (c *lineConfg) LoadReader(r io.Reader) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		kv := strings.SplitN(line, "=", 2)
+		c.Set(kv[0], kv[1])
+	}
+	return nil
+}
+
+func init() {
+	Register("testconfg", func() Configurator {
+		return &lineConfg{NewMapConfg(nil)}
+	})
+}
+
+func TestOpen(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Formats()
+			New("testconfg")
+		}()
+	}
+	wg.Wait()
+
+	a, err := Open("testconfg", "a.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Open("testconfg", "b.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Get("path") != "a.conf" || b.Get("path") != "b.conf" {
+		t.Errorf("Open returned shared instances: %v, %v", a.Get("path"), b.Get("path"))
+	}
+
+	if _, err := Open("nosuchconfg", "a.conf"); err != ErrConfiguratorNotFound {
+		t.Errorf("Open of an unknown format returned %v", err)
+	}
+
+	fsys := fstest.MapFS{"app.conf": {Data: []byte("name=toys\nmode=dev\n")}}
+	c, err := OpenFS("testconfg", fsys, "app.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Get("name") != "toys" || c.Get("mode") != "dev" {
+		t.Errorf("OpenFS loaded name=%v mode=%v", c.Get("name"), c.Get("mode"))
+	}
+}
@@ -0,0 +1,289 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package flagconfg implements a confg.Configurator for command-line flags. Every
+key of a confg.Schema or of an existing Configurator becomes a flag named by
+the dotted key:
+
+	flags := flagconfg.NewSchema(flag.CommandLine, schema)
+	flag.Parse()
+	c := confg.NewLayered(
+		confg.Layer{"defaults", schema.Defaults()},
+		confg.Layer{"file", file},
+		confg.Layer{"flags", flags},
+	)
+
+so "app --db.port 5433" overrides db.port. Only the flags given on the command
+line have a value, the others return nil from Get so the lower layers are
+used. A list flag can be repeated and a map flag takes key=value items.
+
+FlagConfig is not registered with confg.Register, there is no file to load.
+*/
+package flagconfg
+
+import (
+	"flag"
+	"fmt"
+	"github.com/kidstuff/toys/confg"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FlagConfig is a Configurator holding the values of the flags given on the
+// command line.
+type FlagConfig struct {
+	fs    *flag.FlagSet
+	flags map[string]*value
+	mux   sync.RWMutex
+}
+
+// New returns a FlagConfig adding its flags to fs, or to flag.CommandLine if
+// fs is nil. Use Var to add the flags.
+func New(fs *flag.FlagSet) *FlagConfig {
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+	c := &FlagConfig{}
+	c.fs = fs
+	c.flags = make(map[string]*value)
+	return c
+}
+
+// NewSchema returns a FlagConfig with a flag for every field of s, the help
+// of the field is the usage of the flag.
+func NewSchema(fs *flag.FlagSet, s *confg.Schema) *FlagConfig {
+	c := New(fs)
+	for _, f := range s.Fields {
+		c.Var(f.Key, f.Type, f.Default, usage(f))
+	}
+	return c
+}
+
+// NewFrom returns a FlagConfig with a flag for every key of src, which must
+// be a confg.Keyer. The type of a flag is guessed from the current value,
+// which is shown as the default.
+func NewFrom(fs *flag.FlagSet, src confg.Configurator) *FlagConfig {
+	c := New(fs)
+	keyer, ok := src.(confg.Keyer)
+	if !ok {
+		return c
+	}
+	for _, k := range keyer.Keys() {
+		v := confg.Lookup(src, k)
+		c.Var(k, typeOf(v), v, "")
+	}
+	return c
+}
+
+func usage(f confg.Field) string {
+	var notes []string
+	if f.Required {
+		notes = append(notes, "required")
+	}
+	if len(f.Enum) > 0 {
+		items := make([]string, len(f.Enum))
+		for i, e := range f.Enum {
+			items[i] = fmt.Sprint(e)
+		}
+		notes = append(notes, "one of "+strings.Join(items, ", "))
+	}
+	if len(notes) == 0 {
+		return f.Help
+	}
+	return strings.TrimSpace(f.Help + " (" + strings.Join(notes, "; ") + ")")
+}
+
+func typeOf(v interface{}) confg.Type {
+	switch v.(type) {
+	case bool:
+		return confg.Bool
+	case string:
+		return confg.String
+	case time.Duration:
+		return confg.Duration
+	case float32, float64:
+		return confg.Float
+	case map[string]interface{}:
+		return confg.Map
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return confg.Int
+	case reflect.Slice, reflect.Array:
+		return confg.List
+	}
+	return confg.Any
+}
+
+// Var adds the flag for the key k. def is only shown in the help, Get
+// returns nil until the flag is given.
+func (c *FlagConfig) Var(k string, typ confg.Type, def interface{}, usage string) {
+	v := &value{typ: typ, val: def, mux: &c.mux}
+	c.mux.Lock()
+	c.flags[k] = v
+	c.mux.Unlock()
+	c.fs.Var(v, k, usage)
+}
+
+// Parse parses args with the FlagSet, it is the same as calling Parse of the
+// FlagSet.
+func (c *FlagConfig) Parse(args []string) error {
+	return c.fs.Parse(args)
+}
+
+// Load is not supported by FlagConfig.
+func (c *FlagConfig) Load(path string) error {
+	return confg.ErrNotSupported
+}
+
+func (c *FlagConfig) Close() error {
+	return nil
+}
+
+// Set sets the value of k as if the flag was given. It does nothing if k is
+// not a flag.
+func (c *FlagConfig) Set(k string, v interface{}) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if f, ok := c.flags[k]; ok {
+		f.val = v
+		f.set = true
+	}
+}
+
+// Get returns the value of the flag k, or nil if the flag was not given.
+func (c *FlagConfig) Get(k string) interface{} {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	if f, ok := c.flags[k]; ok && f.set {
+		return f.val
+	}
+	return nil
+}
+
+// Del makes the flag k look like it was not given.
+func (c *FlagConfig) Del(k string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if f, ok := c.flags[k]; ok {
+		f.set = false
+	}
+}
+
+// Keys returns the keys of the flags given on the command line.
+func (c *FlagConfig) Keys() []string {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	var keys []string
+	for k, f := range c.flags {
+		if f.set {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// value is a flag.Value converting the argument to the type of the key. It
+// shares the lock of its FlagConfig, so the flags can be parsed while the
+// FlagConfig is read.
+type value struct {
+	typ confg.Type
+	val interface{}
+	set bool
+	mux *sync.RWMutex
+}
+
+func (v *value) String() string {
+	// the flag package calls String on a zero value to find the default
+	if v == nil || v.mux == nil {
+		return ""
+	}
+	v.mux.RLock()
+	defer v.mux.RUnlock()
+	if v.val == nil {
+		return ""
+	}
+	if m, ok := v.val.(map[string]interface{}); ok {
+		items := make([]string, 0, len(m))
+		for k, item := range m {
+			items = append(items, fmt.Sprintf("%s=%v", k, item))
+		}
+		sort.Strings(items)
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(v.val)
+}
+
+func (v *value) Get() interface{} {
+	v.mux.RLock()
+	defer v.mux.RUnlock()
+	return v.val
+}
+
+// IsBoolFlag lets a bool flag be given without a value.
+func (v *value) IsBoolFlag() bool {
+	return v.typ == confg.Bool
+}
+
+func (v *value) Set(s string) error {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	var (
+		val interface{}
+		err error
+	)
+	switch v.typ {
+	case confg.Int:
+		val, err = strconv.ParseInt(s, 0, 64)
+	case confg.Float:
+		val, err = strconv.ParseFloat(s, 64)
+	case confg.Bool:
+		val, err = strconv.ParseBool(s)
+	case confg.Duration:
+		val, err = time.ParseDuration(s)
+	case confg.List:
+		// each occurrence of the flag adds an item
+		var list []interface{}
+		if v.set {
+			list, _ = v.val.([]interface{})
+		}
+		val = append(list, s)
+	case confg.Map:
+		m := make(map[string]interface{})
+		if v.set {
+			if old, ok := v.val.(map[string]interface{}); ok {
+				m = old
+			}
+		}
+		for _, item := range strings.Split(s, ",") {
+			kv := strings.SplitN(item, "=", 2)
+			if len(kv) != 2 {
+				return fmt.Errorf("want key=value, got %q", item)
+			}
+			m[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+		val = m
+	default:
+		val = s
+	}
+	if err != nil {
+		return err
+	}
+	v.val = val
+	v.set = true
+	return nil
+}
+
+var (
+	_ confg.Configurator = &FlagConfig{}
+	_ confg.Keyer        = &FlagConfig{}
+	_ flag.Getter        = &value{}
+)
@@ -0,0 +1,89 @@
+package flagconfg
+
+import (
+	"flag"
+	"github.com/kidstuff/toys/confg"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFlagConfig(t *testing.T) {
+	s := &confg.Schema{Fields: []confg.Field{
+		{Key: "db.port", Type: confg.Int, Default: 5432, Help: "database port"},
+		{Key: "db.hosts", Type: confg.List},
+		{Key: "debug", Type: confg.Bool},
+		{Key: "timeout", Type: confg.Duration},
+		{Key: "log.level", Type: confg.String, Enum: []interface{}{"info", "error"}},
+	}}
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	c := NewSchema(fs, s)
+
+	err := c.Parse([]string{"--db.port", "5433", "--debug", "-db.hosts=a", "--db.hosts", "b", "--timeout=2s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]interface{}{
+		"db.port":   int64(5433),
+		"db.hosts":  []interface{}{"a", "b"},
+		"debug":     true,
+		"timeout":   2 * time.Second,
+		"log.level": nil,
+	}
+	for k, want := range tests {
+		if got := c.Get(k); !reflect.DeepEqual(got, want) {
+			t.Errorf("Get(%q) = %#v, want %#v", k, got, want)
+		}
+	}
+	if got := c.Keys(); !reflect.DeepEqual(got, []string{"db.hosts", "db.port", "debug", "timeout"}) {
+		t.Errorf("Keys() = %v", got)
+	}
+	if err := s.Validate(c); err != nil {
+		t.Errorf("flag values do not match the schema: %v", err)
+	}
+
+	if err := c.Parse([]string{"--db.port", "many"}); err == nil {
+		t.Error("Parse accepted a non integer port")
+	}
+
+	var help strings.Builder
+	fs.SetOutput(&help)
+	fs.PrintDefaults()
+	if !strings.Contains(help.String(), "database port (default 5432)") ||
+		!strings.Contains(help.String(), "(one of info, error)") {
+		t.Errorf("help text:\n%s", help.String())
+	}
+}
+
+func TestConcurrentParse(t *testing.T) {
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	c := New(fs)
+	c.Var("hosts", confg.List, nil, "")
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			c.Get("hosts")
+			c.Keys()
+		}
+	}()
+	args := make([]string, 0, 200)
+	for i := 0; i < 100; i++ {
+		args = append(args, "-hosts", "h")
+	}
+	if err := c.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	if hosts, _ := c.Get("hosts").([]interface{}); len(hosts) != 100 {
+		t.Errorf("got %d hosts", len(hosts))
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iniconfg
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/kidstuff/toys/confg"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// encode writes data in INI format. The lines of the loaded file are kept
+// for the unchanged keys, changed keys are rewritten in place and new keys
+// are added at the end of their section. It returns an error if a value
+// cannot be written in INI, like a nil or a list of maps.
+func (doc *document) encode(data confg.Tree) ([]byte, error) {
+	flat := confg.Flatten(data)
+	for _, k := range sortedKeys(flat) {
+		if err := checkValue(k, flat[k]); err != nil {
+			return nil, err
+		}
+	}
+
+	known := make(map[string]bool)
+	sections := make(map[string]bool)
+	// index of the statement after which the new keys of a section go, -1
+	// is the beginning of the file
+	after := make(map[string]int)
+	rootAfter := len(doc.stmts) - 1
+	for i, st := range doc.stmts {
+		switch st.kind {
+		case stmtSection:
+			if len(sections) == 0 && rootAfter == len(doc.stmts)-1 {
+				rootAfter = -1
+			}
+			sections[st.section] = true
+			if _, ok := after[st.section]; !ok {
+				after[st.section] = i
+			}
+		case stmtEntry:
+			known[st.key] = true
+			if st.section == "" {
+				rootAfter = i
+			} else {
+				after[st.section] = i
+			}
+		}
+	}
+	after[""] = rootAfter
+
+	added := make(map[string][]string)
+	var newSections []string
+	for _, k := range sortedKeys(flat) {
+		if known[k] || isEmptyMap(flat[k]) {
+			continue
+		}
+		sec := ""
+		for s := range sections {
+			if strings.HasPrefix(k, s+".") && len(s) > len(sec) {
+				sec = s
+			}
+		}
+		if sec == "" {
+			if pos := strings.LastIndex(k, "."); pos >= 0 {
+				sec = k[:pos]
+			}
+		}
+		if _, ok := after[sec]; !ok && added[sec] == nil {
+			newSections = append(newSections, sec)
+		}
+		name := k
+		if sec != "" {
+			name = k[len(sec)+1:]
+		}
+		added[sec] = append(added[sec], entryLines("", name, flat[k], "")...)
+	}
+
+	var buf bytes.Buffer
+	writeLines := func(lines []string) {
+		for _, l := range lines {
+			buf.WriteString(l)
+			buf.WriteByte('\n')
+		}
+	}
+	if after[""] < 0 {
+		writeLines(added[""])
+	}
+
+	written := make(map[string]bool)
+	for i, st := range doc.stmts {
+		switch st.kind {
+		case stmtComment:
+			writeLines([]string{st.raw})
+		case stmtSection:
+			if data.Get(st.section) != nil || len(added[st.section]) > 0 {
+				writeLines([]string{st.raw})
+			}
+		case stmtEntry:
+			v, ok := flat[st.key]
+			if !ok || written[st.key] {
+				break
+			}
+			if reflect.DeepEqual(v, st.value) {
+				writeLines([]string{st.raw})
+				break
+			}
+			indent := st.raw[:len(st.raw)-len(strings.TrimLeft(st.raw, " \t"))]
+			writeLines(entryLines(indent, st.name, v, st.comment))
+			written[st.key] = true
+		}
+		for sec, pos := range after {
+			if pos == i {
+				writeLines(added[sec])
+			}
+		}
+	}
+
+	for _, sec := range newSections {
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		writeLines([]string{"[" + sec + "]"})
+		writeLines(added[sec])
+	}
+	return buf.Bytes(), nil
+}
+
+// checkValue returns an error if v cannot be written as the value of k: INI
+// only holds scalars and lists of scalars. The parts of k cannot start with
+// [, ; or #, read back as a section or a comment, or hold a =.
+func checkValue(k string, v interface{}) error {
+	for _, part := range strings.Split(k, ".") {
+		if strings.ContainsRune(part, '=') || part != "" && strings.ContainsRune("[;#", rune(part[0])) {
+			return fmt.Errorf("iniconfg: %s: cannot write the key", k)
+		}
+	}
+	rv := reflect.ValueOf(v)
+	if v != nil && (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) && rv.Type().Elem().Kind() != reflect.Uint8 {
+		for i := 0; i < rv.Len(); i++ {
+			if item := rv.Index(i).Interface(); !isScalar(item) {
+				return fmt.Errorf("iniconfg: %s: cannot write a list holding a %T value", k, item)
+			}
+		}
+		return nil
+	}
+	if !isScalar(v) && !isEmptyMap(v) {
+		return fmt.Errorf("iniconfg: %s: cannot write a %T value", k, v)
+	}
+	return nil
+}
+
+// isScalar reports whether encodeValue can write v.
+func isScalar(v interface{}) bool {
+	switch v.(type) {
+	case nil:
+		return false
+	case string, []byte, bool, time.Time, fmt.Stringer:
+		return true
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// entryLines returns the lines of a key, one line per item for arrays.
+func entryLines(indent, name string, v interface{}, comment string) []string {
+	rv := reflect.ValueOf(v)
+	if v != nil && (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) && rv.Type().Elem().Kind() != reflect.Uint8 {
+		lines := make([]string, rv.Len())
+		for i := range lines {
+			lines[i] = indent + name + "[] = " + encodeValue(rv.Index(i).Interface())
+		}
+		if len(lines) > 0 {
+			lines[0] += comment
+		}
+		return lines
+	}
+	return []string{indent + name + " = " + encodeValue(v) + comment}
+}
+
+func encodeValue(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		if needQuote(x) {
+			return quote(x)
+		}
+		return x
+	case []byte:
+		return encodeValue(string(x))
+	case bool:
+		return strconv.FormatBool(x)
+	case float32:
+		return strconv.FormatFloat(float64(x), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(x, 'g', -1, 64)
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		return encodeValue(x.String())
+	}
+	return fmt.Sprint(v)
+}
+
+// needQuote reports whether s would not be read back as the same string.
+func needQuote(s string) bool {
+	if s != strings.TrimSpace(s) || strings.ContainsAny(s, ";#\"'\n\r\t\\") {
+		return s != ""
+	}
+	_, isStr := typed(s).(string)
+	return !isStr
+}
+
+func quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
+
+func isEmptyMap(v interface{}) bool {
+	m, ok := v.(map[string]interface{})
+	return ok && len(m) == 0
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,153 @@
+package iniconfg
+
+import (
+	"github.com/kidstuff/toys/confg"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const sample = `; global settings
+name = toys
+
+[db]
+host = localhost ; the database host
+port = 5432
+ratio = 0.5
+debug = false
+replicas[] = db1.local
+replicas[] = db2.local
+
+# mail settings
+[mail]
+from = "no-reply@example.com"
+`
+
+func TestParse(t *testing.T) {
+	doc, err := parse("sample.ini", sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := doc.tree()
+
+	tests := map[string]interface{}{
+		"name":        "toys",
+		"db.host":     "localhost",
+		"db.port":     int64(5432),
+		"db.ratio":    0.5,
+		"db.debug":    false,
+		"db.replicas": []interface{}{"db1.local", "db2.local"},
+		"mail.from":   "no-reply@example.com",
+	}
+	for k, want := range tests {
+		if got := data.Get(k); !reflect.DeepEqual(got, want) {
+			t.Errorf("Get(%q) = %#v, want %#v", k, got, want)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	doc, err := parse("sample.ini", sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := doc.tree()
+	if got, err := doc.encode(data); err != nil || string(got) != sample {
+		t.Fatalf("unchanged document encoded as:\n%s, %v", got, err)
+	}
+
+	data.Set("db.host", "db.example.com")
+	data.Del("db.debug")
+	data.Set("db.replicas", []interface{}{"db3.local"})
+	data.Set("db.user", "admin")
+	data.Set("version", "1.0")
+	data.Set("cache.size", int64(64))
+
+	want := `; global settings
+name = toys
+version = "1.0"
+
+[db]
+host = db.example.com ; the database host
+port = 5432
+ratio = 0.5
+replicas[] = db3.local
+user = admin
+
+# mail settings
+[mail]
+from = "no-reply@example.com"
+
+[cache]
+size = 64
+`
+	b, err := doc.encode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(b)
+	if got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if _, err := parse("out.ini", got); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncodeUnsupported(t *testing.T) {
+	for _, v := range []interface{}{
+		nil,
+		[]interface{}{map[string]interface{}{"n": int64(1)}},
+		[]interface{}{[]interface{}{"a"}},
+		struct{ N int }{1},
+	} {
+		data := confg.Tree{"servers": v}
+		if _, err := (&document{}).encode(data); err == nil {
+			t.Errorf("encode(%#v) wrote a value INI cannot hold", v)
+		}
+	}
+
+	for _, data := range []confg.Tree{
+		{"[0": int64(0)},
+		{"": map[string]interface{}{"[0": int64(0)}},
+		{"db": map[string]interface{}{";port": int64(1)}},
+		{"#host": "a"},
+		{"a=b": "c"},
+	} {
+		if _, err := (&document{}).encode(data); err == nil {
+			t.Errorf("encode(%v) wrote a key INI cannot hold", data)
+		}
+	}
+	b, err := (&document{}).encode(confg.Tree{"a[0": int64(1), "db": map[string]interface{}{"x#": "y"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := parse("keys.ini", string(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := doc.tree(); got.Get("a[0") != int64(1) || got.Get("db.x#") != "y" {
+		t.Errorf("read back %v from:\n%s", got, b)
+	}
+}
+
+func TestSyntaxError(t *testing.T) {
+	tests := map[string]int{
+		"a = 1\n[db\n":              2,
+		"a = 1\nb\n":                2,
+		"a = 1\na = 2\n":            2,
+		"[a]\nb = \"x\n":            2,
+		"a = 1\n\n[b]\nc = 'x' y\n": 4,
+	}
+	for src, line := range tests {
+		_, err := parse("bad.ini", src)
+		serr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("parse(%q) error = %v, want *SyntaxError", src, err)
+			continue
+		}
+		if serr.Line != line || !strings.HasPrefix(serr.Error(), "iniconfg: bad.ini:") {
+			t.Errorf("parse(%q) error = %v, want line %d", src, err, line)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iniconfg
+
+import (
+	"errors"
+	"github.com/kidstuff/toys/confg"
+	"strconv"
+	"strings"
+)
+
+const (
+	stmtComment = iota // comment or blank line
+	stmtSection
+	stmtEntry
+)
+
+// stmt is a line of the loaded file.
+type stmt struct {
+	raw     string
+	kind    int
+	section string
+	// for entries
+	name    string // the key as written, without []
+	key     string // full dotted key
+	array   bool
+	value   interface{}
+	comment string // the inline comment with the spaces before it
+}
+
+// document remembers the lines of a file so it can be written back with the
+// comments in place.
+type document struct {
+	stmts []stmt
+}
+
+func parse(file, src string) (*document, error) {
+	doc := &document{}
+	data := make(confg.Tree)
+	section := ""
+	lines := strings.Split(strings.Replace(src, "\r\n", "\n", -1), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	for i, raw := range lines {
+		line := strings.TrimSpace(raw)
+		st := stmt{raw: raw, section: section}
+		synErr := func(msg string) error {
+			return &SyntaxError{file, i + 1, msg}
+		}
+
+		switch {
+		case line == "" || line[0] == ';' || line[0] == '#':
+			st.kind = stmtComment
+		case line[0] == '[':
+			end := strings.IndexByte(line, ']')
+			if end < 0 {
+				return nil, synErr("missing ] in section header")
+			}
+			if rest := strings.TrimSpace(line[end+1:]); rest != "" && rest[0] != ';' && rest[0] != '#' {
+				return nil, synErr("unexpected text after section header: " + rest)
+			}
+			section = strings.TrimSpace(line[1:end])
+			if section == "" {
+				return nil, synErr("empty section name")
+			}
+			if v := data.Get(section); v != nil {
+				if _, ok := v.(map[string]interface{}); !ok {
+					return nil, synErr("section " + section + " conflicts with a key")
+				}
+			}
+			st.kind = stmtSection
+			st.section = section
+		default:
+			pos := strings.IndexByte(line, '=')
+			if pos < 0 {
+				return nil, synErr("expected key = value")
+			}
+			st.kind = stmtEntry
+			st.name = strings.TrimSpace(line[:pos])
+			if strings.HasSuffix(st.name, "[]") {
+				st.array = true
+				st.name = strings.TrimSpace(st.name[:len(st.name)-2])
+			}
+			if st.name == "" {
+				return nil, synErr("missing key before =")
+			}
+			st.key = st.name
+			if section != "" {
+				st.key = section + "." + st.name
+			}
+
+			var err error
+			st.value, st.comment, err = parseValue(line[pos+1:])
+			if err != nil {
+				return nil, synErr(err.Error())
+			}
+			// keep the original spaces before the comment
+			if st.comment != "" {
+				st.comment = commentOf(raw, st.comment)
+			}
+
+			old := data.Get(st.key)
+			if _, ok := old.(map[string]interface{}); ok {
+				return nil, synErr("key " + st.key + " conflicts with a section")
+			}
+			if !canSet(data, st.key) {
+				return nil, synErr("key " + st.key + " conflicts with another key")
+			}
+			if st.array {
+				arr, ok := old.([]interface{})
+				if old != nil && !ok {
+					return nil, synErr("array " + st.key + " already has a single value")
+				}
+				data.Set(st.key, append(arr, st.value))
+			} else {
+				if old != nil {
+					return nil, synErr("duplicate key " + st.key)
+				}
+				data.Set(st.key, st.value)
+			}
+		}
+		doc.stmts = append(doc.stmts, st)
+	}
+
+	// array entries keep the whole original array to detect changes
+	for i := range doc.stmts {
+		if doc.stmts[i].array {
+			doc.stmts[i].value = data.Get(doc.stmts[i].key)
+		}
+	}
+	return doc, nil
+}
+
+// commentOf returns the trailing comment of raw with the spaces before it.
+func commentOf(raw, comment string) string {
+	trimmed := strings.TrimRight(raw, " \t")
+	body := strings.TrimSuffix(trimmed, strings.TrimSpace(comment))
+	return trimmed[len(strings.TrimRight(body, " \t")):]
+}
+
+// canSet reports whether no value other than a map is on the way to k.
+func canSet(t confg.Tree, k string) bool {
+	parts := strings.Split(k, ".")
+	for i := 1; i < len(parts); i++ {
+		v := t.Get(strings.Join(parts[:i], "."))
+		if _, ok := v.(map[string]interface{}); v != nil && !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// tree returns the settings of the document.
+func (doc *document) tree() confg.Tree {
+	data := make(confg.Tree)
+	for _, st := range doc.stmts {
+		if st.kind == stmtEntry {
+			data.Set(st.key, copyValue(st.value))
+		}
+	}
+	return data
+}
+
+func copyValue(v interface{}) interface{} {
+	if arr, ok := v.([]interface{}); ok {
+		return append([]interface{}(nil), arr...)
+	}
+	return v
+}
+
+// parseValue parses the part after = and returns the value and the inline
+// comment.
+func parseValue(s string) (interface{}, string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", "", nil
+	}
+
+	if s[0] == '"' || s[0] == '\'' {
+		str, n, err := unquote(s)
+		if err != nil {
+			return nil, "", err
+		}
+		rest := strings.TrimSpace(s[n:])
+		if rest != "" && rest[0] != ';' && rest[0] != '#' {
+			return nil, "", errors.New("unexpected text after quoted value: " + rest)
+		}
+		return str, rest, nil
+	}
+
+	comment := ""
+	for i := 1; i < len(s); i++ {
+		if (s[i] == ';' || s[i] == '#') && (s[i-1] == ' ' || s[i-1] == '\t') {
+			comment = s[i:]
+			s = strings.TrimSpace(s[:i])
+			break
+		}
+	}
+	return typed(s), comment, nil
+}
+
+// typed converts an unquoted value to bool, int64 or float64 if possible.
+func typed(s string) interface{} {
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return s
+}
+
+// unquote reads a quoted string at the start of s and returns it with the
+// number of bytes consumed. Single quoted strings have no escapes.
+func unquote(s string) (string, int, error) {
+	q := s[0]
+	var buf []byte
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == q:
+			return string(buf), i + 1, nil
+		case c == '\\' && q == '"':
+			i++
+			if i == len(s) {
+				return "", 0, errors.New("unterminated escape sequence")
+			}
+			switch s[i] {
+			case 'n':
+				buf = append(buf, '\n')
+			case 't':
+				buf = append(buf, '\t')
+			case 'r':
+				buf = append(buf, '\r')
+			case '"', '\\':
+				buf = append(buf, s[i])
+			default:
+				return "", 0, errors.New("invalid escape sequence \\" + string(s[i]))
+			}
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return "", 0, errors.New("missing closing quote")
+}
@@ -4,51 +4,112 @@ import (
 	"encoding/json"
 	"github.com/kidstuff/toys/confg"
 	"github.com/kidstuff/toys/util/errs"
+	"io"
+	"io/ioutil"
 	"os"
 )
 
 func init() {
-	confg.Register("jsonconfg", &JSONConfig{})
+	confg.Register("jsonconfg", func() confg.Configurator {
+		return &JSONConfig{}
+	})
 }
 
+// JSONConfig is a Configurator for JSON files. Nested objects can be reached
+// with dotted keys like "db.host".
 type JSONConfig struct {
-	data map[string]interface{}
+	data confg.Tree
 	file *os.File
+	path string
 }
 
+// Load reads the file at path. If the file cannot be decoded the values
+// loaded before are kept, so Load can be called again to reload the file.
 func (c *JSONConfig) Load(path string) error {
-	var err error
-	c.file, err = os.Open(path)
+	f, err := os.Open(path)
 	if err != nil {
 		return errs.Errf(err, "jsonconfg: cannot load the file: %s", path)
 	}
 
-	c.data = make(map[string]interface{})
-	dec := json.NewDecoder(c.file)
-	err = dec.Decode(&c.data)
+	data := make(confg.Tree)
+	dec := json.NewDecoder(f)
+	err = dec.Decode(&data)
 	if err != nil {
+		f.Close()
 		return errs.Errf(err, "jsonconfig: cannot deocde data in %s", path)
 	}
 
+	if c.file != nil {
+		c.file.Close()
+	}
+	c.file = f
+	c.path = path
+	c.data = data
+	return nil
+}
+
+// LoadReader reads the settings from r, the result cannot be saved.
+func (c *JSONConfig) LoadReader(r io.Reader) error {
+	data := make(confg.Tree)
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return errs.Err(err, "jsonconfig: cannot deocde data")
+	}
+	c.data = data
+	c.path = ""
+	return nil
+}
+
+// Save writes the settings back to the loaded file.
+func (c *JSONConfig) Save() error {
+	if c.path == "" {
+		return errs.New("jsonconfg: no file loaded to save")
+	}
+	return c.SaveAs(c.path)
+}
+
+// SaveAs writes the settings to path, the next Save writes there too.
+func (c *JSONConfig) SaveAs(path string) error {
+	b, err := json.MarshalIndent(c.data, "", "\t")
+	if err != nil {
+		return errs.Err(err, "jsonconfg: cannot encode data")
+	}
+	err = ioutil.WriteFile(path, append(b, '\n'), 0644)
+	if err != nil {
+		return errs.Err(err, "jsonconfg: cannot save the file: "+path)
+	}
+	c.path = path
 	return nil
 }
 
 func (c *JSONConfig) Close() error {
+	if c.file == nil {
+		return nil
+	}
 	return c.file.Close()
 }
 
 func (c *JSONConfig) Set(k string, v interface{}) {
-	c.data[k] = v
-	//TODO: make change to file
+	if c.data == nil {
+		c.data = make(confg.Tree)
+	}
+	c.data.Set(k, v)
 }
 
 func (c *JSONConfig) Get(k string) interface{} {
-	return c.data[k]
+	return c.data.Get(k)
 }
 
 func (c *JSONConfig) Del(k string) {
-	delete(c.data, k)
-	//TODO: make change to file
+	c.data.Del(k)
 }
 
-var _ confg.Configurator = &JSONConfig{}
+func (c *JSONConfig) Keys() []string {
+	return c.data.Keys()
+}
+
+var (
+	_ confg.Configurator = &JSONConfig{}
+	_ confg.Keyer        = &JSONConfig{}
+	_ confg.Saver        = &JSONConfig{}
+	_ confg.ReaderLoader = &JSONConfig{}
+)
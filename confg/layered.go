@@ -0,0 +1,216 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package confg
+
+import (
+	"github.com/kidstuff/toys/util/errs"
+	"sort"
+	"sync"
+)
+
+// Layer is a named Configurator stacked inside a Layered.
+type Layer struct {
+	Name string
+	Configurator
+}
+
+// Layered stacks some Configurators into one view. Layers added later take
+// precedence over the earlier ones, so the usual order is defaults, base file,
+// environment file, environment variables and then command-line flags.
+// When more than one layer holds a map for the same key, the maps are merged
+// deeply with the same precedence.
+type Layered struct {
+	layers []Layer
+	write  int
+	mux    sync.RWMutex
+}
+
+// NewLayered returns a Layered with the given layers, lowest precedence first.
+// Set and Del write to the top layer until SetWriteLayer is called.
+func NewLayered(layers ...Layer) *Layered {
+	l := &Layered{}
+	l.layers = append(l.layers, layers...)
+	l.write = len(l.layers) - 1
+	return l
+}
+
+// Push adds a layer on top of the others. If Set and Del were writing to the
+// top layer, they write to the new one.
+func (l *Layered) Push(name string, c Configurator) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if l.write == len(l.layers)-1 {
+		l.write++
+	}
+	l.layers = append(l.layers, Layer{name, c})
+}
+
+// SetWriteLayer chooses the layer that receives Load, Set and Del calls.
+func (l *Layered) SetWriteLayer(name string) error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	for i := range l.layers {
+		if l.layers[i].Name == name {
+			l.write = i
+			return nil
+		}
+	}
+	return errs.New("confg: layer " + name + " not exist")
+}
+
+func (l *Layered) writeLayer() (Configurator, error) {
+	if l.write < 0 || l.write >= len(l.layers) {
+		return nil, ErrNoLayer
+	}
+	return l.layers[l.write].Configurator, nil
+}
+
+// Load loads path into the write layer.
+func (l *Layered) Load(path string) error {
+	l.mux.RLock()
+	defer l.mux.RUnlock()
+
+	c, err := l.writeLayer()
+	if err != nil {
+		return err
+	}
+	return c.Load(path)
+}
+
+// Close closes every layer. It returns the first error encountered.
+func (l *Layered) Close() error {
+	l.mux.RLock()
+	defer l.mux.RUnlock()
+
+	var first error
+	for i := range l.layers {
+		if err := l.layers[i].Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Set sets the value in the write layer.
+func (l *Layered) Set(k string, v interface{}) {
+	l.mux.RLock()
+	defer l.mux.RUnlock()
+
+	if c, err := l.writeLayer(); err == nil {
+		c.Set(k, v)
+	}
+}
+
+// Get returns the value from the highest layer which has k. Maps found in
+// several layers are merged, values of the higher layer win.
+func (l *Layered) Get(k string) interface{} {
+	l.mux.RLock()
+	defer l.mux.RUnlock()
+
+	var val interface{}
+	for i := range l.layers {
+		v := Lookup(l.layers[i], k)
+		if v == nil {
+			continue
+		}
+		base, ok1 := val.(map[string]interface{})
+		over, ok2 := v.(map[string]interface{})
+		if ok1 && ok2 {
+			val = merge(base, over)
+		} else if ok2 {
+			val = merge(nil, over)
+		} else {
+			val = v
+		}
+	}
+	return val
+}
+
+// Del deletes the value from the write layer. The key may still be found in
+// the other layers.
+func (l *Layered) Del(k string) {
+	l.mux.RLock()
+	defer l.mux.RUnlock()
+
+	if c, err := l.writeLayer(); err == nil {
+		c.Del(k)
+	}
+}
+
+// Source returns the name of the layer where the value of k come from, or an
+// empty string if no layer has k. For merged maps it is the highest layer
+// that has the key, use Sources to see all of them.
+func (l *Layered) Source(k string) string {
+	src := l.Sources(k)
+	if len(src) == 0 {
+		return ""
+	}
+	return src[0]
+}
+
+// Sources returns the names of all layers which have k, highest precedence
+// first.
+func (l *Layered) Sources(k string) []string {
+	l.mux.RLock()
+	defer l.mux.RUnlock()
+
+	var names []string
+	for i := len(l.layers) - 1; i >= 0; i-- {
+		if Lookup(l.layers[i], k) != nil {
+			names = append(names, l.layers[i].Name)
+		}
+	}
+	return names
+}
+
+// Keys returns the keys of all layers that are Keyer, an EnvConfg layer
+// only overrides the keys of the others.
+func (l *Layered) Keys() []string {
+	l.mux.RLock()
+	defer l.mux.RUnlock()
+
+	seen := make(map[string]bool)
+	var keys []string
+	for i := range l.layers {
+		keyer, ok := l.layers[i].Configurator.(Keyer)
+		if !ok {
+			continue
+		}
+		for _, k := range keyer.Keys() {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// merge returns a new map holding the deep merge of over into base.
+func merge(base, over map[string]interface{}) map[string]interface{} {
+	m := make(map[string]interface{}, len(base)+len(over))
+	for k, v := range base {
+		m[k] = v
+	}
+	for k, v := range over {
+		b, ok1 := m[k].(map[string]interface{})
+		o, ok2 := v.(map[string]interface{})
+		if ok1 && ok2 {
+			m[k] = merge(b, o)
+		} else if ok2 {
+			m[k] = merge(nil, o)
+		} else {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+var (
+	_ Configurator = &Layered{}
+	_ Keyer        = &Layered{}
+)
@@ -0,0 +1,61 @@
+package confg
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestLayered(t *testing.T) {
+	defaults := NewMapConfg(map[string]interface{}{
+		"name": "toys",
+		"db": map[string]interface{}{
+			"host": "localhost",
+			"port": 5432,
+		},
+	})
+	base := NewMapConfg(map[string]interface{}{
+		"db": map[string]interface{}{
+			"host": "db.example.com",
+		},
+	})
+	os.Setenv("TOYSTEST_DB_USER", "admin")
+	defer os.Unsetenv("TOYSTEST_DB_USER")
+
+	l := NewLayered(Layer{"defaults", defaults}, Layer{"base", base})
+	l.Push("env", NewEnvConfg("TOYSTEST_"))
+
+	want := map[string]interface{}{
+		"host": "db.example.com",
+		"port": 5432,
+	}
+	if got := l.Get("db"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Get(db) = %v, want %v", got, want)
+	}
+	if got := l.Get("db.host"); got != "db.example.com" {
+		t.Errorf("Get(db.host) = %v", got)
+	}
+	if got := l.Get("db.user"); got != "admin" {
+		t.Errorf("Get(db.user) = %v", got)
+	}
+	if got := l.Source("db.port"); got != "defaults" {
+		t.Errorf("Source(db.port) = %q", got)
+	}
+	if got := l.Sources("db"); !reflect.DeepEqual(got, []string{"base", "defaults"}) {
+		t.Errorf("Sources(db) = %v", got)
+	}
+
+	if err := l.SetWriteLayer("base"); err != nil {
+		t.Fatal(err)
+	}
+	l.Set("name", "app")
+	if got := base.Get("name"); got != "app" {
+		t.Errorf("Set wrote %v to base layer", got)
+	}
+	if got := l.Source("name"); got != "base" {
+		t.Errorf("Source(name) = %q", got)
+	}
+	if err := l.SetWriteLayer("flags"); err == nil {
+		t.Error("SetWriteLayer accepted an unknown layer")
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package confg
+
+import (
+	"os"
+	"strings"
+	"sync"
+)
+
+// MapConfg is an in-memory Configurator, mostly use as the defaults layer of
+// a Layered. Nested maps can be reached with dotted keys.
+type MapConfg struct {
+	data Tree
+	mux  sync.RWMutex
+}
+
+// NewMapConfg returns a MapConfg holding a copy of m.
+func NewMapConfg(m map[string]interface{}) *MapConfg {
+	c := &MapConfg{}
+	c.data = merge(nil, m)
+	return c
+}
+
+// Load is not supported by MapConfg.
+func (c *MapConfg) Load(path string) error {
+	return ErrNotSupported
+}
+
+func (c *MapConfg) Close() error {
+	return nil
+}
+
+func (c *MapConfg) Set(k string, v interface{}) {
+	c.mux.Lock()
+	c.data.Set(k, v)
+	c.mux.Unlock()
+}
+
+func (c *MapConfg) Get(k string) interface{} {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return c.data.Get(k)
+}
+
+func (c *MapConfg) Del(k string) {
+	c.mux.Lock()
+	c.data.Del(k)
+	c.mux.Unlock()
+}
+
+func (c *MapConfg) Keys() []string {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return c.data.Keys()
+}
+
+// EnvConfg reads the configuration from environment variables. The key
+// "db.host" with the prefix "APP_" is read from APP_DB_HOST. EnvConfg is not
+// a Keyer, the name APP_MAX_CONNS cannot tell max.conns from max_conns.
+type EnvConfg struct {
+	prefix string
+}
+
+// NewEnvConfg returns an EnvConfg with the given variable name prefix.
+func NewEnvConfg(prefix string) *EnvConfg {
+	return &EnvConfg{prefix}
+}
+
+func (c *EnvConfg) envName(k string) string {
+	r := strings.NewReplacer(".", "_", "-", "_")
+	return c.prefix + strings.ToUpper(r.Replace(k))
+}
+
+// Load is not supported by EnvConfg.
+func (c *EnvConfg) Load(path string) error {
+	return ErrNotSupported
+}
+
+func (c *EnvConfg) Close() error {
+	return nil
+}
+
+// Set sets the environment variable of the current process.
+func (c *EnvConfg) Set(k string, v interface{}) {
+	os.Setenv(c.envName(k), toString(v))
+}
+
+// Get returns the variable as a string, or nil if it is not set.
+func (c *EnvConfg) Get(k string) interface{} {
+	v, ok := os.LookupEnv(c.envName(k))
+	if !ok {
+		return nil
+	}
+	return v
+}
+
+func (c *EnvConfg) Del(k string) {
+	os.Unsetenv(c.envName(k))
+}
+
+var (
+	_ Configurator = &MapConfg{}
+	_ Configurator = &EnvConfg{}
+	_ Keyer        = &MapConfg{}
+)
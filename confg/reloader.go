@@ -0,0 +1,214 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package confg
+
+import (
+	"github.com/howeyc/fsnotify"
+	"github.com/kidstuff/toys/util/errs"
+	"log"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// reloadDelay is the time a Reloader waits after the last event of the file
+// before reloading it, so a file being written is read once complete.
+var reloadDelay = 100 * time.Millisecond
+
+// WatchFunc is called by a Reloader when the value of a watched key changed.
+// old or new is nil if the key was added or removed.
+type WatchFunc func(old, new interface{})
+
+// Reloader wraps a Configurator and reloads it when the loaded file changed.
+// The events of the file are coalesced, it is reloaded once no event came for
+// 100 ms. If the new file cannot be loaded the old values are kept and the
+// error is logged. The wrapped Configurator must keep its values when Load
+// fails, like jsonconfg does.
+type Reloader struct {
+	c       Configurator
+	path    string
+	watcher *fsnotify.Watcher
+	subs    map[string][]WatchFunc
+	mux     struct {
+		data   sync.RWMutex
+		subs   sync.Mutex
+		reload sync.Mutex
+	}
+}
+
+// NewReloader loads path into c and starts watching the file for changes.
+func NewReloader(c Configurator, path string) (*Reloader, error) {
+	r, err := newReloader(c, path)
+	if err != nil {
+		return nil, err
+	}
+
+	r.watcher, err = fsnotify.NewWatcher()
+	if err != nil {
+		return nil, errs.Err(err, "confg: error trying watching config file")
+	}
+	// watch the folder, many editors replace the file rather than write it
+	err = r.watcher.Watch(filepath.Dir(r.path))
+	if err != nil {
+		r.watcher.Close()
+		return nil, errs.Err(err, "confg: error trying watching config file")
+	}
+	go r.watch(r.watcher.Event, r.watcher.Error)
+	return r, nil
+}
+
+// newReloader loads path into c, the returned Reloader does not watch it.
+func newReloader(c Configurator, path string) (*Reloader, error) {
+	r := &Reloader{}
+	r.c = c
+	r.path = filepath.Clean(path)
+	r.subs = make(map[string][]WatchFunc)
+
+	if err := c.Load(r.path); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// watch reloads the file reloadDelay after the last of its events, until
+// the channels are closed.
+func (r *Reloader) watch(events <-chan *fsnotify.FileEvent, errc <-chan error) {
+	var fire <-chan time.Time
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			r.mux.data.RLock()
+			path := r.path
+			r.mux.data.RUnlock()
+			if filepath.Clean(ev.Name) == path && !ev.IsDelete() {
+				fire = time.After(reloadDelay)
+			}
+		case <-fire:
+			fire = nil
+			if err := r.Reload(); err != nil {
+				r.mux.data.RLock()
+				path := r.path
+				r.mux.data.RUnlock()
+				log.Printf("confg: keep the old values of %s\n%s", path, err.Error())
+			}
+		case err, ok := <-errc:
+			if !ok {
+				return
+			}
+			log.Printf("confg: error watching config file\n%s", err.Error())
+		}
+	}
+}
+
+// Reload loads the file again and calls the WatchFunc of the changed keys.
+// The Reloads are serialized, a WatchFunc must not call Reload.
+func (r *Reloader) Reload() error {
+	r.mux.reload.Lock()
+	defer r.mux.reload.Unlock()
+
+	r.mux.subs.Lock()
+	subs := make(map[string][]WatchFunc, len(r.subs))
+	for k, fns := range r.subs {
+		subs[k] = fns
+	}
+	r.mux.subs.Unlock()
+
+	old := make(map[string]interface{}, len(subs))
+	r.mux.data.Lock()
+	for k := range subs {
+		old[k] = Lookup(r.c, k)
+	}
+	err := r.c.Load(r.path)
+	r.mux.data.Unlock()
+	if err != nil {
+		return err
+	}
+
+	r.mux.data.RLock()
+	changed := make(map[string]interface{})
+	for k, v := range old {
+		if nv := Lookup(r.c, k); !reflect.DeepEqual(v, nv) {
+			changed[k] = nv
+		}
+	}
+	r.mux.data.RUnlock()
+
+	for k, nv := range changed {
+		for _, fn := range subs[k] {
+			fn(old[k], nv)
+		}
+	}
+	return nil
+}
+
+// Watch registers fn to be called each time the value of k changed after a
+// reload. k can be a dotted key, see Lookup.
+func (r *Reloader) Watch(k string, fn WatchFunc) {
+	r.mux.subs.Lock()
+	r.subs[k] = append(r.subs[k], fn)
+	r.mux.subs.Unlock()
+}
+
+// Load changes the watched file to path and loads it.
+func (r *Reloader) Load(path string) error {
+	path = filepath.Clean(path)
+	r.mux.data.Lock()
+	err := r.c.Load(path)
+	if err == nil && r.watcher != nil && filepath.Dir(path) != filepath.Dir(r.path) {
+		r.watcher.RemoveWatch(filepath.Dir(r.path))
+		err = r.watcher.Watch(filepath.Dir(path))
+	}
+	if err == nil {
+		r.path = path
+	}
+	r.mux.data.Unlock()
+	return err
+}
+
+// Close stops watching and closes the underlying Configurator.
+func (r *Reloader) Close() error {
+	if r.watcher != nil {
+		r.watcher.Close()
+	}
+	r.mux.data.Lock()
+	defer r.mux.data.Unlock()
+	return r.c.Close()
+}
+
+func (r *Reloader) Set(k string, v interface{}) {
+	r.mux.data.Lock()
+	r.c.Set(k, v)
+	r.mux.data.Unlock()
+}
+
+func (r *Reloader) Get(k string) interface{} {
+	r.mux.data.RLock()
+	defer r.mux.data.RUnlock()
+	return r.c.Get(k)
+}
+
+func (r *Reloader) Del(k string) {
+	r.mux.data.Lock()
+	r.c.Del(k)
+	r.mux.data.Unlock()
+}
+
+// Keys returns the keys of the underlying Configurator if it is a Keyer.
+func (r *Reloader) Keys() []string {
+	r.mux.data.RLock()
+	defer r.mux.data.RUnlock()
+	if keyer, ok := r.c.(Keyer); ok {
+		return keyer.Keys()
+	}
+	return nil
+}
+
+var (
+	_ Configurator = &Reloader{}
+	_ Keyer        = &Reloader{}
+)
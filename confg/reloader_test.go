@@ -0,0 +1,152 @@
+package confg
+
+import (
+	"errors"
+	"github.com/howeyc/fsnotify"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fileConfg loads "key=value" lines and keeps its values if a line has no =.
+type fileConfg struct {
+	*MapConfg
+}
+
+func (c *fileConfg) Load(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	m := make(map[string]interface{})
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return errors.New("fileConfg: bad line " + line)
+		}
+		m[kv[0]] = kv[1]
+	}
+	c.MapConfg = NewMapConfg(m)
+	return nil
+}
+
+func TestReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "confg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.conf")
+	write := func(s string) {
+		if err := ioutil.WriteFile(path, []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("name=toys\nport=1\n")
+	// no watcher, only the Reload calls of the test reload the file
+	r, err := newReloader(&fileConfg{NewMapConfg(nil)}, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	var calls []string
+	for _, k := range []string{"name", "port", "host"} {
+		k := k
+		r.Watch(k, func(old, new interface{}) {
+			calls = append(calls, k)
+			if k == "port" && (old != "1" || new != "2") {
+				t.Errorf("port changed from %v to %v", old, new)
+			}
+			if k == "host" && (old != nil || new != "localhost") {
+				t.Errorf("host changed from %v to %v", old, new)
+			}
+		})
+	}
+
+	write("name=toys\nport=2\nhost=localhost\n")
+	if err = r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(calls, ",") != "host,port" && strings.Join(calls, ",") != "port,host" {
+		t.Errorf("WatchFunc called for %v, want host and port", calls)
+	}
+
+	calls = nil
+	write("name=gopher\nbroken\n")
+	if err = r.Reload(); err == nil {
+		t.Error("want error for a file that cannot be parsed")
+	}
+	if r.Get("name") != "toys" || r.Get("port") != "2" {
+		t.Errorf("old values not kept: name=%v port=%v", r.Get("name"), r.Get("port"))
+	}
+	if len(calls) != 0 {
+		t.Errorf("WatchFunc called for %v after a failed reload", calls)
+	}
+}
+
+func TestReloaderEvents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "confg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.conf")
+	if err = ioutil.WriteFile(path, []byte("port=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := newReloader(&fileConfg{NewMapConfg(nil)}, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mux   sync.Mutex
+		calls []interface{}
+		done  = make(chan bool, 10)
+	)
+	r.Watch("port", func(old, new interface{}) {
+		mux.Lock()
+		calls = append(calls, new)
+		mux.Unlock()
+		done <- true
+	})
+
+	events := make(chan *fsnotify.FileEvent)
+	errc := make(chan error)
+	go r.watch(events, errc)
+	defer close(events)
+
+	// the file is written in steps, "port=" alone is a valid file
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pause := func() { time.Sleep(reloadDelay / 4) }
+	events <- &fsnotify.FileEvent{Name: path}
+	pause()
+	f.WriteString("port=")
+	events <- &fsnotify.FileEvent{Name: path}
+	pause()
+	f.WriteString("2\n")
+	f.Close()
+	events <- &fsnotify.FileEvent{Name: filepath.Join(dir, "other.conf")}
+	events <- &fsnotify.FileEvent{Name: path}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload after the events")
+	}
+	time.Sleep(2 * reloadDelay)
+	mux.Lock()
+	defer mux.Unlock()
+	if len(calls) != 1 || calls[0] != "2" {
+		t.Errorf("WatchFunc called with %v, want a single 2", calls)
+	}
+}
@@ -0,0 +1,353 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package confg
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kidstuff/toys/util/errs"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Keyer is implemented by the Configurators which can list their keys. The
+// keys of nested maps are listed as dotted keys.
+type Keyer interface {
+	Keys() []string
+}
+
+// Type is the type of a value in a Schema.
+type Type string
+
+const (
+	Any      Type = ""
+	String   Type = "string"
+	Int      Type = "int"
+	Float    Type = "float"
+	Bool     Type = "bool"
+	Duration Type = "duration"
+	List     Type = "list"
+	Map      Type = "map"
+)
+
+// KeyPolicy tells what a Schema does with the keys it does not declare.
+type KeyPolicy string
+
+const (
+	AllowUnknown  KeyPolicy = "allow"
+	RejectUnknown KeyPolicy = "reject"
+)
+
+// Field declares a key of a Schema. Min and Max limit the value of numbers
+// and the length of strings and lists. A Field of type Map or Any also
+// declares all the keys under it.
+type Field struct {
+	Key      string        `json:"key"`
+	Type     Type          `json:"type,omitempty"`
+	Required bool          `json:"required,omitempty"`
+	Default  interface{}   `json:"default,omitempty"`
+	Min      *float64      `json:"min,omitempty"`
+	Max      *float64      `json:"max,omitempty"`
+	Enum     []interface{} `json:"enum,omitempty"`
+	Help     string        `json:"help,omitempty"`
+}
+
+// Schema declares the keys a Configurator should have. The zero Unknown
+// policy allows unknown keys.
+type Schema struct {
+	Fields  []Field   `json:"fields"`
+	Unknown KeyPolicy `json:"unknown,omitempty"`
+}
+
+// Violation is a problem found by Schema.Validate.
+type Violation struct {
+	Key string
+	Msg string
+}
+
+// ValidationError lists every Violation found by Schema.Validate.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		lines[i] = "confg: " + v.Key + ": " + v.Msg
+	}
+	return strings.Join(lines, "\n")
+}
+
+// LoadSchema decodes a Schema in JSON format from r, see Schema.Check.
+func LoadSchema(r io.Reader) (*Schema, error) {
+	s := &Schema{}
+	if err := json.NewDecoder(r).Decode(s); err != nil {
+		return nil, errs.Err(err, "confg: cannot decode schema")
+	}
+	if err := s.Check(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Check returns an error if a Field has an unknown type, or a Min or Max
+// while its type has no size: Any, Bool or Map.
+func (s *Schema) Check() error {
+	for _, f := range s.Fields {
+		switch f.Type {
+		case String, Int, Float, Duration, List:
+		case Any, Bool, Map:
+			if f.Min != nil || f.Max != nil {
+				typ := string(f.Type)
+				if typ == "" {
+					typ = "any"
+				}
+				return fmt.Errorf("confg: schema key %s: min and max cannot limit a value of type %s", f.Key, typ)
+			}
+		default:
+			return fmt.Errorf("confg: schema key %s: unknown type %q", f.Key, f.Type)
+		}
+	}
+	switch s.Unknown {
+	case "", AllowUnknown, RejectUnknown:
+	default:
+		return fmt.Errorf("confg: schema: unknown key policy %q", s.Unknown)
+	}
+	return nil
+}
+
+// Field returns the Field declared for k, or nil.
+func (s *Schema) Field(k string) *Field {
+	for i := range s.Fields {
+		if s.Fields[i].Key == k {
+			return &s.Fields[i]
+		}
+	}
+	return nil
+}
+
+// declared reports whether k is a key of s or lives under a Map field.
+func (s *Schema) declared(k string) bool {
+	for _, f := range s.Fields {
+		if f.Key == k || (f.Type == Map || f.Type == Any) && strings.HasPrefix(k, f.Key+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks c against the schema and returns a *ValidationError holding
+// every violation, or nil. Unknown keys are only found if c is a Keyer. An
+// invalid schema gives the error of Check.
+func (s *Schema) Validate(c Configurator) error {
+	if err := s.Check(); err != nil {
+		return err
+	}
+	var vs []Violation
+	for _, f := range s.Fields {
+		v := Lookup(c, f.Key)
+		if v == nil {
+			if f.Required {
+				vs = append(vs, Violation{f.Key, "required key is missing"})
+			}
+			continue
+		}
+		if msg := f.check(v); msg != "" {
+			vs = append(vs, Violation{f.Key, msg})
+		}
+	}
+
+	if keyer, ok := c.(Keyer); ok && s.Unknown == RejectUnknown {
+		for _, k := range keyer.Keys() {
+			if !s.declared(k) {
+				vs = append(vs, Violation{k, "unknown key"})
+			}
+		}
+	}
+
+	if len(vs) > 0 {
+		return &ValidationError{vs}
+	}
+	return nil
+}
+
+// check returns the reason v does not match f, or an empty string.
+func (f *Field) check(v interface{}) string {
+	var size float64
+	switch f.Type {
+	case String:
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Sprintf("want string, got %T", v)
+		}
+		size = float64(len(s))
+	case Int:
+		n, ok := toFloat(v)
+		if !ok || n != float64(int64(n)) {
+			return fmt.Sprintf("want int, got %#v", v)
+		}
+		size = n
+	case Float:
+		n, ok := toFloat(v)
+		if !ok {
+			return fmt.Sprintf("want float, got %#v", v)
+		}
+		size = n
+	case Bool:
+		if !isBool(v) {
+			return fmt.Sprintf("want bool, got %#v", v)
+		}
+	case Duration:
+		d, ok := toDuration(v)
+		if !ok {
+			return fmt.Sprintf("want duration, got %#v", v)
+		}
+		size = d.Seconds()
+	case List:
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return fmt.Sprintf("want list, got %T", v)
+		}
+		size = float64(rv.Len())
+	case Map:
+		if _, ok := v.(map[string]interface{}); !ok {
+			return fmt.Sprintf("want map, got %T", v)
+		}
+	}
+
+	if f.Min != nil && size < *f.Min {
+		return fmt.Sprintf("%v is less than the minimum %v", v, *f.Min)
+	}
+	if f.Max != nil && size > *f.Max {
+		return fmt.Sprintf("%v is greater than the maximum %v", v, *f.Max)
+	}
+	if len(f.Enum) > 0 {
+		for _, e := range f.Enum {
+			if equal(e, v) {
+				return ""
+			}
+		}
+		return fmt.Sprintf("%v is not one of %v", v, f.Enum)
+	}
+	return ""
+}
+
+// toFloat converts numbers and numeric strings, as found in the environment
+// variables, to float64.
+func toFloat(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	case reflect.String:
+		f, err := strconv.ParseFloat(rv.String(), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func isBool(v interface{}) bool {
+	switch b := v.(type) {
+	case bool:
+		return true
+	case string:
+		_, err := strconv.ParseBool(b)
+		return err == nil
+	}
+	return false
+}
+
+func toDuration(v interface{}) (time.Duration, bool) {
+	switch d := v.(type) {
+	case time.Duration:
+		return d, true
+	case string:
+		pd, err := time.ParseDuration(d)
+		return pd, err == nil
+	}
+	return 0, false
+}
+
+// equal compares v with an enum item, numbers are compared by value.
+func equal(e, v interface{}) bool {
+	if reflect.DeepEqual(e, v) {
+		return true
+	}
+	fe, ok1 := toFloat(e)
+	fv, ok2 := toFloat(v)
+	_, str1 := e.(string)
+	_, str2 := v.(string)
+	return ok1 && ok2 && !str1 && !str2 && fe == fv
+}
+
+// Defaults returns a MapConfg holding the default values of the schema, use
+// it as the lowest layer of a Layered.
+func (s *Schema) Defaults() *MapConfg {
+	m := make(map[string]interface{})
+	for _, f := range s.Fields {
+		if f.Default != nil {
+			m[f.Key] = f.Default
+		}
+	}
+	return NewMapConfg(m)
+}
+
+// WriteDoc writes the schema as a reference documentation.
+func (s *Schema) WriteDoc(w io.Writer) error {
+	fields := append([]Field(nil), s.Fields...)
+	sort.Sort(byKey(fields))
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tTYPE\tDEFAULT\tDESCRIPTION")
+	for _, f := range fields {
+		typ := string(f.Type)
+		if typ == "" {
+			typ = "any"
+		}
+		def := "-"
+		if f.Required {
+			def = "(required)"
+		}
+		if f.Default != nil {
+			b, _ := json.Marshal(f.Default)
+			def = string(b)
+		}
+
+		var notes []string
+		if f.Min != nil {
+			notes = append(notes, fmt.Sprintf("min %v", *f.Min))
+		}
+		if f.Max != nil {
+			notes = append(notes, fmt.Sprintf("max %v", *f.Max))
+		}
+		if len(f.Enum) > 0 {
+			b, _ := json.Marshal(f.Enum)
+			notes = append(notes, "one of "+string(b))
+		}
+		help := f.Help
+		if len(notes) > 0 {
+			help = strings.TrimSpace(help + " (" + strings.Join(notes, ", ") + ")")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", f.Key, typ, def, help)
+	}
+	if s.Unknown == RejectUnknown {
+		fmt.Fprintln(tw, "\nOther keys are not allowed.")
+	}
+	return tw.Flush()
+}
+
+type byKey []Field
+
+func (f byKey) Len() int           { return len(f) }
+func (f byKey) Less(i, j int) bool { return f[i].Key < f[j].Key }
+func (f byKey) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
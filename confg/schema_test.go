@@ -0,0 +1,145 @@
+package confg
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func limit(f float64) *float64 {
+	return &f
+}
+
+func TestSchemaValidate(t *testing.T) {
+	s, err := LoadSchema(strings.NewReader(`{
+		"unknown": "reject",
+		"fields": [
+			{"key": "name", "type": "string", "required": true},
+			{"key": "db.port", "type": "int", "min": 1, "max": 65535, "default": 5432},
+			{"key": "db.ssl", "type": "bool"},
+			{"key": "log.level", "type": "string", "enum": ["debug", "info", "error"]},
+			{"key": "timeout", "type": "duration", "help": "Request timeout."},
+			{"key": "extra", "type": "map"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	good := NewMapConfg(map[string]interface{}{
+		"name": "toys",
+		"db": map[string]interface{}{
+			"port": 5432.0,
+			"ssl":  "true",
+		},
+		"log.level": "info",
+		"timeout":   "30s",
+		"extra":     map[string]interface{}{"anything": 1},
+	})
+	if err := s.Validate(good); err != nil {
+		t.Errorf("Validate(good) = %v", err)
+	}
+
+	bad := NewMapConfg(map[string]interface{}{
+		"db.port":   int64(70000),
+		"db.ssl":    1,
+		"log.level": "trace",
+		"timeout":   "soon",
+		"nmae":      "typo",
+	})
+	err = s.Validate(bad)
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Validate(bad) = %v, want *ValidationError", err)
+	}
+	want := []string{"name", "db.port", "db.ssl", "log.level", "timeout", "nmae"}
+	if len(verr.Violations) != len(want) {
+		t.Fatalf("got violations:\n%v", err)
+	}
+	for i, k := range want {
+		if verr.Violations[i].Key != k {
+			t.Errorf("violation %d is for %s, want %s", i, verr.Violations[i].Key, k)
+		}
+	}
+
+	if got := s.Defaults().Get("db.port"); got != 5432.0 {
+		t.Errorf("default db.port = %v", got)
+	}
+
+	s.Fields[0].Max = limit(2)
+	if err := s.Validate(good); err == nil {
+		t.Error("Validate accepted a string longer than Max")
+	}
+
+	var doc bytes.Buffer
+	if err := s.WriteDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	for _, part := range []string{"db.port", "5432", "(required)", "Request timeout.", `one of ["debug","info","error"]`} {
+		if !strings.Contains(doc.String(), part) {
+			t.Errorf("WriteDoc output has no %q:\n%s", part, doc.String())
+		}
+	}
+}
+
+func TestSchemaCheck(t *testing.T) {
+	for _, js := range []string{
+		`{"fields": [{"key": "any", "min": 1}]}`,
+		`{"fields": [{"key": "on", "type": "bool", "max": 1}]}`,
+		`{"fields": [{"key": "db", "type": "map", "min": 1}]}`,
+		`{"fields": [{"key": "n", "type": "integer"}]}`,
+		`{"fields": [], "unknown": "ignore"}`,
+	} {
+		if _, err := LoadSchema(strings.NewReader(js)); err == nil {
+			t.Errorf("LoadSchema(%s) accepted an invalid schema", js)
+		}
+	}
+
+	s := &Schema{Fields: []Field{{Key: "any", Max: limit(3)}}}
+	if err := s.Validate(NewMapConfg(nil)); err == nil {
+		t.Error("Validate accepted an invalid schema")
+	}
+}
+
+func TestOpenSchema(t *testing.T) {
+	s := &Schema{Fields: []Field{
+		{Key: "name", Type: String, Required: true},
+		{Key: "mode", Type: String, Enum: []interface{}{"dev", "prod"}},
+	}}
+	fsys := fstest.MapFS{
+		"good.conf": {Data: []byte("name=toys\nmode=dev\n")},
+		"bad.conf":  {Data: []byte("mode=test\n")},
+	}
+	if _, err := OpenFS("testconfg", fsys, "good.conf", s); err != nil {
+		t.Errorf("OpenFS(good) = %v", err)
+	}
+	_, err := OpenFS("testconfg", fsys, "bad.conf", s)
+	verr, ok := err.(*ValidationError)
+	if !ok || len(verr.Violations) != 2 {
+		t.Errorf("OpenFS(bad) = %v, want 2 violations", err)
+	}
+	if _, err = Open("testconfg", "a.conf", s); err == nil {
+		t.Error("Open did not validate with the schema")
+	}
+}
+
+func TestSchemaValidateEnv(t *testing.T) {
+	os.Setenv("TOYSTEST_MAX_CONNS", "20")
+	os.Setenv("TOYSTEST_ENGINE_MODE", "fast")
+	defer os.Unsetenv("TOYSTEST_MAX_CONNS")
+	defer os.Unsetenv("TOYSTEST_ENGINE_MODE")
+
+	s := &Schema{Unknown: RejectUnknown, Fields: []Field{
+		{Key: "name", Type: String},
+		{Key: "max_conns", Type: Int, Max: limit(10)},
+	}}
+	l := NewLayered(Layer{"file", NewMapConfg(map[string]interface{}{"name": "toys"})})
+	l.Push("env", NewEnvConfg("TOYSTEST_"))
+	err := s.Validate(l)
+	verr, ok := err.(*ValidationError)
+	if !ok || len(verr.Violations) != 1 || verr.Violations[0].Key != "max_conns" {
+		t.Errorf("Validate = %v, want only max_conns over the maximum", err)
+	}
+}
@@ -0,0 +1,397 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package confg
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"github.com/kidstuff/toys/secure"
+	"github.com/kidstuff/toys/util/errs"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// SecretPrefix marks an encrypted value in a configuration file.
+const SecretPrefix = "enc:"
+
+// KeyEnv is the default environment variable holding the secret key.
+const KeyEnv = "TOYS_CONFG_KEY"
+
+var (
+	keySource func() ([]byte, error)
+	keyMux    sync.RWMutex
+)
+
+var (
+	ErrInvalidKey    = errs.New("confg: secret key must be 16, 24 or 32 bytes")
+	ErrInvalidSecret = errs.New("confg: invalid encrypted value")
+	ErrNoKey         = errs.New("confg: no secret key found")
+)
+
+// Saver is implemented by the Configurators which can write the settings
+// back to the loaded file, or to a new file with SaveAs.
+type Saver interface {
+	Save() error
+	SaveAs(path string) error
+}
+
+// NewKey returns a new random 32 bytes key, encoded in base64 like LoadKey
+// expects.
+func NewKey() string {
+	return base64.StdEncoding.EncodeToString(secure.RandomToken(32))
+}
+
+// LoadKey reads a base64 encoded key from the environment variable env, or
+// from file if the variable is not set. Empty names are skipped.
+func LoadKey(env, file string) ([]byte, error) {
+	var text string
+	if v := os.Getenv(env); env != "" && v != "" {
+		text = v
+	} else if file != "" {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, errs.Err(err, "confg: cannot read key file "+file)
+		}
+		text = string(b)
+	} else {
+		return nil, ErrNoKey
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(text))
+	if err != nil {
+		return nil, errs.Err(err, "confg: secret key is not base64 encoded")
+	}
+	if _, err := aes.NewCipher(key); err != nil {
+		return nil, ErrInvalidKey
+	}
+	return key, nil
+}
+
+// SetKeySource makes Open, OpenReader and OpenFS wrap the Configurators they
+// return in a SecretConfg, decrypting with the key returned by load. Open
+// fails if load does. A nil load, the default, turns it off. To read the key
+// from the TOYS_CONFG_KEY variable:
+//
+//	confg.SetKeySource(func() ([]byte, error) {
+//		return confg.LoadKey(confg.KeyEnv, "")
+//	})
+func SetKeySource(load func() ([]byte, error)) {
+	keyMux.Lock()
+	keySource = load
+	keyMux.Unlock()
+}
+
+// withSecrets wraps c in a SecretConfg if a key source is set.
+func withSecrets(c Configurator) (Configurator, error) {
+	keyMux.RLock()
+	load := keySource
+	keyMux.RUnlock()
+	if load == nil {
+		return c, nil
+	}
+	key, err := load()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = aes.NewCipher(key); err != nil {
+		return nil, ErrInvalidKey
+	}
+	return NewSecretConfg(c, key), nil
+}
+
+// IsSecret reports whether v is an encrypted value.
+func IsSecret(v interface{}) bool {
+	s, ok := v.(string)
+	return ok && strings.HasPrefix(s, SecretPrefix)
+}
+
+// Encrypt encrypts plain with AES-GCM and returns it as "enc:" followed by
+// the base64 encoded nonce and cipher text.
+func Encrypt(key []byte, plain string) (string, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := secure.RandomToken(uint(aead.NonceSize()))
+	if nonce == nil {
+		return "", errs.New("confg: cannot generate nonce")
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plain), nil)
+	return SecretPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a value returned by Encrypt.
+func Decrypt(key []byte, secret string) (string, error) {
+	if !strings.HasPrefix(secret, SecretPrefix) {
+		return "", ErrInvalidSecret
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(secret[len(SecretPrefix):])
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrInvalidSecret
+	}
+	n := aead.NonceSize()
+	plain, err := aead.Open(nil, sealed[:n], sealed[n:], nil)
+	if err != nil {
+		return "", errs.Err(err, "confg: cannot decrypt value, wrong key?")
+	}
+	return string(plain), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	return cipher.NewGCM(block)
+}
+
+// SecretConfg wraps a Configurator and decrypts the encrypted values on Get,
+// values in nested maps and lists included. A value which cannot be decrypted
+// is logged and read as nil, GetSecret returns the error.
+type SecretConfg struct {
+	c   Configurator
+	key []byte
+	mux sync.RWMutex
+}
+
+// NewSecretConfg returns a SecretConfg decrypting the values of c with key.
+func NewSecretConfg(c Configurator, key []byte) *SecretConfg {
+	return &SecretConfg{c: c, key: key}
+}
+
+func (s *SecretConfg) Load(path string) error {
+	return s.c.Load(path)
+}
+
+func (s *SecretConfg) Close() error {
+	return s.c.Close()
+}
+
+// Set sets a plain value, use SetSecret to store it encrypted.
+func (s *SecretConfg) Set(k string, v interface{}) {
+	s.c.Set(k, v)
+}
+
+// SetSecret encrypts v and sets it.
+func (s *SecretConfg) SetSecret(k, v string) error {
+	s.mux.RLock()
+	enc, err := Encrypt(s.key, v)
+	s.mux.RUnlock()
+	if err != nil {
+		return err
+	}
+	s.c.Set(k, enc)
+	return nil
+}
+
+func (s *SecretConfg) Get(k string) interface{} {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	v, err := decryptValue(s.key, Lookup(s.c, k))
+	if err != nil {
+		log.Printf("confg: cannot read secret %s\n%s", k, err.Error())
+		return nil
+	}
+	return v
+}
+
+// GetSecret returns the string value of k, decrypted if it is encrypted. It
+// returns an error if the value is missing, is not a string or cannot be
+// decrypted, like with a wrong key.
+func (s *SecretConfg) GetSecret(k string) (string, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	v := Lookup(s.c, k)
+	str, ok := v.(string)
+	switch {
+	case v == nil:
+		return "", errs.New("confg: no value for " + k)
+	case !ok:
+		return "", errs.New("confg: " + k + " is not a string value")
+	case !IsSecret(str):
+		return str, nil
+	}
+	plain, err := Decrypt(s.key, str)
+	if err != nil {
+		return "", errs.Err(err, "confg: cannot read secret "+k)
+	}
+	return plain, nil
+}
+
+func (s *SecretConfg) Del(k string) {
+	s.c.Del(k)
+}
+
+// Keys returns the keys of the underlying Configurator if it is a Keyer.
+func (s *SecretConfg) Keys() []string {
+	if keyer, ok := s.c.(Keyer); ok {
+		return keyer.Keys()
+	}
+	return nil
+}
+
+// Save saves the underlying Configurator if it is a Saver. The encrypted
+// values stay encrypted.
+func (s *SecretConfg) Save() error {
+	if saver, ok := s.c.(Saver); ok {
+		return saver.Save()
+	}
+	return ErrNotSupported
+}
+
+// SaveAs likes Save but writes to path.
+func (s *SecretConfg) SaveAs(path string) error {
+	if saver, ok := s.c.(Saver); ok {
+		return saver.SaveAs(path)
+	}
+	return ErrNotSupported
+}
+
+// Rotate re-encrypts every encrypted value of the underlying Configurator
+// with newKey and uses it from now on. Call Save to write the change.
+func (s *SecretConfg) Rotate(newKey []byte) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if err := Rotate(s.c, s.key, newKey); err != nil {
+		return err
+	}
+	s.key = newKey
+	return nil
+}
+
+func decryptValue(key []byte, v interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case string:
+		if IsSecret(x) {
+			return Decrypt(key, x)
+		}
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, item := range x {
+			dv, err := decryptValue(key, item)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = dv
+		}
+		return m, nil
+	case []interface{}:
+		a := make([]interface{}, len(x))
+		for i, item := range x {
+			dv, err := decryptValue(key, item)
+			if err != nil {
+				return nil, err
+			}
+			a[i] = dv
+		}
+		return a, nil
+	}
+	return v, nil
+}
+
+// EncryptKeys encrypts the plain string values of the given keys in c. The
+// values already encrypted are left as they are. Call Save to write the
+// change.
+func EncryptKeys(c Configurator, key []byte, keys ...string) error {
+	for _, k := range keys {
+		v := Lookup(c, k)
+		s, ok := v.(string)
+		if !ok {
+			return errs.New("confg: " + k + " is not a string value")
+		}
+		if IsSecret(s) {
+			continue
+		}
+		enc, err := Encrypt(key, s)
+		if err != nil {
+			return err
+		}
+		c.Set(k, enc)
+	}
+	return nil
+}
+
+// Rotate re-encrypts every encrypted value of c from oldKey to newKey, the
+// ones inside lists and maps included. c must be a Keyer. Nothing is changed
+// if a value cannot be decrypted.
+func Rotate(c Configurator, oldKey, newKey []byte) error {
+	keyer, ok := c.(Keyer)
+	if !ok {
+		return ErrNotSupported
+	}
+
+	rotated := make(map[string]interface{})
+	for _, k := range keyer.Keys() {
+		v, changed, err := rotateValue(oldKey, newKey, Lookup(c, k))
+		if err != nil {
+			return errs.Err(err, "confg: cannot rotate "+k)
+		}
+		if changed {
+			rotated[k] = v
+		}
+	}
+	for k, v := range rotated {
+		c.Set(k, v)
+	}
+	return nil
+}
+
+// rotateValue re-encrypts the encrypted values of v like decryptValue
+// decrypts them, changed is false if v holds none.
+func rotateValue(oldKey, newKey []byte, v interface{}) (interface{}, bool, error) {
+	switch x := v.(type) {
+	case string:
+		if !IsSecret(x) {
+			return v, false, nil
+		}
+		plain, err := Decrypt(oldKey, x)
+		if err != nil {
+			return nil, false, err
+		}
+		enc, err := Encrypt(newKey, plain)
+		return enc, err == nil, err
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(x))
+		changed := false
+		for k, item := range x {
+			rv, ch, err := rotateValue(oldKey, newKey, item)
+			if err != nil {
+				return nil, false, err
+			}
+			m[k] = rv
+			changed = changed || ch
+		}
+		return m, changed, nil
+	case []interface{}:
+		a := make([]interface{}, len(x))
+		changed := false
+		for i, item := range x {
+			rv, ch, err := rotateValue(oldKey, newKey, item)
+			if err != nil {
+				return nil, false, err
+			}
+			a[i] = rv
+			changed = changed || ch
+		}
+		return a, changed, nil
+	}
+	return v, false, nil
+}
+
+var (
+	_ Configurator = &SecretConfg{}
+	_ Keyer        = &SecretConfg{}
+	_ Saver        = &SecretConfg{}
+)
@@ -0,0 +1,150 @@
+package confg
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSecretConfg(t *testing.T) {
+	key1, _ := base64.StdEncoding.DecodeString(NewKey())
+	key2, _ := base64.StdEncoding.DecodeString(NewKey())
+
+	enc, err := Encrypt(key1, "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsSecret(enc) {
+		t.Fatalf("Encrypt returned %q", enc)
+	}
+	if _, err := Decrypt(key2, enc); err == nil {
+		t.Error("Decrypt succeeded with the wrong key")
+	}
+
+	c := NewMapConfg(map[string]interface{}{
+		"smtp": map[string]interface{}{"password": enc, "host": "smtp.example.com"},
+	})
+	s := NewSecretConfg(c, key1)
+	if got := s.Get("smtp.password"); got != "hunter2" {
+		t.Errorf("Get(smtp.password) = %v", got)
+	}
+	if err := s.SetSecret("db.password", "s3cret"); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Get("db.password"); !IsSecret(got) {
+		t.Errorf("SetSecret stored %v", got)
+	}
+
+	if err := s.Rotate(key2); err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range map[string]string{"smtp.password": "hunter2", "db.password": "s3cret"} {
+		v := Lookup(c, k).(string)
+		if plain, err := Decrypt(key2, v); err != nil || plain != want {
+			t.Errorf("rotated %s = %q, %v", k, plain, err)
+		}
+		if got := s.Get(k); got != want {
+			t.Errorf("Get(%s) after Rotate = %v", k, got)
+		}
+	}
+}
+
+func TestRotateNested(t *testing.T) {
+	key1, _ := base64.StdEncoding.DecodeString(NewKey())
+	key2, _ := base64.StdEncoding.DecodeString(NewKey())
+	enc := func(plain string) string {
+		s, err := Encrypt(key1, plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	c := NewMapConfg(map[string]interface{}{
+		"pw": []interface{}{enc("a"), "plain"},
+		"servers": []interface{}{
+			map[string]interface{}{"host": "db1", "auth": map[string]interface{}{"pw": enc("b")}},
+		},
+	})
+	s := NewSecretConfg(c, key1)
+	if err := s.Rotate(key2); err != nil {
+		t.Fatal(err)
+	}
+
+	pw, ok := s.Get("pw").([]interface{})
+	if !ok || len(pw) != 2 || pw[0] != "a" || pw[1] != "plain" {
+		t.Errorf("Get(pw) after Rotate = %#v", s.Get("pw"))
+	}
+	servers, ok := s.Get("servers").([]interface{})
+	if !ok || len(servers) != 1 {
+		t.Fatalf("Get(servers) after Rotate = %#v", s.Get("servers"))
+	}
+	auth := servers[0].(map[string]interface{})["auth"].(map[string]interface{})
+	if auth["pw"] != "b" {
+		t.Errorf("nested pw after Rotate = %#v", auth["pw"])
+	}
+	if _, err := Decrypt(key2, c.Get("pw").([]interface{})[0].(string)); err != nil {
+		t.Errorf("stored pw is not encrypted with the new key: %v", err)
+	}
+}
+
+func TestSetKeySource(t *testing.T) {
+	key1, _ := base64.StdEncoding.DecodeString(NewKey())
+	key2, _ := base64.StdEncoding.DecodeString(NewKey())
+	enc, err := Encrypt(key1, "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := `<config><setting key="db.password">` + enc + `</setting></config>`
+	defer SetKeySource(nil)
+
+	c, err := OpenReader("xmlconfg", strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Get("db.password"); got != enc {
+		t.Errorf("Get without a key source = %v", got)
+	}
+
+	SetKeySource(func() ([]byte, error) { return key1, nil })
+	c, err = OpenReader("xmlconfg", strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Get("db.password"); got != "hunter2" {
+		t.Errorf("Get = %v", got)
+	}
+	s, ok := c.(*SecretConfg)
+	if !ok {
+		t.Fatalf("OpenReader returned a %T", c)
+	}
+	if got, err := s.GetSecret("db.password"); err != nil || got != "hunter2" {
+		t.Errorf("GetSecret = %q, %v", got, err)
+	}
+
+	SetKeySource(func() ([]byte, error) { return key2, nil })
+	c, err = OpenReader("xmlconfg", strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Get("db.password"); got != nil {
+		t.Errorf("Get with the wrong key = %v", got)
+	}
+	if _, err := c.(*SecretConfg).GetSecret("db.password"); err == nil {
+		t.Error("GetSecret succeeded with the wrong key")
+	}
+	if _, err := c.(*SecretConfg).GetSecret("db.host"); err == nil {
+		t.Error("GetSecret succeeded for a missing value")
+	}
+
+	errKey := errors.New("no key")
+	SetKeySource(func() ([]byte, error) { return nil, errKey })
+	if _, err = OpenReader("xmlconfg", strings.NewReader(doc)); err != errKey {
+		t.Errorf("OpenReader error = %v, want %v", err, errKey)
+	}
+	SetKeySource(func() ([]byte, error) { return []byte("short"), nil })
+	if _, err = OpenReader("xmlconfg", strings.NewReader(doc)); err != ErrInvalidKey {
+		t.Errorf("OpenReader error = %v, want %v", err, ErrInvalidKey)
+	}
+}
@@ -0,0 +1,393 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tomlconfg
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/kidstuff/toys/confg"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// encode writes data in TOML format. The statements of the loaded file are
+// kept for the unchanged keys, changed keys are rewritten in place and new
+// keys are added at the end of their table. An array of tables is written
+// again as a whole when it changed. It returns an error if a value cannot be
+// written in TOML, like a nil.
+func (doc *document) encode(data confg.Tree) ([]byte, error) {
+	for _, k := range sortedKeys(data) {
+		if err := checkValue(k, data[k]); err != nil {
+			return nil, err
+		}
+	}
+	known := make(map[string]bool)
+	owners := make(map[string]bool)
+	tables := make(map[string]bool)
+	rootKeys := make(map[string]bool)
+	// index of the statement after which the new keys of a table go, -1 is
+	// the beginning of the file
+	after := make(map[string]int)
+	rootAfter := len(doc.stmts) - 1
+	table := ""
+	for i, st := range doc.stmts {
+		if st.owner != "" {
+			owners[st.owner] = true
+			if rootAfter == len(doc.stmts)-1 && len(tables) == 0 {
+				rootAfter = -1
+			}
+			continue
+		}
+		switch st.kind {
+		case stmtTable:
+			if rootAfter == len(doc.stmts)-1 && len(tables) == 0 {
+				rootAfter = -1
+			}
+			table = st.key
+			tables[table] = true
+			if _, ok := after[table]; !ok {
+				after[table] = i
+			}
+		case stmtKeyValue:
+			known[st.key] = true
+			if table == "" {
+				rootAfter = i
+				rootKeys[strings.SplitN(st.key, ".", 2)[0]] = true
+			} else {
+				after[table] = i
+			}
+		}
+	}
+	after[""] = rootAfter
+
+	added := make(map[string][]string)
+	var newTables, newArrays []string
+	var walk func(path string, m map[string]interface{})
+	walk = func(path string, m map[string]interface{}) {
+		for _, k := range sortedKeys(m) {
+			full := join(path, k)
+			if known[full] || owners[full] {
+				continue
+			}
+			v := m[k]
+			if sub, ok := v.(map[string]interface{}); ok && (len(sub) > 0 || tables[full]) {
+				walk(full, sub)
+				continue
+			}
+			if isArrayTable(v) {
+				newArrays = append(newArrays, full)
+				continue
+			}
+
+			// find the deepest table in the file to hold the key
+			t := ""
+			for name := range tables {
+				if strings.HasPrefix(full, name+".") && len(name) > len(t) {
+					t = name
+				}
+			}
+			rel := full
+			if t != "" {
+				rel = full[len(t)+1:]
+			} else if pos := strings.LastIndex(full, "."); pos >= 0 && !rootKeys[strings.SplitN(full, ".", 2)[0]] {
+				t = full[:pos]
+				rel = full[pos+1:]
+				if added[t] == nil {
+					newTables = append(newTables, t)
+				}
+			}
+			added[t] = append(added[t], keyPath(rel)+" = "+encodeValue(v))
+		}
+	}
+	walk("", data)
+
+	var buf bytes.Buffer
+	writeLines := func(lines []string) {
+		for _, l := range lines {
+			buf.WriteString(l)
+			buf.WriteByte('\n')
+		}
+	}
+	if after[""] < 0 {
+		writeLines(added[""])
+	}
+
+	written := make(map[string]bool)
+	for i, st := range doc.stmts {
+		switch {
+		case st.owner != "":
+			v := data.Get(st.owner)
+			if reflect.DeepEqual(v, lookup(doc.orig, st.owner)) {
+				buf.WriteString(st.raw)
+			} else if !written[st.owner] && v != nil {
+				writeArrayTable(&buf, st.owner, v)
+				written[st.owner] = true
+			}
+		case st.kind == stmtComment:
+			buf.WriteString(st.raw)
+		case st.kind == stmtTable:
+			if _, ok := data.Get(st.key).(map[string]interface{}); ok {
+				buf.WriteString(st.raw)
+			}
+		case st.kind == stmtKeyValue:
+			v := data.Get(st.key)
+			if v == nil {
+				break
+			}
+			if reflect.DeepEqual(v, st.value) {
+				buf.WriteString(st.raw)
+			} else {
+				writeLines([]string{st.indent + st.keyText + " = " + encodeValue(v) + st.comment})
+			}
+		}
+		for t, pos := range after {
+			if pos == i {
+				writeLines(added[t])
+			}
+		}
+	}
+	if buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+
+	for _, t := range newTables {
+		separate(&buf)
+		writeLines([]string{"[" + keyPath(t) + "]"})
+		writeLines(added[t])
+	}
+	for _, path := range newArrays {
+		writeArrayTable(&buf, path, data.Get(path))
+	}
+	return buf.Bytes(), nil
+}
+
+// lookup returns the value at the dotted path in m.
+func lookup(m map[string]interface{}, path string) interface{} {
+	return confg.Tree(m).Get(path)
+}
+
+func isArrayTable(v interface{}) bool {
+	arr, ok := v.([]interface{})
+	if !ok || len(arr) == 0 {
+		return false
+	}
+	for _, e := range arr {
+		if _, ok := e.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func writeArrayTable(buf *bytes.Buffer, path string, v interface{}) {
+	if !isArrayTable(v) {
+		// not an array of tables anymore, write it as a plain key
+		separate(buf)
+		pos := strings.LastIndex(path, ".")
+		if pos >= 0 {
+			fmt.Fprintf(buf, "[%s]\n", keyPath(path[:pos]))
+		}
+		fmt.Fprintf(buf, "%s = %s\n", keyPath(path[pos+1:]), encodeValue(v))
+		return
+	}
+	for _, e := range v.([]interface{}) {
+		writeTable(buf, path, e.(map[string]interface{}), "[["+keyPath(path)+"]]")
+	}
+}
+
+// writeTable writes the table m at path with the given header. Sub-tables
+// and arrays of tables are written after the plain keys.
+func writeTable(buf *bytes.Buffer, path string, m map[string]interface{}, header string) {
+	separate(buf)
+	buf.WriteString(header + "\n")
+	keys := sortedKeys(m)
+	for _, k := range keys {
+		v := m[k]
+		if _, ok := v.(map[string]interface{}); ok || isArrayTable(v) {
+			continue
+		}
+		fmt.Fprintf(buf, "%s = %s\n", quoteKey(k), encodeValue(v))
+	}
+	for _, k := range keys {
+		full := join(path, k)
+		switch v := m[k].(type) {
+		case map[string]interface{}:
+			writeTable(buf, full, v, "["+keyPath(full)+"]")
+		case []interface{}:
+			if isArrayTable(v) {
+				writeArrayTable(buf, full, v)
+			}
+		}
+	}
+}
+
+// separate ends buf with a blank line, if it is not empty.
+func separate(buf *bytes.Buffer) {
+	if buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n\n")) {
+		buf.WriteByte('\n')
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func keyPath(path string) string {
+	parts := strings.Split(path, ".")
+	for i := range parts {
+		parts[i] = quoteKey(parts[i])
+	}
+	return strings.Join(parts, ".")
+}
+
+func quoteKey(k string) string {
+	if k == "" {
+		return `""`
+	}
+	for i := 0; i < len(k); i++ {
+		if !isBare(k[i]) {
+			return quote(k)
+		}
+	}
+	return k
+}
+
+func quote(s string) string {
+	var buf bytes.Buffer
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\r':
+			buf.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&buf, `\u%04X`, r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+func encodeFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+// checkValue returns an error if v, the value of k, holds a value TOML
+// cannot represent, like a nil.
+func checkValue(k string, v interface{}) error {
+	switch v.(type) {
+	case string, []byte, bool, time.Time, fmt.Stringer:
+		return nil
+	case nil:
+		return fmt.Errorf("tomlconfg: %s: cannot write a nil value", k)
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return nil
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if err := checkValue(fmt.Sprintf("%s[%d]", k, i), rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		for _, mk := range rv.MapKeys() {
+			if err := checkValue(fmt.Sprintf("%s.%v", k, mk.Interface()), rv.MapIndex(mk).Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("tomlconfg: %s: cannot write a %T value", k, v)
+}
+
+func encodeValue(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return quote(x)
+	case []byte:
+		return quote(string(x))
+	case bool:
+		return strconv.FormatBool(x)
+	case float32:
+		return encodeFloat(float64(x))
+	case float64:
+		return encodeFloat(x)
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	case map[string]interface{}:
+		if len(x) == 0 {
+			return "{}"
+		}
+		items := make([]string, 0, len(x))
+		for _, k := range sortedKeys(x) {
+			items = append(items, quoteKey(k)+" = "+encodeValue(x[k]))
+		}
+		return "{ " + strings.Join(items, ", ") + " }"
+	case fmt.Stringer:
+		return quote(x.String())
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10)
+	case reflect.Slice, reflect.Array:
+		items := make([]string, rv.Len())
+		for i := range items {
+			items[i] = encodeValue(rv.Index(i).Interface())
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case reflect.Map:
+		m := make(map[string]interface{}, rv.Len())
+		for _, k := range rv.MapKeys() {
+			m[fmt.Sprint(k.Interface())] = rv.MapIndex(k).Interface()
+		}
+		return encodeValue(m)
+	}
+	return quote(fmt.Sprint(v))
+}
@@ -0,0 +1,629 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tomlconfg
+
+import (
+	"fmt"
+	"github.com/kidstuff/toys/confg"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	stmtComment = iota // comment or blank line
+	stmtTable
+	stmtArrayTable
+	stmtKeyValue
+)
+
+// stmt is a statement of the loaded file, a key/value may span many lines.
+type stmt struct {
+	raw  string // the original text including the ending newline
+	kind int
+	key  string // full dotted key of a key/value, path of a table
+	// owner is the path of the array of tables the statement belongs to
+	owner string
+	// for key/values
+	indent  string
+	keyText string // the key as written
+	value   interface{}
+	comment string // text after the value with the spaces before it
+}
+
+// document remembers the statements of a file so it can be written back with
+// the comments in place.
+type document struct {
+	stmts []stmt
+	orig  map[string]interface{}
+}
+
+// tree returns a copy of the settings of the document.
+func (doc *document) tree() confg.Tree {
+	return deepCopy(doc.orig).(map[string]interface{})
+}
+
+func deepCopy(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, v := range x {
+			m[k] = deepCopy(v)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(x))
+		for i, v := range x {
+			a[i] = deepCopy(v)
+		}
+		return a
+	}
+	return v
+}
+
+type parser struct {
+	file string
+	src  string
+	pos  int
+
+	data    map[string]interface{}
+	cur     map[string]interface{}
+	curPath string
+	owner   string
+	// explicitly defined tables and arrays of tables by path
+	defined     map[string]bool
+	arrayTables map[string]bool
+}
+
+func parse(file, src string) (doc *document, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			serr, ok := r.(*SyntaxError)
+			if !ok {
+				panic(r)
+			}
+			doc, err = nil, serr
+		}
+	}()
+
+	p := &parser{file: file, src: src}
+	p.data = make(map[string]interface{})
+	p.cur = p.data
+	p.defined = make(map[string]bool)
+	p.arrayTables = make(map[string]bool)
+
+	doc = &document{orig: p.data}
+	for p.pos < len(p.src) {
+		doc.stmts = append(doc.stmts, p.stmt())
+	}
+	return doc, nil
+}
+
+func (p *parser) errorf(format string, a ...interface{}) {
+	line := 1 + strings.Count(p.src[:p.pos], "\n")
+	panic(&SyntaxError{p.file, line, fmt.Sprintf(format, a...)})
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *parser) expect(c byte) {
+	if p.peek() != c {
+		p.errorf("expected %q, found %s", c, p.found())
+	}
+	p.pos++
+}
+
+func (p *parser) found() string {
+	if p.eof() {
+		return "end of file"
+	}
+	r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+	return strconv.QuoteRune(r)
+}
+
+func (p *parser) skipSpace() {
+	for c := p.peek(); c == ' ' || c == '\t'; c = p.peek() {
+		p.pos++
+	}
+}
+
+func (p *parser) skipComment() {
+	if p.peek() == '#' {
+		for !p.eof() && p.src[p.pos] != '\n' {
+			p.pos++
+		}
+	}
+}
+
+// skipBlank skips spaces, newlines and comments inside arrays.
+func (p *parser) skipBlank() {
+	for {
+		p.skipSpace()
+		p.skipComment()
+		switch {
+		case p.peek() == '\n':
+			p.pos++
+		case strings.HasPrefix(p.src[p.pos:], "\r\n"):
+			p.pos += 2
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) endOfLine() {
+	switch {
+	case p.eof():
+	case p.peek() == '\n':
+		p.pos++
+	case strings.HasPrefix(p.src[p.pos:], "\r\n"):
+		p.pos += 2
+	default:
+		p.errorf("expected newline, found %s", p.found())
+	}
+}
+
+func (p *parser) stmt() stmt {
+	start := p.pos
+	p.skipSpace()
+
+	var st stmt
+	switch c := p.peek(); {
+	case c == 0 || c == '\n' || c == '\r' || c == '#':
+		st.kind = stmtComment
+		st.owner = p.owner
+	case c == '[':
+		p.header(&st)
+	default:
+		st.kind = stmtKeyValue
+		st.indent = p.src[start:p.pos]
+		st.owner = p.owner
+		p.keyValue(&st)
+	}
+
+	valueEnd := p.pos
+	p.skipSpace()
+	p.skipComment()
+	st.comment = p.src[valueEnd:p.pos]
+	p.endOfLine()
+	st.raw = p.src[start:p.pos]
+	return st
+}
+
+func (p *parser) header(st *stmt) {
+	p.pos++
+	st.kind = stmtTable
+	if p.peek() == '[' {
+		p.pos++
+		st.kind = stmtArrayTable
+	}
+	p.skipSpace()
+	keys := p.key()
+	p.skipSpace()
+	p.expect(']')
+	if st.kind == stmtArrayTable {
+		p.expect(']')
+	}
+
+	// walk down to the parent of the table
+	m := p.data
+	owner := ""
+	path := ""
+	for _, k := range keys[:len(keys)-1] {
+		path = join(path, k)
+		m = p.descend(m, k, path, true)
+		if p.arrayTables[path] && owner == "" {
+			owner = path
+		}
+	}
+
+	last := keys[len(keys)-1]
+	path = join(path, last)
+	if st.kind == stmtTable {
+		if p.defined[path] || p.arrayTables[path] {
+			p.errorf("table %s already defined", path)
+		}
+		m = p.descend(m, last, path, false)
+	} else {
+		arr, ok := m[last].([]interface{})
+		if m[last] != nil && (!ok || !p.arrayTables[path]) {
+			p.errorf("key %s already defined", path)
+		}
+		elem := make(map[string]interface{})
+		m[last] = append(arr, elem)
+		m = elem
+		p.arrayTables[path] = true
+		// the sub-tables of the previous element can be defined again
+		for t := range p.defined {
+			if strings.HasPrefix(t, path+".") {
+				delete(p.defined, t)
+			}
+		}
+		if owner == "" {
+			owner = path
+		}
+	}
+	p.defined[path] = true
+
+	p.cur = m
+	p.curPath = path
+	p.owner = owner
+	st.key = path
+	st.owner = owner
+}
+
+// descend returns the table at m[k], creating it if need. The last element
+// of an array of tables is returned if inArray is true.
+func (p *parser) descend(m map[string]interface{}, k, path string, inArray bool) map[string]interface{} {
+	switch v := m[k].(type) {
+	case nil:
+		sub := make(map[string]interface{})
+		m[k] = sub
+		return sub
+	case map[string]interface{}:
+		return v
+	case []interface{}:
+		if inArray && p.arrayTables[path] {
+			return v[len(v)-1].(map[string]interface{})
+		}
+	}
+	p.errorf("key %s already defined as a value", path)
+	return nil
+}
+
+func (p *parser) keyValue(st *stmt) {
+	keyStart := p.pos
+	keys := p.key()
+	st.keyText = strings.TrimSpace(p.src[keyStart:p.pos])
+	p.skipSpace()
+	p.expect('=')
+	p.skipSpace()
+	v := p.value()
+
+	st.key = p.set(p.cur, p.curPath, keys, v)
+	st.value = v
+}
+
+// set sets the dotted key keys inside the table m and returns the full path.
+func (p *parser) set(m map[string]interface{}, path string, keys []string, v interface{}) string {
+	for _, k := range keys[:len(keys)-1] {
+		path = join(path, k)
+		m = p.descend(m, k, path, false)
+	}
+	last := keys[len(keys)-1]
+	path = join(path, last)
+	if _, dup := m[last]; dup {
+		p.errorf("duplicate key %s", path)
+	}
+	m[last] = v
+	return path
+}
+
+func join(path, k string) string {
+	if path == "" {
+		return k
+	}
+	return path + "." + k
+}
+
+func isBare(c byte) bool {
+	return 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '_' || c == '-'
+}
+
+// key parses a dotted key.
+func (p *parser) key() []string {
+	var keys []string
+	for {
+		p.skipSpace()
+		switch c := p.peek(); {
+		case c == '"':
+			keys = append(keys, p.basicString())
+		case c == '\'':
+			keys = append(keys, p.literalString())
+		case isBare(c):
+			start := p.pos
+			for isBare(p.peek()) {
+				p.pos++
+			}
+			keys = append(keys, p.src[start:p.pos])
+		default:
+			p.errorf("expected key, found %s", p.found())
+		}
+		p.skipSpace()
+		if p.peek() != '.' {
+			return keys
+		}
+		p.pos++
+	}
+}
+
+func (p *parser) value() interface{} {
+	rest := p.src[p.pos:]
+	switch c := p.peek(); {
+	case strings.HasPrefix(rest, `"""`):
+		return p.multilineString(`"""`)
+	case c == '"':
+		return p.basicString()
+	case strings.HasPrefix(rest, "'''"):
+		return p.multilineString("'''")
+	case c == '\'':
+		return p.literalString()
+	case c == '[':
+		return p.array()
+	case c == '{':
+		return p.inlineTable()
+	case strings.HasPrefix(rest, "true") && !p.bareAt(4):
+		p.pos += 4
+		return true
+	case strings.HasPrefix(rest, "false") && !p.bareAt(5):
+		p.pos += 5
+		return false
+	case c == 0 || c == '\n' || c == '\r' || c == '#':
+		p.errorf("missing value")
+	}
+	return p.scalar()
+}
+
+// bareAt reports whether the byte n bytes ahead may continue a bare word.
+func (p *parser) bareAt(n int) bool {
+	return p.pos+n < len(p.src) && isBare(p.src[p.pos+n])
+}
+
+var (
+	dateRe    = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}`)
+	timeRe    = regexp.MustCompile(`^\d{2}:\d{2}:\d{2}(\.\d+)?$`)
+	decimalRe = regexp.MustCompile(`^[+-]?(0|[1-9](_?\d)*)$`)
+	floatRe   = regexp.MustCompile(`^[+-]?(0|[1-9](_?\d)*)(\.\d(_?\d)*)?([eE][+-]?\d(_?\d)*)?$`)
+	prefixRe  = regexp.MustCompile(`^0(x[0-9A-Fa-f](_?[0-9A-Fa-f])*|o[0-7](_?[0-7])*|b[01](_?[01])*)$`)
+)
+
+// scalar parses numbers and date-times.
+func (p *parser) scalar() interface{} {
+	start := p.pos
+	for c := p.peek(); isBare(c) || c == '+' || c == '.' || c == ':'; c = p.peek() {
+		p.pos++
+	}
+	tok := p.src[start:p.pos]
+	// a date and a time may be separated by a space
+	if dateRe.MatchString(tok) && len(tok) == 10 && p.peek() == ' ' &&
+		p.pos+1 < len(p.src) && '0' <= p.src[p.pos+1] && p.src[p.pos+1] <= '9' {
+		p.pos++
+		for c := p.peek(); isBare(c) || c == '+' || c == '.' || c == ':'; c = p.peek() {
+			p.pos++
+		}
+		tok = p.src[start:p.pos]
+	}
+	if tok == "" {
+		p.errorf("expected value, found %s", p.found())
+	}
+
+	switch {
+	case dateRe.MatchString(tok):
+		return p.dateTime(tok)
+	case timeRe.MatchString(tok):
+		return tok
+	case decimalRe.MatchString(tok):
+		i, err := strconv.ParseInt(strings.Replace(tok, "_", "", -1), 10, 64)
+		if err != nil {
+			p.errorf("integer %s out of range", tok)
+		}
+		return i
+	case prefixRe.MatchString(tok):
+		base := map[byte]int{'x': 16, 'o': 8, 'b': 2}[tok[1]]
+		i, err := strconv.ParseInt(strings.Replace(tok[2:], "_", "", -1), base, 64)
+		if err != nil {
+			p.errorf("integer %s out of range", tok)
+		}
+		return i
+	case floatRe.MatchString(tok):
+		f, err := strconv.ParseFloat(strings.Replace(tok, "_", "", -1), 64)
+		if err != nil {
+			p.errorf("float %s out of range", tok)
+		}
+		return f
+	}
+	switch strings.TrimLeft(tok, "+-") {
+	case "inf":
+		if tok[0] == '-' {
+			return math.Inf(-1)
+		}
+		return math.Inf(1)
+	case "nan":
+		return math.NaN()
+	}
+	p.pos = start
+	p.errorf("invalid value %s", tok)
+	return nil
+}
+
+func (p *parser) dateTime(tok string) interface{} {
+	s := strings.ToUpper(strings.Replace(tok, " ", "T", 1))
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05.999999999", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t
+		}
+	}
+	p.errorf("invalid date-time %s", tok)
+	return nil
+}
+
+func (p *parser) basicString() string {
+	p.pos++
+	var buf []byte
+	for {
+		if p.eof() || p.peek() == '\n' {
+			p.errorf("missing closing quote")
+		}
+		c := p.src[p.pos]
+		p.pos++
+		switch c {
+		case '"':
+			return string(buf)
+		case '\\':
+			buf = p.escape(buf)
+		default:
+			buf = append(buf, c)
+		}
+	}
+}
+
+func (p *parser) escape(buf []byte) []byte {
+	c := p.peek()
+	p.pos++
+	switch c {
+	case 'b':
+		return append(buf, '\b')
+	case 't':
+		return append(buf, '\t')
+	case 'n':
+		return append(buf, '\n')
+	case 'f':
+		return append(buf, '\f')
+	case 'r':
+		return append(buf, '\r')
+	case '"', '\\':
+		return append(buf, c)
+	case 'u', 'U':
+		n := 4
+		if c == 'U' {
+			n = 8
+		}
+		if p.pos+n > len(p.src) {
+			p.errorf("invalid unicode escape")
+		}
+		r, err := strconv.ParseUint(p.src[p.pos:p.pos+n], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(r)) {
+			p.errorf("invalid unicode escape \\%c%s", c, p.src[p.pos:p.pos+n])
+		}
+		p.pos += n
+		return append(buf, string(rune(r))...)
+	}
+	p.pos--
+	p.errorf("invalid escape sequence \\%c", c)
+	return nil
+}
+
+func (p *parser) literalString() string {
+	p.pos++
+	start := p.pos
+	for p.peek() != '\'' {
+		if p.eof() || p.peek() == '\n' {
+			p.errorf("missing closing quote")
+		}
+		p.pos++
+	}
+	p.pos++
+	return p.src[start : p.pos-1]
+}
+
+func (p *parser) multilineString(delim string) string {
+	p.pos += 3
+	// a newline right after the delimiter is trimmed
+	if p.peek() == '\n' {
+		p.pos++
+	} else if strings.HasPrefix(p.src[p.pos:], "\r\n") {
+		p.pos += 2
+	}
+
+	var buf []byte
+	for {
+		if p.eof() {
+			p.errorf("missing closing %s", delim)
+		}
+		if strings.HasPrefix(p.src[p.pos:], delim) {
+			p.pos += 3
+			// up to two quotes may be part of the content
+			for i := 0; i < 2 && p.peek() == delim[0]; i++ {
+				buf = append(buf, delim[0])
+				p.pos++
+			}
+			return string(buf)
+		}
+		c := p.src[p.pos]
+		p.pos++
+		if c != '\\' || delim == "'''" {
+			buf = append(buf, c)
+			continue
+		}
+		// a backslash at the end of a line trims the following whitespace
+		i := p.pos
+		for i < len(p.src) && (p.src[i] == ' ' || p.src[i] == '\t') {
+			i++
+		}
+		if i < len(p.src) && (p.src[i] == '\n' || p.src[i] == '\r') {
+			for i < len(p.src) && strings.IndexByte(" \t\r\n", p.src[i]) >= 0 {
+				i++
+			}
+			p.pos = i
+			continue
+		}
+		buf = p.escape(buf)
+	}
+}
+
+func (p *parser) array() []interface{} {
+	p.pos++
+	arr := []interface{}{}
+	for {
+		p.skipBlank()
+		if p.peek() == ']' {
+			p.pos++
+			return arr
+		}
+		arr = append(arr, p.value())
+		p.skipBlank()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return arr
+		default:
+			p.errorf("expected ',' or ']' in array, found %s", p.found())
+		}
+	}
+}
+
+func (p *parser) inlineTable() map[string]interface{} {
+	p.pos++
+	m := make(map[string]interface{})
+	p.skipSpace()
+	if p.peek() == '}' {
+		p.pos++
+		return m
+	}
+	for {
+		keys := p.key()
+		p.skipSpace()
+		p.expect('=')
+		p.skipSpace()
+		p.set(m, "", keys, p.value())
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return m
+		default:
+			p.errorf("expected ',' or '}' in inline table, found %s", p.found())
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package tomlconfg implements a confg.Configurator for TOML files. A file like:
+
+	# global settings
+	name = "toys"
+
+	[db]
+	host = "localhost" # the database host
+	ports = [5432, 5433]
+
+	[[db.replicas]]
+	host = "db1.local"
+
+gives the keys "name", "db.host", "db.ports" and "db.replicas", tables are
+mapped to dotted keys and the name of a table gives a map of its keys.
+Integers are int64, floats are float64, date-times are time.Time, local times
+are strings, arrays are []interface{} and inline tables or elements of an
+array of tables are map[string]interface{}. Save writes the settings back and
+keeps the comments and layout of the loaded file.
+*/
+package tomlconfg
+
+import (
+	"fmt"
+	"github.com/kidstuff/toys/confg"
+	"github.com/kidstuff/toys/util/errs"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+func init() {
+	confg.Register("tomlconfg", func() confg.Configurator {
+		return &TOMLConfig{}
+	})
+}
+
+// SyntaxError describes a line of the file which cannot be parsed.
+type SyntaxError struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("tomlconfg: %s:%d: %s", e.File, e.Line, e.Msg)
+}
+
+type TOMLConfig struct {
+	path string
+	doc  *document
+	data confg.Tree
+	mux  sync.RWMutex
+}
+
+// Load reads the file at path. If the file cannot be parsed the values loaded
+// before are kept and the error is a *SyntaxError.
+func (c *TOMLConfig) Load(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errs.Err(err, "tomlconfg: cannot load the file: "+path)
+	}
+
+	doc, err := parse(path, string(b))
+	if err != nil {
+		return err
+	}
+
+	c.mux.Lock()
+	c.path = path
+	c.doc = doc
+	c.data = doc.tree()
+	c.mux.Unlock()
+	return nil
+}
+
+// LoadReader reads the settings from r, the result cannot be saved. The
+// file name in the *SyntaxError is "input".
+func (c *TOMLConfig) LoadReader(r io.Reader) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return errs.Err(err, "tomlconfg: cannot read the input")
+	}
+
+	doc, err := parse("input", string(b))
+	if err != nil {
+		return err
+	}
+
+	c.mux.Lock()
+	c.path = ""
+	c.doc = doc
+	c.data = doc.tree()
+	c.mux.Unlock()
+	return nil
+}
+
+// Save writes the settings back to the loaded file.
+func (c *TOMLConfig) Save() error {
+	c.mux.RLock()
+	path := c.path
+	c.mux.RUnlock()
+
+	if path == "" {
+		return errs.New("tomlconfg: no file loaded to save")
+	}
+	return c.SaveAs(path)
+}
+
+// SaveAs writes the settings to path, the next Save writes there too. The
+// comments and layout of the loaded file are kept.
+func (c *TOMLConfig) SaveAs(path string) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if c.doc == nil {
+		c.doc = &document{}
+	}
+	b, err := c.doc.encode(c.data)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(path, b, 0644)
+	if err != nil {
+		return errs.Err(err, "tomlconfg: cannot save the file: "+path)
+	}
+	c.path = path
+	return nil
+}
+
+func (c *TOMLConfig) Close() error {
+	return nil
+}
+
+func (c *TOMLConfig) Set(k string, v interface{}) {
+	c.mux.Lock()
+	if c.data == nil {
+		c.data = make(confg.Tree)
+	}
+	c.data.Set(k, v)
+	c.mux.Unlock()
+}
+
+// Get returns the value of k. The name of a table gives a map of its keys.
+func (c *TOMLConfig) Get(k string) interface{} {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return c.data.Get(k)
+}
+
+func (c *TOMLConfig) Del(k string) {
+	c.mux.Lock()
+	c.data.Del(k)
+	c.mux.Unlock()
+}
+
+func (c *TOMLConfig) Keys() []string {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return c.data.Keys()
+}
+
+var (
+	_ confg.Configurator = &TOMLConfig{}
+	_ confg.Keyer        = &TOMLConfig{}
+	_ confg.Saver        = &TOMLConfig{}
+	_ confg.ReaderLoader = &TOMLConfig{}
+)
@@ -0,0 +1,199 @@
+package tomlconfg
+
+import (
+	"github.com/kidstuff/toys/confg"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sample = `# global settings
+name = "toys"
+title = 'C:\path' # literal string
+
+[db]
+host = "localhost" # the database host
+ports = [
+  5432, # primary
+  5433,
+]
+timeout = 1.5
+enabled = true
+started = 1979-05-27T07:32:00Z
+point = { x = 1, y = -2 }
+server.name = "alpha"
+
+[[products]]
+name = "hammer"
+sku = 0x2A
+
+[[products]]
+name = "nail"
+dims = { length = 2_000 }
+`
+
+func TestParse(t *testing.T) {
+	doc, err := parse("sample.toml", sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := doc.tree()
+
+	tests := map[string]interface{}{
+		"name":           "toys",
+		"title":          `C:\path`,
+		"db.host":        "localhost",
+		"db.ports":       []interface{}{int64(5432), int64(5433)},
+		"db.timeout":     1.5,
+		"db.enabled":     true,
+		"db.started":     time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC),
+		"db.point":       map[string]interface{}{"x": int64(1), "y": int64(-2)},
+		"db.point.y":     int64(-2),
+		"db.server.name": "alpha",
+		"products": []interface{}{
+			map[string]interface{}{"name": "hammer", "sku": int64(42)},
+			map[string]interface{}{"name": "nail", "dims": map[string]interface{}{"length": int64(2000)}},
+		},
+	}
+	for k, want := range tests {
+		if got := data.Get(k); !reflect.DeepEqual(got, want) {
+			t.Errorf("Get(%q) = %#v, want %#v", k, got, want)
+		}
+	}
+}
+
+func TestValues(t *testing.T) {
+	tests := map[string]interface{}{
+		`"tab\there \u00e9"`:         "tab\there \u00e9",
+		"\"\"\"\nline1\nline2\"\"\"": "line1\nline2",
+		"\"\"\"a \\\n    b\"\"\"":    "a b",
+		"'''\nraw \\n'''":            `raw \n`,
+		"-17":                        int64(-17),
+		"0o755":                      int64(493),
+		"0b101":                      int64(5),
+		"6.626e-34":                  6.626e-34,
+		"-inf":                       math.Inf(-1),
+		"07:32:00":                   "07:32:00",
+		"1979-05-27 07:32:00+07:00":  time.Date(1979, 5, 27, 7, 32, 0, 0, time.FixedZone("", 7*3600)),
+		"[[1, 2], ['a']]":            []interface{}{[]interface{}{int64(1), int64(2)}, []interface{}{"a"}},
+		"{ a.b = 1 }":                map[string]interface{}{"a": map[string]interface{}{"b": int64(1)}},
+	}
+	for src, want := range tests {
+		doc, err := parse("value.toml", "v = "+src+"\n")
+		if err != nil {
+			t.Errorf("parse(%q): %v", src, err)
+			continue
+		}
+		got := doc.tree().Get("v")
+		if gt, ok := got.(time.Time); ok {
+			if !gt.Equal(want.(time.Time)) {
+				t.Errorf("parse(%q) = %v, want %v", src, got, want)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("parse(%q) = %#v, want %#v", src, got, want)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	doc, err := parse("sample.toml", sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := doc.tree()
+	if got, err := doc.encode(data); err != nil || string(got) != sample {
+		t.Fatalf("unchanged document encoded as:\n%s, %v", got, err)
+	}
+
+	data.Set("db.host", "db.example.com")
+	data.Del("db.enabled")
+	data.Set("db.user", "admin")
+	data.Set("version", int64(2))
+	data.Set("cache.size", 64.0)
+	products := data.Get("products").([]interface{})
+	data.Set("products", products[:1])
+
+	want := `# global settings
+name = "toys"
+title = 'C:\path' # literal string
+version = 2
+
+[db]
+host = "db.example.com" # the database host
+ports = [
+  5432, # primary
+  5433,
+]
+timeout = 1.5
+started = 1979-05-27T07:32:00Z
+point = { x = 1, y = -2 }
+server.name = "alpha"
+user = "admin"
+
+[[products]]
+name = "hammer"
+sku = 42
+
+[cache]
+size = 64.0
+`
+	b, err := doc.encode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(b)
+	if got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	doc2, err := parse("out.toml", got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(doc2.tree(), data) {
+		t.Errorf("encoded document read back as %v", doc2.tree())
+	}
+}
+
+func TestSyntaxError(t *testing.T) {
+	tests := map[string]int{
+		"a = 1\nb\n":                 2,
+		"a = 1\na = 2\n":             2,
+		"[a]\nb = \"x\n":             2,
+		"a = [1,\n2\n3]\n":           3,
+		"[a]\n[a]\n":                 2,
+		"a = 1\n[a]\n":               2,
+		"a = 01\n":                   1,
+		"a = 1 b\n":                  1,
+		"\n\n\nk = \"\\q\"\n":        4,
+		"x = \"\"\"\nnever closed\n": 3,
+		"[[t]]\n[t]\n":               2,
+	}
+	for src, line := range tests {
+		_, err := parse("bad.toml", src)
+		serr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("parse(%q) error = %v, want *SyntaxError", src, err)
+			continue
+		}
+		if serr.Line != line || !strings.HasPrefix(serr.Error(), "tomlconfg: bad.toml:") {
+			t.Errorf("parse(%q) error = %v, want line %d", src, err, line)
+		}
+	}
+}
+
+func TestEncodeUnsupported(t *testing.T) {
+	for _, v := range []interface{}{
+		nil,
+		[]interface{}{"a", nil},
+		map[string]interface{}{"n": struct{ N int }{1}},
+	} {
+		data := confg.Tree{"servers": v}
+		if _, err := (&document{}).encode(data); err == nil {
+			t.Errorf("encode(%#v) wrote a value TOML cannot hold", v)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package confg
+
+import (
+	"sort"
+	"strings"
+)
+
+// Tree is a set of nested maps addressed by dotted keys: the key "db.host"
+// is the "host" item of the map stored at "db". Nested maps are always of
+// type map[string]interface{}.
+type Tree map[string]interface{}
+
+// Get returns the value of k or nil if k not exist.
+func (t Tree) Get(k string) interface{} {
+	if v, ok := t[k]; ok {
+		return v
+	}
+	return lookupMap(t, strings.Split(k, "."))
+}
+
+// Set sets the value of k, the maps on the way are created if need.
+// A non-map value on the way is replaced.
+func (t Tree) Set(k string, v interface{}) {
+	if _, ok := t[k]; ok {
+		t[k] = v
+		return
+	}
+	parts := strings.Split(k, ".")
+	m := map[string]interface{}(t)
+	for _, p := range parts[:len(parts)-1] {
+		sub, ok := m[p].(map[string]interface{})
+		if !ok {
+			sub = make(map[string]interface{})
+			m[p] = sub
+		}
+		m = sub
+	}
+	m[parts[len(parts)-1]] = v
+}
+
+// Del deletes k from the tree.
+func (t Tree) Del(k string) {
+	if _, ok := t[k]; ok {
+		delete(t, k)
+		return
+	}
+	parts := strings.Split(k, ".")
+	m := map[string]interface{}(t)
+	for _, p := range parts[:len(parts)-1] {
+		sub, ok := m[p].(map[string]interface{})
+		if !ok {
+			return
+		}
+		m = sub
+	}
+	delete(m, parts[len(parts)-1])
+}
+
+// Keys returns the sorted dotted keys of all non-map values in the tree.
+func (t Tree) Keys() []string {
+	var keys []string
+	for k := range Flatten(t) {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Flatten returns a map of the dotted keys to the non-map values of m.
+func Flatten(m map[string]interface{}) map[string]interface{} {
+	flat := make(map[string]interface{})
+	flatten(flat, "", m)
+	return flat
+}
+
+func flatten(dst map[string]interface{}, prefix string, m map[string]interface{}) {
+	for k, v := range m {
+		if prefix != "" {
+			k = prefix + "." + k
+		}
+		if sub, ok := v.(map[string]interface{}); ok && len(sub) > 0 {
+			flatten(dst, k, sub)
+		} else {
+			dst[k] = v
+		}
+	}
+}
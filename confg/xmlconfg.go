@@ -4,53 +4,281 @@
 package confg
 
 import (
+	"bytes"
 	"encoding/xml"
-	"errors"
+	"fmt"
+	"github.com/kidstuff/toys/util/errs"
+	"io"
+	"io/ioutil"
 	"os"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
 )
 
+func init() {
+	Register("xmlconfg", func() Configurator {
+		return &XmlConfg{}
+	})
+}
+
+// XmlSetting is a setting element of the XML file. Nested maps are written
+// as dotted keys, the type attribute tells how to read the text:
+//
+//	<config>
+//		<setting key="db.host">localhost</setting>
+//		<setting key="db.port" type="int">5432</setting>
+//		<setting key="db.replicas" type="list">
+//			<item>db1.local</item>
+//			<item>db2.local</item>
+//		</setting>
+//	</config>
+//
+// The types are string (the default), int, float, bool, time (RFC 3339),
+// list with item elements and map with setting elements.
 type XmlSetting struct {
-	Key   string
-	Value interface{}
+	Key      string       `xml:"key,attr,omitempty"`
+	Type     string       `xml:"type,attr,omitempty"`
+	Value    string       `xml:",chardata"`
+	Items    []XmlSetting `xml:"item"`
+	Settings []XmlSetting `xml:"setting"`
+}
+
+type xmlDocument struct {
+	XMLName  xml.Name     `xml:"config"`
+	Settings []XmlSetting `xml:"setting"`
 }
 
+// XmlConfg is a Configurator for XML files, see XmlSetting for the format.
 type XmlConfg struct {
-	settings []XmlSetting
-	file     *os.File
+	data Tree
+	path string
+	mux  sync.RWMutex
 }
 
+// NewXmlConfg returns a XmlConfg loaded from path.
 func NewXmlConfg(path string) (*XmlConfg, error) {
-	f, err := os.OpenFile(path, os.O_RDWR, os.ModePerm)
+	x := &XmlConfg{}
+	if err := x.Load(path); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// Load reads the file at path. If the file cannot be decoded the values
+// loaded before are kept.
+func (x *XmlConfg) Load(path string) error {
+	f, err := os.Open(path)
 	if err != nil {
-		return nil, errors.New("confg: cannot open congfig file")
+		return errs.Err(err, "confg: cannot open congfig file "+path)
+	}
+	defer f.Close()
+
+	if err = x.LoadReader(f); err != nil {
+		return err
+	}
+	x.mux.Lock()
+	x.path = path
+	x.mux.Unlock()
+	return nil
+}
+
+// LoadReader reads the settings from r.
+func (x *XmlConfg) LoadReader(r io.Reader) error {
+	var doc xmlDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return errs.Err(err, "confg: cannot decode stored data")
+	}
+
+	data := make(Tree)
+	for _, s := range doc.Settings {
+		v, err := s.decode()
+		if err != nil {
+			return err
+		}
+		data.Set(s.Key, v)
 	}
 
-	var settings []XmlSetting
-	dec := xml.NewDecoder(f)
-	err = dec.Decode(settings)
+	x.mux.Lock()
+	x.data = data
+	x.path = ""
+	x.mux.Unlock()
+	return nil
+}
+
+func (s *XmlSetting) decode() (interface{}, error) {
+	var (
+		v   interface{}
+		err error
+	)
+	switch s.Type {
+	case "", "string":
+		v = s.Value
+	case "int":
+		v, err = strconv.ParseInt(s.Value, 10, 64)
+	case "float":
+		v, err = strconv.ParseFloat(s.Value, 64)
+	case "bool":
+		v, err = strconv.ParseBool(s.Value)
+	case "time":
+		v, err = time.Parse(time.RFC3339Nano, s.Value)
+	case "list":
+		list := make([]interface{}, len(s.Items))
+		for i := range s.Items {
+			if list[i], err = s.Items[i].decode(); err != nil {
+				return nil, err
+			}
+		}
+		v = list
+	case "map":
+		m := make(Tree)
+		for i := range s.Settings {
+			item, err := s.Settings[i].decode()
+			if err != nil {
+				return nil, err
+			}
+			m.Set(s.Settings[i].Key, item)
+		}
+		v = map[string]interface{}(m)
+	default:
+		return nil, errs.New("confg: unknown setting type " + s.Type)
+	}
 	if err != nil {
-		return nil, errors.New("confg: cannot decode stored data")
+		return nil, errs.Err(err, "confg: invalid value for "+s.Key)
 	}
+	return v, nil
+}
 
-	x := &XmlConfg{}
-	x.file = f
-	x.settings = settings
-	return x, nil
+// encodeSetting returns the setting of k, or an error if v cannot be written
+// with the setting types, like a nil. path is the full key of the setting,
+// used in the errors.
+func encodeSetting(k, path string, v interface{}) (XmlSetting, error) {
+	s := XmlSetting{Key: k}
+	switch x := v.(type) {
+	case nil:
+		return s, errs.New("confg: " + path + ": cannot write a nil value")
+	case string:
+		s.Value = x
+	case bool:
+		s.Type = "bool"
+		s.Value = strconv.FormatBool(x)
+	case time.Time:
+		s.Type = "time"
+		s.Value = x.Format(time.RFC3339Nano)
+	case map[string]interface{}:
+		s.Type = "map"
+		for _, mk := range Tree(x).Keys() {
+			item, err := encodeSetting(mk, path+"."+mk, Tree(x).Get(mk))
+			if err != nil {
+				return s, err
+			}
+			s.Settings = append(s.Settings, item)
+		}
+	case fmt.Stringer:
+		s.Value = x.String()
+	default:
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			s.Type = "int"
+			s.Value = fmt.Sprint(v)
+		case reflect.Float32, reflect.Float64:
+			s.Type = "float"
+			s.Value = strconv.FormatFloat(rv.Float(), 'g', -1, 64)
+		case reflect.Slice, reflect.Array:
+			s.Type = "list"
+			s.Items = make([]XmlSetting, rv.Len())
+			for i := range s.Items {
+				item, err := encodeSetting("", fmt.Sprintf("%s[%d]", path, i), rv.Index(i).Interface())
+				if err != nil {
+					return s, err
+				}
+				s.Items[i] = item
+			}
+		default:
+			return s, errs.New(fmt.Sprintf("confg: %s: cannot write a %T value", path, v))
+		}
+	}
+	return s, nil
 }
 
-func (x *XmlConfg) Close() {
-	x.file.Close()
+// Save writes the settings back to the loaded file.
+func (x *XmlConfg) Save() error {
+	x.mux.RLock()
+	path := x.path
+	x.mux.RUnlock()
+
+	if path == "" {
+		return errs.New("confg: no file loaded to save")
+	}
+	return x.SaveAs(path)
 }
 
-func (x *XmlConfg) Set(k string, v interface{}) {
-	found := false
-	for _, setting := range x.settings {
-		if setting.Key == k {
-			setting.Value = v
-			found = true
+// SaveAs writes the settings to path, the next Save writes there too.
+func (x *XmlConfg) SaveAs(path string) error {
+	x.mux.Lock()
+	defer x.mux.Unlock()
+
+	var doc xmlDocument
+	for _, k := range x.data.Keys() {
+		s, err := encodeSetting(k, k, x.data.Get(k))
+		if err != nil {
+			return err
 		}
+		doc.Settings = append(doc.Settings, s)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "\t")
+	if err := enc.Encode(doc); err != nil {
+		return errs.Err(err, "confg: cannot encode settings")
 	}
-	if !found {
-		return
+	buf.WriteByte('\n')
+
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return errs.Err(err, "confg: cannot save the file "+path)
+	}
+	x.path = path
+	return nil
+}
+
+func (x *XmlConfg) Close() error {
+	return nil
+}
+
+func (x *XmlConfg) Set(k string, v interface{}) {
+	x.mux.Lock()
+	if x.data == nil {
+		x.data = make(Tree)
 	}
+	x.data.Set(k, v)
+	x.mux.Unlock()
+}
+
+func (x *XmlConfg) Get(k string) interface{} {
+	x.mux.RLock()
+	defer x.mux.RUnlock()
+	return x.data.Get(k)
 }
+
+func (x *XmlConfg) Del(k string) {
+	x.mux.Lock()
+	x.data.Del(k)
+	x.mux.Unlock()
+}
+
+func (x *XmlConfg) Keys() []string {
+	x.mux.RLock()
+	defer x.mux.RUnlock()
+	return x.data.Keys()
+}
+
+var (
+	_ Configurator = &XmlConfg{}
+	_ Keyer        = &XmlConfg{}
+	_ Saver        = &XmlConfg{}
+	_ ReaderLoader = &XmlConfg{}
+)
@@ -0,0 +1,102 @@
+package confg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestXmlConfgRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "confg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.xml")
+
+	at := time.Date(2013, 3, 7, 10, 30, 0, 0, time.UTC)
+	values := map[string]interface{}{
+		"name":       "toys",
+		"db.port":    int64(5432),
+		"db.ratio":   0.5,
+		"db.ssl":     true,
+		"started":    at,
+		"db.hosts":   []interface{}{"db1.local", "db2.local"},
+		"extra":      map[string]interface{}{},
+		"servers":    []interface{}{map[string]interface{}{"host": "a", "port": int64(1), "tags": []interface{}{"x"}}},
+		"matrix":     []interface{}{[]interface{}{int64(1), int64(2)}, []interface{}{}},
+		"empty.text": "",
+	}
+	x := &XmlConfg{}
+	for k, v := range values {
+		x.Set(k, v)
+	}
+	if err = x.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := ioutil.ReadFile(path)
+	for _, part := range []string{
+		`<setting key="db.port" type="int">5432</setting>`,
+		`<setting key="started" type="time">2013-03-07T10:30:00Z</setting>`,
+		`<setting key="extra" type="map"></setting>`,
+	} {
+		if !strings.Contains(string(b), part) {
+			t.Errorf("saved file has no %s:\n%s", part, b)
+		}
+	}
+
+	y, err := NewXmlConfg(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range values {
+		if got := y.Get(k); !reflect.DeepEqual(got, want) {
+			t.Errorf("Get(%s) = %#v, want %#v", k, got, want)
+		}
+	}
+	if !reflect.DeepEqual(y.Keys(), x.Keys()) {
+		t.Errorf("Keys() = %v, want %v", y.Keys(), x.Keys())
+	}
+
+	y.Set("db.port", int64(5433))
+	if err = y.Save(); err != nil {
+		t.Fatal(err)
+	}
+	z, err := NewXmlConfg(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := z.Get("db.port"); got != int64(5433) {
+		t.Errorf("Get(db.port) after Save = %#v", got)
+	}
+}
+
+func TestXmlConfgUnsupported(t *testing.T) {
+	dir, err := ioutil.TempDir("", "confg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, v := range []interface{}{
+		nil,
+		[]interface{}{"a", nil},
+		map[string]interface{}{"n": struct{ N int }{1}},
+	} {
+		x := &XmlConfg{}
+		x.Set("key", v)
+		if err := x.SaveAs(filepath.Join(dir, "app.xml")); err == nil {
+			t.Errorf("SaveAs(%#v) wrote a value XML cannot hold", v)
+		}
+	}
+
+	x := &XmlConfg{}
+	if err := x.LoadReader(strings.NewReader(`<config><setting key="n" type="int">x</setting></config>`)); err == nil {
+		t.Error("LoadReader accepted an invalid int")
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package locale
+
+// The bundled Conventions, taken from the CLDR data of each language.
+
+const (
+	nbsp       = "\u00a0"
+	narrowNbsp = "\u202f"
+)
+
+func init() {
+	en := &Conventions{
+		Decimal: ".", Group: ",", PrimaryGroup: 3, MinGrouping: 1,
+		CurrencyFormat: "¤n",
+		DateFormats:    [4]string{"M/d/yy", "MMM d, y", "MMMM d, y", "EEEE, MMMM d, y"},
+		TimeFormats:    [4]string{"h:mm a", "h:mm:ss a", "h:mm:ss a", "h:mm:ss a"},
+		Months: [12]string{"January", "February", "March", "April", "May", "June",
+			"July", "August", "September", "October", "November", "December"},
+		ShortMonths: [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun",
+			"Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+		Days: [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+		AM:   "AM", PM: "PM",
+	}
+	enGB := *en
+	enGB.DateFormats = [4]string{"dd/MM/y", "d MMM y", "d MMMM y", "EEEE d MMMM y"}
+	enGB.TimeFormats = [4]string{"HH:mm", "HH:mm:ss", "HH:mm:ss", "HH:mm:ss"}
+	enGB.AM, enGB.PM = "am", "pm"
+
+	conventions["en"] = en
+	conventions["en-gb"] = &enGB
+	conventions["vi"] = &Conventions{
+		Decimal: ",", Group: ".", PrimaryGroup: 3, MinGrouping: 1,
+		CurrencyFormat: "n" + nbsp + "¤",
+		DateFormats:    [4]string{"dd/MM/y", "d MMM, y", "d MMMM, y", "EEEE, d MMMM, y"},
+		TimeFormats:    [4]string{"HH:mm", "HH:mm:ss", "HH:mm:ss", "HH:mm:ss"},
+		Months: [12]string{"tháng 1", "tháng 2", "tháng 3", "tháng 4", "tháng 5", "tháng 6",
+			"tháng 7", "tháng 8", "tháng 9", "tháng 10", "tháng 11", "tháng 12"},
+		ShortMonths: [12]string{"thg 1", "thg 2", "thg 3", "thg 4", "thg 5", "thg 6",
+			"thg 7", "thg 8", "thg 9", "thg 10", "thg 11", "thg 12"},
+		Days: [7]string{"Chủ Nhật", "Thứ Hai", "Thứ Ba", "Thứ Tư", "Thứ Năm", "Thứ Sáu", "Thứ Bảy"},
+		AM:   "SA", PM: "CH",
+	}
+	conventions["fr"] = &Conventions{
+		Decimal: ",", Group: narrowNbsp, PrimaryGroup: 3, MinGrouping: 1,
+		CurrencyFormat: "n" + nbsp + "¤",
+		DateFormats:    [4]string{"dd/MM/y", "d MMM y", "d MMMM y", "EEEE d MMMM y"},
+		TimeFormats:    [4]string{"HH:mm", "HH:mm:ss", "HH:mm:ss", "HH:mm:ss"},
+		Months: [12]string{"janvier", "février", "mars", "avril", "mai", "juin",
+			"juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		ShortMonths: [12]string{"janv.", "févr.", "mars", "avr.", "mai", "juin",
+			"juil.", "août", "sept.", "oct.", "nov.", "déc."},
+		Days: [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+		AM:   "AM", PM: "PM",
+	}
+	conventions["de"] = &Conventions{
+		Decimal: ",", Group: ".", PrimaryGroup: 3, MinGrouping: 1,
+		CurrencyFormat: "n" + nbsp + "¤",
+		DateFormats:    [4]string{"dd.MM.yy", "dd.MM.y", "d. MMMM y", "EEEE, d. MMMM y"},
+		TimeFormats:    [4]string{"HH:mm", "HH:mm:ss", "HH:mm:ss", "HH:mm:ss"},
+		Months: [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni",
+			"Juli", "August", "September", "Oktober", "November", "Dezember"},
+		ShortMonths: [12]string{"Jan.", "Feb.", "März", "Apr.", "Mai", "Juni",
+			"Juli", "Aug.", "Sept.", "Okt.", "Nov.", "Dez."},
+		Days: [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		AM:   "AM", PM: "PM",
+	}
+	conventions["es"] = &Conventions{
+		Decimal: ",", Group: ".", PrimaryGroup: 3, MinGrouping: 5,
+		CurrencyFormat: "n" + nbsp + "¤",
+		DateFormats:    [4]string{"d/M/yy", "d MMM y", "d 'de' MMMM 'de' y", "EEEE, d 'de' MMMM 'de' y"},
+		TimeFormats:    [4]string{"H:mm", "H:mm:ss", "H:mm:ss", "H:mm:ss"},
+		Months: [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio",
+			"julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		ShortMonths: [12]string{"ene", "feb", "mar", "abr", "may", "jun",
+			"jul", "ago", "sept", "oct", "nov", "dic"},
+		Days: [7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+		AM:   "a." + nbsp + "m.", PM: "p." + nbsp + "m.",
+	}
+	conventions["pt"] = &Conventions{
+		Decimal: ",", Group: ".", PrimaryGroup: 3, MinGrouping: 1,
+		CurrencyFormat: "¤" + nbsp + "n",
+		DateFormats:    [4]string{"dd/MM/y", "d 'de' MMM 'de' y", "d 'de' MMMM 'de' y", "EEEE, d 'de' MMMM 'de' y"},
+		TimeFormats:    [4]string{"HH:mm", "HH:mm:ss", "HH:mm:ss", "HH:mm:ss"},
+		Months: [12]string{"janeiro", "fevereiro", "março", "abril", "maio", "junho",
+			"julho", "agosto", "setembro", "outubro", "novembro", "dezembro"},
+		ShortMonths: [12]string{"jan.", "fev.", "mar.", "abr.", "mai.", "jun.",
+			"jul.", "ago.", "set.", "out.", "nov.", "dez."},
+		Days: [7]string{"domingo", "segunda-feira", "terça-feira", "quarta-feira", "quinta-feira", "sexta-feira", "sábado"},
+		AM:   "AM", PM: "PM",
+	}
+	conventions["it"] = &Conventions{
+		Decimal: ",", Group: ".", PrimaryGroup: 3, MinGrouping: 1,
+		CurrencyFormat: "n" + nbsp + "¤",
+		DateFormats:    [4]string{"dd/MM/yy", "d MMM y", "d MMMM y", "EEEE d MMMM y"},
+		TimeFormats:    [4]string{"HH:mm", "HH:mm:ss", "HH:mm:ss", "HH:mm:ss"},
+		Months: [12]string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno",
+			"luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"},
+		ShortMonths: [12]string{"gen", "feb", "mar", "apr", "mag", "giu",
+			"lug", "ago", "set", "ott", "nov", "dic"},
+		Days: [7]string{"domenica", "lunedì", "martedì", "mercoledì", "giovedì", "venerdì", "sabato"},
+		AM:   "AM", PM: "PM",
+	}
+	conventions["ru"] = &Conventions{
+		Decimal: ",", Group: nbsp, PrimaryGroup: 3, MinGrouping: 1,
+		CurrencyFormat: "n" + nbsp + "¤",
+		DateFormats:    [4]string{"dd.MM.y", "d MMM y 'г'.", "d MMMM y 'г'.", "EEEE, d MMMM y 'г'."},
+		TimeFormats:    [4]string{"HH:mm", "HH:mm:ss", "HH:mm:ss", "HH:mm:ss"},
+		Months: [12]string{"января", "февраля", "марта", "апреля", "мая", "июня",
+			"июля", "августа", "сентября", "октября", "ноября", "декабря"},
+		ShortMonths: [12]string{"янв.", "февр.", "мар.", "апр.", "мая", "июн.",
+			"июл.", "авг.", "сент.", "окт.", "нояб.", "дек."},
+		Days: [7]string{"воскресенье", "понедельник", "вторник", "среда", "четверг", "пятница", "суббота"},
+		AM:   "AM", PM: "PM",
+	}
+	conventions["ja"] = &Conventions{
+		Decimal: ".", Group: ",", PrimaryGroup: 3, MinGrouping: 1,
+		CurrencyFormat: "¤n",
+		DateFormats:    [4]string{"y/MM/dd", "y/MM/dd", "y年M月d日", "y年M月d日EEEE"},
+		TimeFormats:    [4]string{"H:mm", "H:mm:ss", "H:mm:ss", "H:mm:ss"},
+		Months: [12]string{"1月", "2月", "3月", "4月", "5月", "6月",
+			"7月", "8月", "9月", "10月", "11月", "12月"},
+		ShortMonths: [12]string{"1月", "2月", "3月", "4月", "5月", "6月",
+			"7月", "8月", "9月", "10月", "11月", "12月"},
+		Days: [7]string{"日曜日", "月曜日", "火曜日", "水曜日", "木曜日", "金曜日", "土曜日"},
+		AM:   "午前", PM: "午後",
+	}
+	conventions["zh"] = &Conventions{
+		Decimal: ".", Group: ",", PrimaryGroup: 3, MinGrouping: 1,
+		CurrencyFormat: "¤n",
+		DateFormats:    [4]string{"y/M/d", "y年M月d日", "y年M月d日", "y年M月d日EEEE"},
+		TimeFormats:    [4]string{"HH:mm", "HH:mm:ss", "HH:mm:ss", "HH:mm:ss"},
+		Months: [12]string{"一月", "二月", "三月", "四月", "五月", "六月",
+			"七月", "八月", "九月", "十月", "十一月", "十二月"},
+		ShortMonths: [12]string{"1月", "2月", "3月", "4月", "5月", "6月",
+			"7月", "8月", "9月", "10月", "11月", "12月"},
+		Days: [7]string{"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"},
+		AM:   "上午", PM: "下午",
+	}
+	conventions["hi"] = &Conventions{
+		Decimal: ".", Group: ",", PrimaryGroup: 3, SecondaryGroup: 2, MinGrouping: 1,
+		CurrencyFormat: "¤n",
+		DateFormats:    [4]string{"d/M/yy", "d MMM y", "d MMMM y", "EEEE, d MMMM y"},
+		TimeFormats:    [4]string{"h:mm a", "h:mm:ss a", "h:mm:ss a", "h:mm:ss a"},
+		Months: [12]string{"जनवरी", "फ़रवरी", "मार्च", "अप्रैल", "मई", "जून",
+			"जुलाई", "अगस्त", "सितंबर", "अक्तूबर", "नवंबर", "दिसंबर"},
+		ShortMonths: [12]string{"जन॰", "फ़र॰", "मार्च", "अप्रैल", "मई", "जून",
+			"जुल॰", "अग॰", "सित॰", "अक्तू॰", "नव॰", "दिस॰"},
+		Days: [7]string{"रविवार", "सोमवार", "मंगलवार", "बुधवार", "गुरुवार", "शुक्रवार", "शनिवार"},
+		AM:   "am", PM: "pm",
+	}
+}
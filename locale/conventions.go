@@ -0,0 +1,398 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package locale
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DateStyle is the length of a date or time format.
+type DateStyle int
+
+const (
+	DateShort DateStyle = iota
+	DateMedium
+	DateLong
+	DateFull
+)
+
+// ParseDateStyle returns the style named short, medium, long or full. Other
+// names give DateMedium.
+func ParseDateStyle(name string) DateStyle {
+	switch strings.ToLower(name) {
+	case "short":
+		return DateShort
+	case "long":
+		return DateLong
+	case "full":
+		return DateFull
+	}
+	return DateMedium
+}
+
+// Conventions are the number, currency and date formats of a language.
+// The date and time formats are CLDR patterns, see FormatDate.
+type Conventions struct {
+	Decimal string
+	Group   string
+	// PrimaryGroup is the size of the last group of integer digits and
+	// SecondaryGroup the size of the others, 3 and 2 give 12,34,567.
+	PrimaryGroup   int
+	SecondaryGroup int
+	// MinGrouping is the number of digits a number needs to be grouped,
+	// with 5 1234 is not grouped.
+	MinGrouping int
+	// CurrencyFormat places the currency symbol ¤ and the number n, like
+	// "¤n" or "n ¤".
+	CurrencyFormat string
+
+	DateFormats [4]string
+	TimeFormats [4]string
+	Months      [12]string
+	ShortMonths [12]string
+	Days        [7]string // from Sunday
+	AM, PM      string
+}
+
+// Currency is a currency of Conventions.Currency.
+type Currency struct {
+	Symbol string
+	Digits int
+}
+
+var (
+	conventions = make(map[string]*Conventions)
+	currencies  = map[string]Currency{
+		"BRL": {"R$", 2},
+		"CHF": {"CHF", 2},
+		"CNY": {"¥", 2},
+		"EUR": {"€", 2},
+		"GBP": {"£", 2},
+		"INR": {"₹", 2},
+		"JPY": {"¥", 0},
+		"KRW": {"₩", 0},
+		"RUB": {"₽", 2},
+		"USD": {"$", 2},
+		"VND": {"₫", 0},
+	}
+	conventionsMux sync.RWMutex
+)
+
+// RegisterConventions sets the Conventions of a language. It replaces the
+// bundled ones if any.
+func RegisterConventions(lang string, c *Conventions) {
+	conventionsMux.Lock()
+	conventions[normTag(lang)] = c
+	conventionsMux.Unlock()
+}
+
+// RegisterCurrency sets the symbol and the number of fraction digits of a
+// currency code.
+func RegisterCurrency(code string, c Currency) {
+	conventionsMux.Lock()
+	currencies[strings.ToUpper(code)] = c
+	conventionsMux.Unlock()
+}
+
+// ConventionsFor returns the Conventions of a language tag. A regional tag
+// like vi-VN uses the Conventions of vi if it has none, the English ones
+// are used for the unknown languages.
+func ConventionsFor(lang string) *Conventions {
+	lang = normTag(lang)
+	conventionsMux.RLock()
+	defer conventionsMux.RUnlock()
+	for _, tag := range append([]string{lang}, parentTags(lang)...) {
+		if c, ok := conventions[tag]; ok {
+			return c
+		}
+	}
+	return conventions["en"]
+}
+
+// numberText returns the digits of n with frac fraction digits, or the
+// shortest representation if frac is negative.
+func numberText(n interface{}, frac int) (digits string, neg bool, ok bool) {
+	rv := reflect.ValueOf(n)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := rv.Int()
+		digits = strconv.FormatInt(i, 10)
+		if i < 0 {
+			digits, neg = digits[1:], true
+		}
+		if frac > 0 {
+			digits += "." + strings.Repeat("0", frac)
+		}
+		return digits, neg, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		digits = strconv.FormatUint(rv.Uint(), 10)
+		if frac > 0 {
+			digits += "." + strings.Repeat("0", frac)
+		}
+		return digits, false, true
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", false, false
+		}
+		digits = strconv.FormatFloat(math.Abs(f), 'f', frac, 64)
+		neg = f < 0 && strings.Trim(digits, "0.") != ""
+		return digits, neg, true
+	}
+	return "", false, false
+}
+
+// group inserts the group separators in the integer digits.
+func (c *Conventions) group(digits string) string {
+	primary, secondary := c.PrimaryGroup, c.SecondaryGroup
+	if primary <= 0 || len(digits) < c.MinGrouping || len(digits) <= primary {
+		return digits
+	}
+	if secondary <= 0 {
+		secondary = primary
+	}
+	var parts []string
+	parts = append(parts, digits[len(digits)-primary:])
+	digits = digits[:len(digits)-primary]
+	for len(digits) > secondary {
+		parts = append(parts, digits[len(digits)-secondary:])
+		digits = digits[:len(digits)-secondary]
+	}
+	parts = append(parts, digits)
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+	return strings.Join(parts, c.Group)
+}
+
+func (c *Conventions) number(n interface{}, frac int) (string, bool) {
+	digits, neg, ok := numberText(n, frac)
+	if !ok {
+		return "", false
+	}
+	intPart, fracPart := digits, ""
+	if pos := strings.IndexByte(digits, '.'); pos >= 0 {
+		intPart, fracPart = digits[:pos], digits[pos+1:]
+	}
+	s := c.group(intPart)
+	if fracPart != "" {
+		s += c.Decimal + fracPart
+	}
+	return s, neg
+}
+
+// Number formats n, any integer or float type, with frac fraction digits.
+// A negative frac gives as many digits as needed.
+//
+//	ConventionsFor("vi").Number(1234567.5, 2) // "1.234.567,50"
+func (c *Conventions) Number(n interface{}, frac int) string {
+	s, neg := c.number(n, frac)
+	if neg {
+		return "-" + s
+	}
+	if s == "" {
+		// not a number, NaN or infinity
+		return fmt.Sprint(n)
+	}
+	return s
+}
+
+// Currency formats an amount of the currency with the ISO 4217 code, like
+// USD or VND, with the digits and symbol of the currency. The code is used
+// as symbol for the unknown currencies.
+func (c *Conventions) Currency(amount interface{}, code string) string {
+	code = strings.ToUpper(code)
+	conventionsMux.RLock()
+	cur, ok := currencies[code]
+	conventionsMux.RUnlock()
+	if !ok {
+		cur = Currency{code, 2}
+	}
+
+	s, neg := c.number(amount, cur.Digits)
+	if s == "" {
+		return fmt.Sprint(amount)
+	}
+	format := c.CurrencyFormat
+	if format == "" {
+		format = "¤n"
+	}
+	s = strings.Replace(strings.Replace(format, "n", s, 1), "¤", cur.Symbol, 1)
+	if neg {
+		return "-" + s
+	}
+	return s
+}
+
+// index returns the index of the style in the formats, an unknown style is
+// DateMedium.
+func (s DateStyle) index() int {
+	if s < DateShort || s > DateFull {
+		return int(DateMedium)
+	}
+	return int(s)
+}
+
+// Date formats the date of t, an unknown style is DateMedium.
+func (c *Conventions) Date(t time.Time, style DateStyle) string {
+	return c.FormatDate(t, c.DateFormats[style.index()])
+}
+
+// Time formats the time of t, an unknown style is DateMedium.
+func (c *Conventions) Time(t time.Time, style DateStyle) string {
+	return c.FormatDate(t, c.TimeFormats[style.index()])
+}
+
+// FormatDate formats t with a CLDR date pattern. The fields are y, yy, M,
+// MM, MMM, MMMM, d, dd, EEEE, H, HH, h, hh, m, mm, s, ss and a, text
+// between single quotes is written as it is.
+func (c *Conventions) FormatDate(t time.Time, pattern string) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(pattern); {
+		ch := pattern[i]
+		if ch == '\'' {
+			// quoted text, '' is a quote
+			i++
+			if i < len(pattern) && pattern[i] == '\'' {
+				buf.WriteByte('\'')
+				i++
+				continue
+			}
+			for i < len(pattern) {
+				if pattern[i] == '\'' {
+					if i+1 < len(pattern) && pattern[i+1] == '\'' {
+						buf.WriteByte('\'')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				buf.WriteByte(pattern[i])
+				i++
+			}
+			continue
+		}
+		if !('a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z') {
+			buf.WriteByte(ch)
+			i++
+			continue
+		}
+		n := 1
+		for i+n < len(pattern) && pattern[i+n] == ch {
+			n++
+		}
+		i += n
+		buf.WriteString(c.dateField(t, ch, n))
+	}
+	return buf.String()
+}
+
+func pad(v, n int) string {
+	s := strconv.Itoa(v)
+	for len(s) < n {
+		s = "0" + s
+	}
+	return s
+}
+
+func (c *Conventions) dateField(t time.Time, ch byte, n int) string {
+	switch ch {
+	case 'y':
+		if n == 2 {
+			return pad(t.Year()%100, 2)
+		}
+		return pad(t.Year(), n)
+	case 'M', 'L':
+		m := int(t.Month())
+		switch n {
+		case 1, 2:
+			return pad(m, n)
+		case 3:
+			return c.ShortMonths[m-1]
+		}
+		return c.Months[m-1]
+	case 'd':
+		return pad(t.Day(), n)
+	case 'E':
+		return c.Days[t.Weekday()]
+	case 'H':
+		return pad(t.Hour(), n)
+	case 'h':
+		h := t.Hour() % 12
+		if h == 0 {
+			h = 12
+		}
+		return pad(h, n)
+	case 'm':
+		return pad(t.Minute(), n)
+	case 's':
+		return pad(t.Second(), n)
+	case 'a':
+		if t.Hour() < 12 {
+			return c.AM
+		}
+		return c.PM
+	}
+	return strings.Repeat(string(ch), n)
+}
+
+// conventions returns the Conventions of the current lang-set.
+func (l *Lang) conventions() *Conventions {
+	return ConventionsFor(l.defaultSet())
+}
+
+// Number formats n in the current lang-set, see Conventions.Number.
+func (l *Lang) Number(n interface{}, frac int) string {
+	return l.conventions().Number(n, frac)
+}
+
+// Currency formats an amount in the current lang-set, see
+// Conventions.Currency.
+func (l *Lang) Currency(amount interface{}, code string) string {
+	return l.conventions().Currency(amount, code)
+}
+
+// Date formats the date of t in the current lang-set.
+func (l *Lang) Date(t time.Time, style DateStyle) string {
+	return l.conventions().Date(t, style)
+}
+
+// Time formats the time of t in the current lang-set.
+func (l *Lang) Time(t time.Time, style DateStyle) string {
+	return l.conventions().Time(t, style)
+}
+
+// Conventions returns the Conventions of the lang-set.
+func (lc *Localizer) Conventions() *Conventions {
+	return ConventionsFor(lc.set)
+}
+
+// Number formats n, see Conventions.Number.
+func (lc *Localizer) Number(n interface{}, frac int) string {
+	return lc.Conventions().Number(n, frac)
+}
+
+// Currency formats an amount, see Conventions.Currency.
+func (lc *Localizer) Currency(amount interface{}, code string) string {
+	return lc.Conventions().Currency(amount, code)
+}
+
+// Date formats the date of t.
+func (lc *Localizer) Date(t time.Time, style DateStyle) string {
+	return lc.Conventions().Date(t, style)
+}
+
+// Time formats the time of t.
+func (lc *Localizer) Time(t time.Time, style DateStyle) string {
+	return lc.Conventions().Time(t, style)
+}
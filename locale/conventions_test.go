@@ -0,0 +1,66 @@
+package locale
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConventions(t *testing.T) {
+	tests := []struct {
+		lang, got, want string
+	}{
+		{"en", ConventionsFor("en-US").Number(1234567.5, 2), "1,234,567.50"},
+		{"vi", ConventionsFor("vi-VN").Number(1234567.5, 2), "1.234.567,50"},
+		{"vi", ConventionsFor("vi").Number(-1234567, 0), "-1.234.567"},
+		{"hi", ConventionsFor("hi").Number(1234567.25, -1), "12,34,567.25"},
+		{"es", ConventionsFor("es").Number(1234, -1), "1234"},
+		{"es", ConventionsFor("es").Number(12345, -1), "12.345"},
+		{"fr", ConventionsFor("fr").Number(-0.001, 2), "0,00"},
+		{"xx", ConventionsFor("xx").Number(uint8(255), 1), "255.0"},
+		{"en", ConventionsFor("en").Currency(-1234.5, "usd"), "-$1,234.50"},
+		{"vi", ConventionsFor("vi").Currency(1234567.5, "VND"), "1.234.568\u00a0₫"},
+		{"de", ConventionsFor("de").Currency(9.99, "EUR"), "9,99\u00a0€"},
+		{"pt", ConventionsFor("pt-BR").Currency(10, "BRL"), "R$\u00a010,00"},
+		{"en", ConventionsFor("en").Currency(3, "XYZ"), "XYZ3.00"},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("%s: got %q, want %q", test.lang, test.got, test.want)
+		}
+	}
+}
+
+func TestFormatDate(t *testing.T) {
+	d := time.Date(2013, 3, 7, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		lang  string
+		style DateStyle
+		date  bool
+		want  string
+	}{
+		{"en", DateShort, true, "3/7/13"},
+		{"en", DateFull, true, "Thursday, March 7, 2013"},
+		{"en", DateShort, false, "3:04 PM"},
+		{"en-GB", DateMedium, true, "7 Mar 2013"},
+		{"vi", DateLong, true, "7 tháng 3, 2013"},
+		{"vi", DateMedium, false, "15:04:05"},
+		{"es", DateLong, true, "7 de marzo de 2013"},
+		{"ru", DateMedium, true, "7 мар. 2013 г."},
+		{"ja", DateFull, true, "2013年3月7日木曜日"},
+		{"en", DateStyle(-1), true, "Mar 7, 2013"},
+		{"en", DateStyle(4), false, "3:04:05 PM"},
+	}
+	for _, test := range tests {
+		c := ConventionsFor(test.lang)
+		got := c.Time(d, test.style)
+		if test.date {
+			got = c.Date(d, test.style)
+		}
+		if got != test.want {
+			t.Errorf("%s %d: got %q, want %q", test.lang, test.style, got, test.want)
+		}
+	}
+	if got := ConventionsFor("en").FormatDate(d, "'at' h 'o''clock' a"); got != "at 3 o'clock PM" {
+		t.Errorf("quoted text: got %q", got)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package locale
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// FormatError is returned by Format when the text is malformed or some
+// placeholders have no argument.
+type FormatError struct {
+	Text    string
+	Missing []string
+	Msg     string
+}
+
+func (e *FormatError) Error() string {
+	if e.Msg != "" {
+		return "locale: " + e.Msg + " in " + fmt.Sprintf("%q", e.Text)
+	}
+	return "locale: missing argument " + strings.Join(e.Missing, ", ") +
+		" for " + fmt.Sprintf("%q", e.Text)
+}
+
+// Format replaces the named placeholders of s with the values of args:
+//
+//	Format("Hello, {name}!", map[string]interface{}{"name": "Gopher"})
+//
+// args is a map with string keys or a struct, or a pointer to them. The
+// struct fields are found by the name in their lang tag, else by the field
+// name. A dotted name like {user.Name} looks into the nested values. Write
+// {{ and }} for literal braces.
+//
+// The placeholders without argument are left in the result and reported in
+// a *FormatError.
+func Format(s string, args interface{}) (string, error) {
+	var (
+		buf     bytes.Buffer
+		missing []string
+	)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '{' && i+1 < len(s) && s[i+1] == '{':
+			buf.WriteByte('{')
+			i++
+		case c == '}' && i+1 < len(s) && s[i+1] == '}':
+			buf.WriteByte('}')
+			i++
+		case c == '}':
+			return "", &FormatError{Text: s, Msg: "unexpected }"}
+		case c == '{':
+			end := strings.IndexByte(s[i+1:], '}')
+			if end < 0 {
+				return "", &FormatError{Text: s, Msg: "unclosed {"}
+			}
+			name := strings.TrimSpace(s[i+1 : i+1+end])
+			if name == "" || strings.IndexByte(name, '{') >= 0 {
+				return "", &FormatError{Text: s, Msg: "invalid placeholder"}
+			}
+			i += end + 1
+			v, ok := lookupArg(args, name)
+			if !ok {
+				missing = append(missing, name)
+				buf.WriteString("{" + name + "}")
+				continue
+			}
+			fmt.Fprint(&buf, v)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	if len(missing) > 0 {
+		return buf.String(), &FormatError{Text: s, Missing: missing}
+	}
+	return buf.String(), nil
+}
+
+// lookupArg returns the value of the dotted name in args.
+func lookupArg(args interface{}, name string) (interface{}, bool) {
+	v := reflect.ValueOf(args)
+	for _, part := range strings.Split(name, ".") {
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return nil, false
+			}
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return nil, false
+			}
+			v = v.MapIndex(reflect.ValueOf(part).Convert(v.Type().Key()))
+		case reflect.Struct:
+			v = structField(v, part)
+		default:
+			return nil, false
+		}
+		if !v.IsValid() {
+			return nil, false
+		}
+	}
+	if !v.CanInterface() {
+		return nil, false
+	}
+	return v.Interface(), true
+}
+
+func structField(v reflect.Value, name string) reflect.Value {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("lang") == name {
+			return v.Field(i)
+		}
+	}
+	return v.FieldByName(name)
+}
+
+// Format returns the value of key in the current lang-set with the
+// placeholders replaced by args, see the Format function.
+func (l *Lang) Format(file, key string, args interface{}) (string, error) {
+	return l.FormatSet(l.defaultSet(), file, key, args)
+}
+
+// FormatSet likes Format but uses the given lang-set.
+func (l *Lang) FormatSet(set, file, key string, args interface{}) (string, error) {
+	return Format(l.LoadSet(set, file, key), args)
+}
@@ -0,0 +1,46 @@
+package locale
+
+import (
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	type user struct {
+		Name  string
+		Email string `lang:"mail"`
+	}
+	args := map[string]interface{}{
+		"name":  "Gopher",
+		"count": 3,
+		"user":  &user{"Ann", "ann@example.com"},
+	}
+	tests := map[string]string{
+		"Hello, {name}!":              "Hello, Gopher!",
+		"{count} new, {{literal}}":    "3 new, {literal}",
+		"{ name } and {user.Name}":    "Gopher and Ann",
+		"mail: {user.mail}":           "mail: ann@example.com",
+		"no placeholder":              "no placeholder",
+		"{user.Name}}} {{{user.Name}": "Ann} {Ann",
+	}
+	for s, want := range tests {
+		got, err := Format(s, args)
+		if err != nil || got != want {
+			t.Errorf("Format(%q) = %q, %v, want %q", s, got, err, want)
+		}
+	}
+
+	got, err := Format("Hi {name}, {age} {user.Phone}", args)
+	ferr, ok := err.(*FormatError)
+	if !ok || len(ferr.Missing) != 2 || ferr.Missing[0] != "age" || ferr.Missing[1] != "user.Phone" {
+		t.Errorf("missing args error = %v", err)
+	}
+	if got != "Hi Gopher, {age} {user.Phone}" {
+		t.Errorf("missing args result = %q", got)
+	}
+
+	for _, s := range []string{"open {name", "close }", "empty {}"} {
+		if _, err := Format(s, args); err == nil {
+			t.Errorf("Format(%q) want error", s)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package locale
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// LangEntry is an entry of a .lang file with its comments. An entry with an
+// empty Key is a comment block detached from the entries, like a header
+// followed by a blank line or the comments at the end of the file.
+type LangEntry struct {
+	Key   string
+	Value string
+	// Note is the text of the # comment lines just above the entry.
+	Note string
+	// Source is the source text the value was translated from, given by a
+	// "#| text" line, see ExportXLIFF.
+	Source string
+	// Review tells the value needs a review, given by a "#, needs-review"
+	// line.
+	Review bool
+}
+
+// parseLang reads a .lang file into a map, see ReadLang.
+func parseLang(name string, r io.Reader) (map[string]string, error) {
+	entries, err := ReadLang(name, r)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]string, len(entries))
+	for _, e := range entries {
+		if e.Key != "" {
+			m[e.Key] = e.Value
+		}
+	}
+	return m, nil
+}
+
+// ReadLang reads the entries of a .lang file in order, name is used in the
+// errors. Every line holds a key=value entry, the blank lines and the lines
+// starting with # are skipped. The key and the value are trimmed. In keys
+// and values \= is a literal =, \# a literal #, \n a newline, \t a tab and \\
+// a backslash, the other backslashes are kept as written.
+//
+// A line ending with an odd number of backslashes goes on at the next line
+// after a newline, the last backslash is dropped. Write \\ at the end of a
+// value ending with a backslash, like a Windows path C:\dir\\.
+//
+// The comment lines just above an entry belong to it: "#| text" gives its
+// Source, "#, needs-review" its Review flag and the others its Note. The
+// comment lines followed by a blank line or the end of the file are kept in
+// an entry with an empty Key.
+func ReadLang(name string, r io.Reader) ([]LangEntry, error) {
+	var (
+		entries []LangEntry
+		br      = bufio.NewReader(r)
+		entry   bytes.Buffer
+		comment LangEntry
+		lineNo  int
+		startNo int
+		more    bool
+	)
+	flush := func() error {
+		k, v, msg := splitEntry(entry.String())
+		if msg != "" {
+			return fmt.Errorf("locale: %s:%d: %s", name, startNo, msg)
+		}
+		comment.Key, comment.Value = k, v
+		entries = append(entries, comment)
+		comment = LangEntry{}
+		entry.Reset()
+		return nil
+	}
+	detach := func() {
+		if comment != (LangEntry{}) {
+			entries = append(entries, comment)
+			comment = LangEntry{}
+		}
+	}
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("locale: cannot read %s: %s", name, err.Error())
+		}
+		if line == "" && err == io.EOF {
+			break
+		}
+		lineNo++
+		if lineNo == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		line = strings.TrimSpace(line)
+
+		switch {
+		case more:
+			entry.WriteByte('\n')
+		case line == "":
+			detach()
+			continue
+		case line[0] == '#':
+			comment.addComment(line)
+			continue
+		default:
+			startNo = lineNo
+		}
+		more = continued(line)
+		if more {
+			line = line[:len(line)-1]
+		}
+		entry.WriteString(line)
+		if !more {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if more {
+		if err := flush(); err != nil {
+			return nil, err
+		}
+	}
+	detach()
+	return entries, nil
+}
+
+// addComment adds a comment line to the entry.
+func (e *LangEntry) addComment(line string) {
+	switch {
+	case strings.HasPrefix(line, "#|"):
+		var buf bytes.Buffer
+		s := strings.TrimSpace(line[2:])
+		for i := 0; i < len(s); i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+				unescape(&buf, s[i])
+				continue
+			}
+			buf.WriteByte(s[i])
+		}
+		e.Source = buf.String()
+	case strings.HasPrefix(line, "#,"):
+		for _, flag := range strings.Split(line[2:], ",") {
+			if strings.TrimSpace(flag) == "needs-review" {
+				e.Review = true
+			}
+		}
+	default:
+		if e.Note != "" {
+			e.Note += "\n"
+		}
+		e.Note += strings.TrimSpace(line[1:])
+	}
+}
+
+// WriteLang writes the entries in the .lang format read by ReadLang, the
+// entries with an empty Key are written as comments followed by a blank
+// line.
+func WriteLang(w io.Writer, entries []LangEntry) error {
+	var buf bytes.Buffer
+	for i, e := range entries {
+		if i > 0 && (e.Key == "" || e.Note != "" || e.Source != "" || e.Review) && !bytes.HasSuffix(buf.Bytes(), []byte("\n\n")) {
+			buf.WriteByte('\n')
+		}
+		if e.Note != "" {
+			for _, line := range strings.Split(e.Note, "\n") {
+				buf.WriteString(strings.TrimSpace("# "+line) + "\n")
+			}
+		}
+		if e.Review {
+			buf.WriteString("#, needs-review\n")
+		}
+		if e.Source != "" {
+			buf.WriteString("#| " + EscapeLang(e.Source, false) + "\n")
+		}
+		if e.Key == "" {
+			if i < len(entries)-1 {
+				buf.WriteByte('\n')
+			}
+			continue
+		}
+		buf.WriteString(EscapeLang(e.Key, true) + "=" + EscapeLang(e.Value, false) + "\n")
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// EscapeLang escapes s for a .lang file, the = only in keys.
+func EscapeLang(s string, key bool) string {
+	s = strings.NewReplacer("\\", "\\\\", "\n", "\\n", "\t", "\\t").Replace(s)
+	if key {
+		s = strings.Replace(s, "=", "\\=", -1)
+	}
+	if strings.HasPrefix(s, "#") {
+		s = "\\" + s
+	}
+	return s
+}
+
+// continued reports whether line ends with an unescaped backslash.
+func continued(line string) bool {
+	n := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+// splitEntry splits an entry at the first unescaped = and unescapes the key
+// and the value. msg describes the error if the entry is malformed.
+func splitEntry(s string) (key, value, msg string) {
+	var buf bytes.Buffer
+	keyDone := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+			unescape(&buf, s[i])
+		case c == '=' && !keyDone:
+			key = strings.TrimSpace(buf.String())
+			buf.Reset()
+			keyDone = true
+			// skip the spaces after =
+			for i+1 < len(s) && (s[i+1] == ' ' || s[i+1] == '\t') {
+				i++
+			}
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	if !keyDone {
+		return "", "", "missing = in entry"
+	}
+	if key == "" {
+		return "", "", "empty key"
+	}
+	return key, buf.String(), ""
+}
+
+// unescape writes the byte escaped by \c to buf. An unknown escape is kept
+// as written, so a value like C:\dir reads as is.
+func unescape(buf *bytes.Buffer, c byte) {
+	switch c {
+	case '=', '#', '\\':
+		buf.WriteByte(c)
+	case 'n':
+		buf.WriteByte('\n')
+	case 't':
+		buf.WriteByte('\t')
+	default:
+		buf.WriteByte('\\')
+		buf.WriteByte(c)
+	}
+}
@@ -0,0 +1,115 @@
+package locale
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLang(t *testing.T) {
+	src := "\ufeff# comment\n" +
+		"\n" +
+		"  hi = Hello!  \n" +
+		"a\\=b=c=d\n" +
+		"multi=one\\n\\ttwo\n" +
+		"long = First line\\\n" +
+		"       second line\n" +
+		"slash=C:\\\\\n" +
+		"hash=\\#1\n" +
+		"empty=\n" +
+		"last=no newline"
+	m, err := parseLang("en/index.lang", strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"hi":    "Hello!",
+		"a=b":   "c=d",
+		"multi": "one\n\ttwo",
+		"long":  "First line\nsecond line",
+		"slash": "C:\\",
+		"hash":  "#1",
+		"empty": "",
+		"last":  "no newline",
+	}
+	if len(m) != len(want) {
+		t.Errorf("got %d entries, want %d: %q", len(m), len(want), m)
+	}
+	for k, v := range want {
+		if m[k] != v {
+			t.Errorf("%s = %q, want %q", k, m[k], v)
+		}
+	}
+}
+
+func TestReadLangComments(t *testing.T) {
+	src := "# header\n# second line\n\n" +
+		"# hi note\nhi=Hello\n\n" +
+		"# detached\n\n" +
+		"bye=Goodbye\n# trailing"
+	entries, err := ReadLang("en/index.lang", strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []LangEntry{
+		{Note: "header\nsecond line"},
+		{Key: "hi", Value: "Hello", Note: "hi note"},
+		{Note: "detached"},
+		{Key: "bye", Value: "Goodbye"},
+		{Note: "trailing"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("got %+v\nwant %+v", entries, want)
+	}
+	m, err := parseLang("en/index.lang", strings.NewReader(src))
+	if err != nil || len(m) != 2 {
+		t.Errorf("parseLang = %q, %v", m, err)
+	}
+
+	var buf bytes.Buffer
+	if err = WriteLang(&buf, entries); err != nil {
+		t.Fatal(err)
+	}
+	out := "# header\n# second line\n\n# hi note\nhi=Hello\n\n# detached\n\nbye=Goodbye\n\n# trailing\n"
+	if buf.String() != out {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), out)
+	}
+	if again, _ := ReadLang("en/index.lang", &buf); !reflect.DeepEqual(again, want) {
+		t.Errorf("read back %+v", again)
+	}
+}
+
+func TestParseLangBackslash(t *testing.T) {
+	src := "path=C:\\dir\\sub\nre=\\d+\\.\\w\ntail=C:\\dir\\\\\nnext=1\n#| a\\qb\nx=y\n"
+	entries, err := ReadLang("en/index.lang", strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []LangEntry{
+		{Key: "path", Value: `C:\dir\sub`},
+		{Key: "re", Value: `\d+\.\w`},
+		{Key: "tail", Value: `C:\dir\`},
+		{Key: "next", Value: "1"},
+		{Key: "x", Value: "y", Source: `a\qb`},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("got  %+v\nwant %+v", entries, want)
+	}
+}
+
+func TestParseLangErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"hi=Hello\nno equal sign\n", "en/index.lang:2: missing ="},
+		{"# c\n\n=value\n", "en/index.lang:3: empty key"},
+	}
+	for _, test := range tests {
+		_, err := parseLang("en/index.lang", strings.NewReader(test.src))
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%q: got error %v, want %q", test.src, err, test.want)
+		}
+	}
+}
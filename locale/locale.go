@@ -21,6 +21,19 @@ content mostly use. The .lang file have a basic struct like this:
 	key1=value1
 	key2=value2
 
+The blank lines and the lines starting with # are skipped, the spaces around
+keys and values are trimmed. Write \= for a = in a key, \n for a newline, \#
+for a leading # and \\ for a backslash, the other backslashes are kept as
+written, so write \\ to end a value with a backslash. A value ending with a
+single \ goes on at the next line:
+
+	# the home page
+	title = Welcome
+	intro = First line\
+	        second line
+
+A malformed line makes Parse fail with the file and line number in the error.
+
 For real example, in the en/index.lang we may have
 	hi=Hello!
 And in vi/index.lang, we habe:
@@ -29,118 +42,328 @@ Then you can use the package like this:
 	lang := locale.NewLang("path/to/your/languages")
 	lang.Parse("en")
 	lang.Load("index.lang", "hi") // return "Hello!"
+
+Numbers, amounts and dates are formatted in the conventions of the language
+(see Conventions), from the bundled CLDR data:
+
+	lang.Number(1234567.5, 2)      // "1,234,567.50" in en, "1.234.567,50" in vi
+	lang.Currency(250000, "VND")   // "250.000 ₫" in vi
+	lang.Date(t, locale.DateLong)  // "March 7, 2013" in en
+
+A value missing in a lang-set is looked up in the less specific set (vi for
+vi-VN) and then in the default set. Match and Negotiator choose the set of a
+request from the URL prefix, a cookie or the Accept-Language header:
+
+	n := locale.NewNegotiator(lang)
+	set := n.Negotiate(r) // "vi" for "Accept-Language: vi-VN,vi;q=0.9"
+
+A lang-set may also hold gettext catalogues, .po or .mo files, used like the
+.lang files with the msgid as key, see ContextKey for the msgctxt. The
+msgstr[i] of a plural message is the i-th plural form of the lang-set
+language, a catalogue whose Plural-Forms header has another nplurals is an
+error.
+
+Plural values are stored with the CLDR plural form as a key suffix, the form
+is chosen by the rule of the lang-set language (see PluralRuleFor):
+
+	items.one=item
+	items.other=items
+
+	lang.Plural("index.lang", "items", 1) // return "item"
+	lang.Plural("index.lang", "items", 5) // return "items"
+
+Values may have named placeholders, filled from a map or a struct by Format:
+
+	welcome=Hello, {name}! You have {count} new messages.
+
+	lang.Format("index.lang", "welcome", map[string]interface{}{
+		"name":  "Gopher",
+		"count": 3,
+	})
+
+Watch reloads a lang-set when its folder changes, keeping the old content if
+the new files cannot be parsed:
+
+	lang.Watch()
+	defer lang.Close()
+
+ExportXLIFF, ReadXLIFF and ImportXLIFF hand the lang-sets to the translators
+working with XLIFF 1.2 or 2.0, the notes and the source texts of the
+translations are kept as comments of the .lang entries, see ReadLang.
+
+EnablePseudo adds a pseudo lang-set generated from another one, with accented
+and longer texts, to spot the hard-coded strings and the truncations:
+
+	lang.EnablePseudo(locale.PseudoSet, "en")
+
+NewLangFS reads the lang-sets from an fs.FS instead, so they can be embedded
+in the binary:
+
+	//go:embed languages
+	var files embed.FS
+
+	sub, _ := fs.Sub(files, "languages")
+	lang := locale.NewLangFS(sub)
 */
 package locale
 
 import (
-	"bufio"
-	"bytes"
+	"fmt"
+	"github.com/howeyc/fsnotify"
 	"github.com/kidstuff/toys/util/errs"
+	"io/fs"
+	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
-// Lang manages the locale system
+// Lang manages the locale system. It is safe for concurrent use, the
+// lookups read an immutable snapshot of the parsed lang-sets without locking
+// and Parse, SetDefault and Reload replace the snapshot.
 type Lang struct {
-	root    string
+	root    string // empty if the source is not a folder
+	fsys    fs.FS
+	snap    atomic.Value // *snapshot
+	watcher *fsnotify.Watcher
+	mux     sync.Mutex // serializes the writers
+}
+
+// snapshot holds the parsed lang-sets, it is never changed once stored.
+type snapshot struct {
 	current string
 	set     map[string]map[string]map[string]string
+	pseudo  map[string]string // pseudo lang-set to its source
 }
 
 // NewLang returns a new Lang iwth the given language folder
 func NewLang(root string) *Lang {
-	l := &Lang{}
+	l := NewLangFS(os.DirFS(root))
 	l.root = root
-	l.set = make(map[string]map[string]map[string]string)
 	return l
 }
 
-// readln returns a single line (without the ending \n)
-// from the input buffered reader.
-// An error is returned if there is an error with the
-// buffered reader.
-func readln(r *bufio.Reader) ([]byte, error) {
-	var (
-		isPrefix bool  = true
-		err      error = nil
-		line, ln []byte
-	)
-	for isPrefix && err == nil {
-		line, isPrefix, err = r.ReadLine()
-		ln = append(ln, line...)
+// NewLangFS returns a new Lang reading the lang-sets from fsys, like an
+// embed.FS, with the same layout as the language folder. Such a Lang cannot
+// Watch its source.
+func NewLangFS(fsys fs.FS) *Lang {
+	l := &Lang{}
+	l.fsys = fsys
+	l.snap.Store(&snapshot{set: make(map[string]map[string]map[string]string)})
+	return l
+}
+
+// load returns the current snapshot.
+func (l *Lang) load() *snapshot {
+	return l.snap.Load().(*snapshot)
+}
+
+// update stores a copy of the snapshot changed by fn.
+func (l *Lang) update(fn func(s *snapshot)) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	old := l.load()
+	s := &snapshot{current: old.current}
+	s.set = make(map[string]map[string]map[string]string, len(old.set)+1)
+	for k, v := range old.set {
+		s.set[k] = v
+	}
+	s.pseudo = make(map[string]string, len(old.pseudo))
+	for k, v := range old.pseudo {
+		s.pseudo[k] = v
 	}
-	return ln, err
+	fn(s)
+	for set, source := range s.pseudo {
+		s.set[set] = pseudoFiles(s.set[source])
+	}
+	l.snap.Store(s)
+}
+
+// setOf returns the parsed files of a lang-set.
+func (l *Lang) setOf(set string) map[string]map[string]string {
+	return l.load().set[set]
+}
+
+// defaultSet returns the name of the default lang-set.
+func (l *Lang) defaultSet() string {
+	return l.load().current
 }
 
 // SetDefault make a lang-set to be default
 func (l *Lang) SetDefault(set string) error {
-	_, ok := l.set[set]
-	if !ok {
+	if l.setOf(set) == nil {
 		err := l.Parse(set)
 		if err != nil {
 			return err
 		}
 	}
-	l.current = set
+	l.update(func(s *snapshot) {
+		s.current = set
+	})
 	return nil
 }
 
 // Parse parses the lang-set and cache them
 func (l *Lang) Parse(set string) error {
-	setFolder := filepath.Join(l.root, set)
+	m, err := l.parseSet(set)
+	if err != nil {
+		return err
+	}
+	l.update(func(s *snapshot) {
+		s.set[set] = m
+		s.current = set
+	})
+
+	l.mux.Lock()
+	w := l.watcher
+	l.mux.Unlock()
+	if w != nil {
+		if err = w.Watch(filepath.Join(l.root, set)); err != nil {
+			return errs.Err(err, "lang: error trying watching language set folder")
+		}
+	}
+	return nil
+}
 
-	setroot, err := os.Open(setFolder)
+// Reload parses the lang-set again and replaces its content. The old content
+// is kept if the lang-set cannot be parsed.
+func (l *Lang) Reload(set string) error {
+	m, err := l.parseSet(set)
 	if err != nil {
-		return errs.New("lang: cannot open language set folder")
+		return err
 	}
-	defer setroot.Close()
+	l.update(func(s *snapshot) {
+		s.set[set] = m
+	})
+	return nil
+}
 
-	files, err := setroot.Readdir(-1)
+// parseSet reads the files of the lang-set folder.
+func (l *Lang) parseSet(set string) (map[string]map[string]string, error) {
+	if !fs.ValidPath(set) || set == "." {
+		return nil, errs.New("lang: invalid language set name")
+	}
+	files, err := fs.ReadDir(l.fsys, set)
 	if err != nil {
-		return errs.New("lang: cannot list file in language set folder")
+		return nil, errs.New("lang: cannot list file in language set folder")
 	}
 
-	l.set[set] = make(map[string]map[string]string)
+	content := make(map[string]map[string]string)
 	for _, file := range files {
-		if !file.IsDir() {
-			//read file
-			f, err := os.Open(filepath.Join(setFolder, file.Name()))
-			if err != nil {
-				continue
-			}
-			defer f.Close()
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+		m, err := parseFile(l.fsys, path.Join(set, file.Name()), set)
+		if err != nil {
+			return nil, err
+		}
+		if m != nil {
+			content[file.Name()] = m
+		}
+	}
+	return content, nil
+}
 
-			r := bufio.NewReader(f)
-			s, e := readln(r)
-			if e != nil {
-				continue
-			}
+// parseFile reads a .lang, .po or .mo file of the lang-set. The other files
+// are ignored.
+func parseFile(fsys fs.FS, name, set string) (map[string]string, error) {
+	ext := path.Ext(name)
+	if ext != ".lang" && ext != ".po" && ext != ".mo" {
+		return nil, nil
+	}
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("locale: cannot open %s: %s", name, err.Error())
+	}
+	defer f.Close()
 
-			m := make(map[string]string)
-			for e == nil {
-				pos := bytes.Index(s, []byte{0x3d})
-				m[string(s[:pos])] = string(s[pos+1:])
-				s, e = readln(r)
-			}
-			l.set[set][file.Name()] = m
+	if ext == ".lang" {
+		return parseLang(name, f)
+	}
+	return parseCatalog(name, f, pluralForms(set))
+}
+
+// Watch starts watching the parsed lang-set folders, and the ones parsed
+// later. A changed lang-set is reloaded, if it cannot be parsed the old
+// content is kept and the error is logged. Only a Lang created by NewLang
+// can watch its folder.
+func (l *Lang) Watch() error {
+	if l.root == "" {
+		return errs.New("lang: the language source cannot be watched")
+	}
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if l.watcher != nil {
+		return nil
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errs.Err(err, "lang: error trying watching language folder")
+	}
+	snap := l.load()
+	for set := range snap.set {
+		if _, ok := snap.pseudo[set]; ok {
+			continue
+		}
+		if err = w.Watch(filepath.Join(l.root, set)); err != nil {
+			w.Close()
+			return errs.Err(err, "lang: error trying watching language set folder")
 		}
 	}
-	l.current = set
+	l.watcher = w
+
+	go func(w *fsnotify.Watcher) {
+		for {
+			select {
+			case ev, ok := <-w.Event:
+				if !ok {
+					return
+				}
+				set := filepath.Base(filepath.Dir(ev.Name))
+				if err := l.Reload(set); err != nil {
+					log.Printf("locale: keep the old content of %s\n%s", set, err.Error())
+				}
+			case err, ok := <-w.Error:
+				if !ok {
+					return
+				}
+				log.Printf("locale: error watching language folder\n%s", err.Error())
+			}
+		}
+	}(w)
 	return nil
 }
 
+// Close stops watching the language folder.
+func (l *Lang) Close() error {
+	l.mux.Lock()
+	w := l.watcher
+	l.watcher = nil
+	l.mux.Unlock()
+	if w == nil {
+		return nil
+	}
+	return w.Close()
+}
+
 // Load returns a value base on file name and key
 func (l *Lang) Load(file, key string) string {
-	return l.LoadSet(l.current, file, key)
+	return l.LoadSet(l.defaultSet(), file, key)
 }
 
 // LoadSet returns a value base on file, set name and key.
-// It will return the key if no value exist.
+// A missing value is looked up in the less specific sets (vi for vi-VN) and
+// then in the default set. It will return the key if no value exist.
 func (l *Lang) LoadSet(set, file, key string) string {
-	v, ok := l.set[set][file][key]
-	if !ok {
-		return key
+	snap := l.load()
+	for _, fb := range snap.fallbacks(set) {
+		if v, ok := snap.set[fb][file][key]; ok {
+			return v
+		}
 	}
-
-	return v
+	return key
 }
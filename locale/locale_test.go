@@ -0,0 +1,155 @@
+package locale
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+func TestReload(t *testing.T) {
+	root, err := ioutil.TempDir("", "locale")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	dir := filepath.Join(root, "en")
+	os.Mkdir(dir, 0755)
+	write := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("index.lang", "hi=Hello!\n")
+
+	l := NewLang(root)
+	if err = l.Parse("en"); err != nil {
+		t.Fatal(err)
+	}
+	if err = l.Watch(); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	write("index.lang", "hi=Hi!\n")
+	if err = l.Reload("en"); err != nil {
+		t.Fatal(err)
+	}
+	if got := l.Load("index.lang", "hi"); got != "Hi!" {
+		t.Errorf("after reload got %q, want %q", got, "Hi!")
+	}
+
+	write("index.lang", "hi=Hey!\n")
+	write("broken.po", "msgid \"unterminated\n")
+	if err = l.Reload("en"); err == nil {
+		t.Error("want error for a broken catalogue")
+	}
+	if got := l.Load("index.lang", "hi"); got != "Hi!" {
+		t.Errorf("after failed reload got %q, want the old %q", got, "Hi!")
+	}
+}
+
+func TestConcurrentLang(t *testing.T) {
+	root, err := ioutil.TempDir("", "locale")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	sets := map[string]string{
+		"en":    "hi=Hello!\nitems.one=item\nitems.other=items\n",
+		"vi":    "hi=Xin chào!\n",
+		"vi-VN": "bye=Tạm biệt\n",
+	}
+	for set, content := range sets {
+		os.Mkdir(filepath.Join(root, set), 0755)
+		err = ioutil.WriteFile(filepath.Join(root, set, "index.lang"), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	l := NewLang(root)
+	if err = l.SetDefault("en"); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				if got := l.LoadSet("vi-VN", "index.lang", "hi"); got != "Xin chào!" && got != "Hello!" {
+					t.Errorf("LoadSet = %q", got)
+					return
+				}
+				if got := l.Load("index.lang", "hi"); got != "Hello!" && got != "Xin chào!" {
+					t.Errorf("Load = %q", got)
+					return
+				}
+				l.Plural("index.lang", "items", j)
+				l.Localizer("").Format("index.lang", "hi", nil)
+				l.Sets()
+				l.Values("en", "index.lang")
+			}
+		}()
+	}
+	for _, set := range []string{"vi", "vi-VN", "en"} {
+		wg.Add(1)
+		go func(set string) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if err := l.Parse(set); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := l.Reload(set); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := l.SetDefault("en"); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(set)
+	}
+	wg.Wait()
+
+	if got := l.Load("index.lang", "hi"); got != "Hello!" {
+		t.Errorf("Load = %q, want %q", got, "Hello!")
+	}
+	if got := l.Sets(); len(got) != 3 {
+		t.Errorf("Sets = %q", got)
+	}
+}
+
+func TestNewLangFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"en/index.lang": {Data: []byte("hi=Hello!\n")},
+		"vi/index.lang": {Data: []byte("hi=Xin chào!\n")},
+		"vi/README":     {Data: []byte("not a lang file")},
+	}
+	l := NewLangFS(fsys)
+	if err := l.Parse("vi"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.SetDefault("en"); err != nil {
+		t.Fatal(err)
+	}
+	if got := l.LoadSet("vi", "index.lang", "hi"); got != "Xin chào!" {
+		t.Errorf("LoadSet = %q", got)
+	}
+	if got := l.Files("vi"); len(got) != 1 {
+		t.Errorf("Files = %q, want only index.lang", got)
+	}
+	if err := l.Parse("../en"); err == nil {
+		t.Error("want error for an invalid lang-set name")
+	}
+	if err := l.Watch(); err == nil {
+		t.Error("want error watching an fs.FS")
+	}
+}
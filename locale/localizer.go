@@ -0,0 +1,52 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package locale
+
+import (
+	"net/http"
+)
+
+// Localizer translates to a single lang-set. Its set never changes, so one
+// Localizer per request can be used while other requests use other
+// languages.
+type Localizer struct {
+	lang *Lang
+	set  string
+}
+
+// Localizer returns a Localizer for set, or for the default lang-set if set
+// is empty.
+func (l *Lang) Localizer(set string) *Localizer {
+	if set == "" {
+		set = l.defaultSet()
+	}
+	return &Localizer{lang: l, set: set}
+}
+
+// Localizer returns the Localizer of the lang-set negotiated for r.
+func (n *Negotiator) Localizer(r *http.Request) *Localizer {
+	return n.Lang.Localizer(n.Negotiate(r))
+}
+
+// Set returns the name of the lang-set.
+func (lc *Localizer) Set() string {
+	return lc.set
+}
+
+// Load returns a value base on file name and key, see Lang.LoadSet.
+func (lc *Localizer) Load(file, key string) string {
+	return lc.lang.LoadSet(lc.set, file, key)
+}
+
+// Plural returns the plural form of key for n, see Lang.PluralSet.
+func (lc *Localizer) Plural(file, key string, n interface{}) string {
+	return lc.lang.PluralSet(lc.set, file, key, n)
+}
+
+// Format returns the value of key with the placeholders replaced by args,
+// see Lang.FormatSet.
+func (lc *Localizer) Format(file, key string, args interface{}) (string, error) {
+	return lc.lang.FormatSet(lc.set, file, key, args)
+}
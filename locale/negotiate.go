@@ -0,0 +1,219 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package locale
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// normTag returns the lower case language tag with - as separator.
+func normTag(tag string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(tag), "_", "-", -1))
+}
+
+// parentTags returns the less specific tags of tag, "zh-hant-tw" gives
+// "zh-hant" and "zh".
+func parentTags(tag string) []string {
+	var tags []string
+	for {
+		pos := strings.LastIndex(tag, "-")
+		if pos < 0 {
+			return tags
+		}
+		tag = tag[:pos]
+		tags = append(tags, tag)
+	}
+}
+
+// ParseAcceptLanguage returns the language tags of an Accept-Language header
+// ordered by their quality. The tags with q=0 and * are left out.
+func ParseAcceptLanguage(header string) []string {
+	type item struct {
+		tag string
+		q   float64
+	}
+	var items []item
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				v, err := strconv.ParseFloat(param[2:], 64)
+				if err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			items = append(items, item{tag, q})
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].q > items[j].q
+	})
+	tags := make([]string, len(items))
+	for i := range items {
+		tags[i] = items[i].tag
+	}
+	return tags
+}
+
+// Sets returns the sorted names of the parsed lang-sets.
+func (l *Lang) Sets() []string {
+	return l.load().sets()
+}
+
+// sets returns the sorted names of the lang-sets.
+func (s *snapshot) sets() []string {
+	sets := make([]string, 0, len(s.set))
+	for set := range s.set {
+		sets = append(sets, set)
+	}
+	sort.Strings(sets)
+	return sets
+}
+
+// Files returns the sorted names of the files of a parsed lang-set.
+func (l *Lang) Files(set string) []string {
+	m := l.setOf(set)
+	files := make([]string, 0, len(m))
+	for file := range m {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// Values returns a copy of the values of a file of a parsed lang-set.
+func (l *Lang) Values(set, file string) map[string]string {
+	m := l.setOf(set)[file]
+	values := make(map[string]string, len(m))
+	for k, v := range m {
+		values[k] = v
+	}
+	return values
+}
+
+// Match returns the parsed lang-set that best matches the preferred tags,
+// given in order. For each tag it looks for the same tag, then a less
+// specific one (vi-VN matches vi) and then a regional variant (en matches
+// en-US). It returns an empty string if no set matches.
+func (l *Lang) Match(tags ...string) string {
+	sets := l.Sets()
+	byTag := make(map[string]string, len(sets))
+	for _, set := range sets {
+		byTag[normTag(set)] = set
+	}
+
+	for _, tag := range tags {
+		tag = normTag(tag)
+		if tag == "" {
+			continue
+		}
+		if set, ok := byTag[tag]; ok {
+			return set
+		}
+		for _, parent := range parentTags(tag) {
+			if set, ok := byTag[parent]; ok {
+				return set
+			}
+		}
+		base := strings.SplitN(tag, "-", 2)[0]
+		for _, set := range sets {
+			if strings.HasPrefix(normTag(set), base+"-") {
+				return set
+			}
+		}
+	}
+	return ""
+}
+
+// Negotiator chooses the lang-set of a request.
+type Negotiator struct {
+	Lang *Lang
+	// Cookie is the name of the cookie holding the language, empty to
+	// skip the cookie.
+	Cookie string
+	// URLPrefix tells whether the first segment of the URL path, like
+	// /vi/about, chooses the language.
+	URLPrefix bool
+}
+
+// NewNegotiator returns a Negotiator using the URL prefix and the "lang"
+// cookie.
+func NewNegotiator(l *Lang) *Negotiator {
+	return &Negotiator{Lang: l, Cookie: "lang", URLPrefix: true}
+}
+
+// SplitPath returns the lang-set named by the first segment of path and the
+// rest of the path. set is empty if the segment is not a lang-set.
+func (n *Negotiator) SplitPath(path string) (set, rest string) {
+	p := strings.TrimPrefix(path, "/")
+	seg := p
+	if pos := strings.IndexByte(p, '/'); pos >= 0 {
+		seg = p[:pos]
+	}
+	if seg == "" {
+		return "", path
+	}
+	for _, s := range n.Lang.Sets() {
+		if normTag(s) == normTag(seg) {
+			rest = p[len(seg):]
+			if rest == "" {
+				rest = "/"
+			}
+			return s, rest
+		}
+	}
+	return "", path
+}
+
+// Negotiate returns the lang-set for r. The URL prefix comes first, then the
+// cookie and the Accept-Language header. The default lang-set is returned
+// if nothing matches.
+func (n *Negotiator) Negotiate(r *http.Request) string {
+	if n.URLPrefix {
+		if set, _ := n.SplitPath(r.URL.Path); set != "" {
+			return set
+		}
+	}
+	if n.Cookie != "" {
+		if c, err := r.Cookie(n.Cookie); err == nil {
+			if set := n.Lang.Match(c.Value); set != "" {
+				return set
+			}
+		}
+	}
+	if set := n.Lang.Match(ParseAcceptLanguage(r.Header.Get("Accept-Language"))...); set != "" {
+		return set
+	}
+	return n.Lang.defaultSet()
+}
+
+// fallbacks returns the lang-sets to look a key up in: set, its less
+// specific parsed sets and the default set.
+func (s *snapshot) fallbacks(set string) []string {
+	chain := []string{set}
+	sets := s.sets()
+	for _, parent := range parentTags(normTag(set)) {
+		for _, name := range sets {
+			if normTag(name) == parent {
+				chain = append(chain, name)
+			}
+		}
+	}
+	if def := s.current; def != "" && def != set {
+		chain = append(chain, def)
+	}
+	return chain
+}
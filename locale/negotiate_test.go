@@ -0,0 +1,102 @@
+package locale
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func testLang() *Lang {
+	l := NewLang("")
+	l.update(func(s *snapshot) {
+		s.set["en"] = map[string]map[string]string{
+			"index.lang": {"hi": "Hello!", "bye": "Bye!", "items.one": "item", "items.other": "items"},
+		}
+		s.set["vi"] = map[string]map[string]string{
+			"index.lang": {"hi": "Xin chào!", "items": "mục"},
+		}
+		s.set["vi-VN"] = map[string]map[string]string{
+			"index.lang": {"hi": "Chào!"},
+		}
+		s.set["pt-BR"] = map[string]map[string]string{}
+		s.current = "en"
+	})
+	return l
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	got := ParseAcceptLanguage("fr;q=0.5, vi-VN,*;q=0.1 , en;q=0.8,de;q=0")
+	want := []string{"vi-VN", "en", "fr"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	l := testLang()
+	tests := []struct {
+		tags []string
+		want string
+	}{
+		{[]string{"vi-VN"}, "vi-VN"},
+		{[]string{"vi_vn"}, "vi-VN"},
+		{[]string{"vi-US"}, "vi"},
+		{[]string{"en-GB"}, "en"},
+		{[]string{"pt"}, "pt-BR"},
+		{[]string{"fr", "en"}, "en"},
+		{[]string{"fr"}, ""},
+	}
+	for _, test := range tests {
+		if got := l.Match(test.tags...); got != test.want {
+			t.Errorf("Match(%v) = %q, want %q", test.tags, got, test.want)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	n := NewNegotiator(testLang())
+	r, _ := http.NewRequest("GET", "/vi/about", nil)
+	r.Header.Set("Accept-Language", "en")
+	r.AddCookie(&http.Cookie{Name: "lang", Value: "pt"})
+	if got := n.Negotiate(r); got != "vi" {
+		t.Errorf("URL prefix: got %q", got)
+	}
+	if set, rest := n.SplitPath("/vi/about"); set != "vi" || rest != "/about" {
+		t.Errorf("SplitPath = %q %q", set, rest)
+	}
+
+	r.URL.Path = "/about"
+	if got := n.Negotiate(r); got != "pt-BR" {
+		t.Errorf("cookie: got %q", got)
+	}
+	n.Cookie = ""
+	r.Header.Set("Accept-Language", "fr, vi-VN;q=0.9")
+	if got := n.Negotiate(r); got != "vi-VN" {
+		t.Errorf("Accept-Language: got %q", got)
+	}
+	r.Header.Set("Accept-Language", "fr")
+	if got := n.Negotiate(r); got != "en" {
+		t.Errorf("default: got %q", got)
+	}
+}
+
+func TestFallback(t *testing.T) {
+	l := testLang()
+	tests := map[string]string{
+		"hi":      "Chào!",
+		"items":   "mục",
+		"bye":     "Bye!",
+		"missing": "missing",
+	}
+	for key, want := range tests {
+		if got := l.LoadSet("vi-VN", "index.lang", key); got != want {
+			t.Errorf("LoadSet(vi-VN, %s) = %q, want %q", key, got, want)
+		}
+	}
+	if got := l.PluralSet("vi-VN", "index.lang", "items", 1); got != "mục" {
+		t.Errorf("PluralSet(vi-VN) = %q", got)
+	}
+	if got := l.PluralSet("fr", "index.lang", "items", 1); got != "item" {
+		t.Errorf("PluralSet(fr) = %q", got)
+	}
+}
@@ -0,0 +1,322 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package locale
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PluralForm is a CLDR plural category.
+type PluralForm string
+
+const (
+	Zero  PluralForm = "zero"
+	One   PluralForm = "one"
+	Two   PluralForm = "two"
+	Few   PluralForm = "few"
+	Many  PluralForm = "many"
+	Other PluralForm = "other"
+)
+
+// Operands are the CLDR plural operands of a number:
+//
+//	N absolute value of the number
+//	I integer digits
+//	V number of visible fraction digits, with trailing zeros
+//	W number of visible fraction digits, without trailing zeros
+//	F visible fraction digits, with trailing zeros
+//	T visible fraction digits, without trailing zeros
+//
+// "1.50" has N=1.5 I=1 V=2 W=1 F=50 T=5.
+type Operands struct {
+	N    float64
+	I    int64
+	V, W int64
+	F, T int64
+}
+
+// NewOperands returns the Operands of n. n may be any integer or float type,
+// or a decimal string to keep the trailing zeros of the fraction ("1.0").
+func NewOperands(n interface{}) (Operands, error) {
+	rv := reflect.ValueOf(n)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := rv.Int()
+		if i < 0 {
+			i = -i
+		}
+		return Operands{N: float64(i), I: i}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i := int64(rv.Uint())
+		return Operands{N: float64(i), I: i}, nil
+	case reflect.Float32, reflect.Float64:
+		return parseOperands(strconv.FormatFloat(math.Abs(rv.Float()), 'f', -1, 64))
+	case reflect.String:
+		return parseOperands(strings.TrimPrefix(strings.TrimSpace(rv.String()), "-"))
+	}
+	return Operands{}, fmt.Errorf("locale: cannot get plural operands of %T", n)
+}
+
+func parseOperands(s string) (Operands, error) {
+	var o Operands
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return o, fmt.Errorf("locale: invalid number %q", s)
+	}
+	o.N = n
+
+	intPart, frac := s, ""
+	if pos := strings.IndexByte(s, '.'); pos >= 0 {
+		intPart, frac = s[:pos], s[pos+1:]
+	}
+	if intPart != "" {
+		if o.I, err = strconv.ParseInt(intPart, 10, 64); err != nil {
+			o.I = int64(n)
+		}
+	}
+	if frac != "" {
+		o.V = int64(len(frac))
+		o.F, _ = strconv.ParseInt(frac, 10, 64)
+		trimmed := strings.TrimRight(frac, "0")
+		o.W = int64(len(trimmed))
+		if trimmed != "" {
+			o.T, _ = strconv.ParseInt(trimmed, 10, 64)
+		}
+	}
+	return o, nil
+}
+
+// PluralRule returns the plural form of a number.
+type PluralRule func(o Operands) PluralForm
+
+var (
+	pluralRules = make(map[string]PluralRule)
+	pluralMux   sync.RWMutex
+)
+
+// RegisterPluralRule sets the plural rule of a language. It replaces the
+// bundled rule if any.
+func RegisterPluralRule(lang string, r PluralRule) {
+	pluralMux.Lock()
+	pluralRules[strings.ToLower(lang)] = r
+	pluralMux.Unlock()
+}
+
+// PluralRuleFor returns the plural rule of a language tag. A regional tag
+// like pt-PT uses the rule of pt if it has none. The languages without a
+// known rule only have the Other form.
+func PluralRuleFor(lang string) PluralRule {
+	lang = strings.ToLower(strings.Replace(lang, "_", "-", -1))
+	pluralMux.RLock()
+	defer pluralMux.RUnlock()
+	for {
+		if r, ok := pluralRules[lang]; ok {
+			return r
+		}
+		pos := strings.LastIndex(lang, "-")
+		if pos < 0 {
+			return pluralOther
+		}
+		lang = lang[:pos]
+	}
+}
+
+func init() {
+	for _, lang := range []string{"id", "ja", "km", "ko", "lo", "ms", "my", "th", "vi", "zh"} {
+		pluralRules[lang] = pluralOther
+	}
+	for _, lang := range []string{"ca", "de", "en", "et", "fi", "gl", "it", "nl", "sv", "ur"} {
+		pluralRules[lang] = pluralOneInt
+	}
+	for _, lang := range []string{"bg", "el", "es", "hu", "nb", "tr"} {
+		pluralRules[lang] = pluralOneN
+	}
+	for _, lang := range []string{"bn", "fa", "hi", "zu"} {
+		pluralRules[lang] = pluralHi
+	}
+	pluralRules["fr"] = pluralFr
+	pluralRules["pt"] = pluralFr
+	pluralRules["pt-pt"] = pluralOneInt
+	pluralRules["ru"] = pluralRu
+	pluralRules["uk"] = pluralRu
+	pluralRules["pl"] = pluralPl
+	pluralRules["cs"] = pluralCs
+	pluralRules["sk"] = pluralCs
+	pluralRules["ro"] = pluralRo
+	pluralRules["he"] = pluralHe
+	pluralRules["ar"] = pluralAr
+}
+
+func inRange(n, from, to int64) bool {
+	return n >= from && n <= to
+}
+
+func pluralOther(o Operands) PluralForm {
+	return Other
+}
+
+// one: i = 1 and v = 0
+func pluralOneInt(o Operands) PluralForm {
+	if o.I == 1 && o.V == 0 {
+		return One
+	}
+	return Other
+}
+
+// one: n = 1
+func pluralOneN(o Operands) PluralForm {
+	if o.N == 1 {
+		return One
+	}
+	return Other
+}
+
+// one: i = 0 or n = 1
+func pluralHi(o Operands) PluralForm {
+	if o.I == 0 || o.N == 1 {
+		return One
+	}
+	return Other
+}
+
+// one: i = 0,1
+func pluralFr(o Operands) PluralForm {
+	if o.I == 0 || o.I == 1 {
+		return One
+	}
+	return Other
+}
+
+func pluralRu(o Operands) PluralForm {
+	if o.V != 0 {
+		return Other
+	}
+	i10, i100 := o.I%10, o.I%100
+	switch {
+	case i10 == 1 && i100 != 11:
+		return One
+	case inRange(i10, 2, 4) && !inRange(i100, 12, 14):
+		return Few
+	}
+	return Many
+}
+
+func pluralPl(o Operands) PluralForm {
+	if o.V != 0 {
+		return Other
+	}
+	i10, i100 := o.I%10, o.I%100
+	switch {
+	case o.I == 1:
+		return One
+	case inRange(i10, 2, 4) && !inRange(i100, 12, 14):
+		return Few
+	}
+	return Many
+}
+
+func pluralCs(o Operands) PluralForm {
+	switch {
+	case o.V != 0:
+		return Many
+	case o.I == 1:
+		return One
+	case inRange(o.I, 2, 4):
+		return Few
+	}
+	return Other
+}
+
+func pluralRo(o Operands) PluralForm {
+	switch {
+	case o.I == 1 && o.V == 0:
+		return One
+	case o.V != 0 || o.N == 0 || inRange(o.I%100, 1, 19):
+		return Few
+	}
+	return Other
+}
+
+func pluralHe(o Operands) PluralForm {
+	switch {
+	case o.I == 1 && o.V == 0 || o.I == 0 && o.V != 0:
+		return One
+	case o.I == 2 && o.V == 0:
+		return Two
+	}
+	return Other
+}
+
+func pluralAr(o Operands) PluralForm {
+	if o.N != math.Trunc(o.N) {
+		return Other
+	}
+	n100 := o.I % 100
+	switch {
+	case o.N == 0:
+		return Zero
+	case o.N == 1:
+		return One
+	case o.N == 2:
+		return Two
+	case inRange(n100, 3, 10):
+		return Few
+	case inRange(n100, 11, 99):
+		return Many
+	}
+	return Other
+}
+
+var formOrder = []PluralForm{Zero, One, Two, Few, Many, Other}
+
+// pluralForms returns the forms the rule of lang gives to integers, in the
+// CLDR order. gettext catalogues list their plural translations so.
+func pluralForms(lang string) []PluralForm {
+	rule := PluralRuleFor(lang)
+	seen := make(map[PluralForm]bool)
+	for n := int64(0); n < 1000; n++ {
+		seen[rule(Operands{N: float64(n), I: n})] = true
+	}
+	var forms []PluralForm
+	for _, f := range formOrder {
+		if seen[f] {
+			forms = append(forms, f)
+		}
+	}
+	return forms
+}
+
+// Plural returns the value of the plural form of key for n in the current
+// lang-set, see PluralSet.
+func (l *Lang) Plural(file, key string, n interface{}) string {
+	return l.PluralSet(l.defaultSet(), file, key, n)
+}
+
+// PluralSet returns the value of the plural form of key for n. The forms are
+// stored as key.one, key.few, key.other etc. The rule is chosen by the name
+// of the lang-set. If the form is missing key.other then key are used, then
+// the fallback sets like LoadSet does.
+func (l *Lang) PluralSet(set, file, key string, n interface{}) string {
+	o, err := NewOperands(n)
+	snap := l.load()
+	for _, fb := range snap.fallbacks(set) {
+		form := Other
+		if err == nil {
+			form = PluralRuleFor(snap.language(fb))(o)
+		}
+		m := snap.set[fb][file]
+		for _, k := range []string{key + "." + string(form), key + "." + string(Other), key} {
+			if v, ok := m[k]; ok {
+				return v
+			}
+		}
+	}
+	return key
+}
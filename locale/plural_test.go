@@ -0,0 +1,110 @@
+package locale
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPluralRules(t *testing.T) {
+	tests := []struct {
+		lang string
+		n    interface{}
+		want PluralForm
+	}{
+		{"en", 1, One},
+		{"en", 0, Other},
+		{"en", "1.0", Other},
+		{"en-US", 2, Other},
+		{"vi", 1, Other},
+		{"vi_VN", 1, Other},
+		{"fr", 0, One},
+		{"fr", 1.5, One},
+		{"fr", 2, Other},
+		{"pt-PT", 0, Other},
+		{"ru", 1, One},
+		{"ru", 11, Many},
+		{"ru", 22, Few},
+		{"ru", 25, Many},
+		{"ru", 1.5, Other},
+		{"pl", 1, One},
+		{"pl", 21, Many},
+		{"pl", 24, Few},
+		{"cs", 3, Few},
+		{"cs", "0.5", Many},
+		{"ro", 0, Few},
+		{"ro", 119, Few},
+		{"ro", 20, Other},
+		{"ar", 0, Zero},
+		{"ar", 2, Two},
+		{"ar", 105, Few},
+		{"ar", 111, Many},
+		{"ar", 100, Other},
+		{"xx", 1, Other},
+	}
+	for _, test := range tests {
+		o, err := NewOperands(test.n)
+		if err != nil {
+			t.Errorf("NewOperands(%#v): %v", test.n, err)
+			continue
+		}
+		if got := PluralRuleFor(test.lang)(o); got != test.want {
+			t.Errorf("%s %#v = %s, want %s", test.lang, test.n, got, test.want)
+		}
+	}
+}
+
+func TestOperands(t *testing.T) {
+	o, err := NewOperands("-1.50")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Operands{N: 1.5, I: 1, V: 2, W: 1, F: 50, T: 5}
+	if o != want {
+		t.Errorf("got %+v, want %+v", o, want)
+	}
+	if _, err = NewOperands("many"); err == nil {
+		t.Error("want error for an invalid number")
+	}
+}
+
+func TestLangPlural(t *testing.T) {
+	root, err := ioutil.TempDir("", "locale")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	sets := map[string]string{
+		"en": "items.one=item\nitems.other=items\n",
+		"vi": "items=mục\n",
+	}
+	for set, content := range sets {
+		os.Mkdir(filepath.Join(root, set), 0755)
+		err = ioutil.WriteFile(filepath.Join(root, set, "index.lang"), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l := NewLang(root)
+	if err = l.Parse("vi"); err != nil {
+		t.Fatal(err)
+	}
+	if err = l.SetDefault("en"); err != nil {
+		t.Fatal(err)
+	}
+	if got := l.Plural("index.lang", "items", 1); got != "item" {
+		t.Errorf("Plural(1) = %q", got)
+	}
+	if got := l.Plural("index.lang", "items", 3); got != "items" {
+		t.Errorf("Plural(3) = %q", got)
+	}
+	if got := l.PluralSet("vi", "index.lang", "items", 1); got != "mục" {
+		t.Errorf("PluralSet(vi, 1) = %q", got)
+	}
+	if got := l.Plural("index.lang", "missing", 1); got != "missing" {
+		t.Errorf("Plural(missing) = %q", got)
+	}
+}
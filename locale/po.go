@@ -0,0 +1,493 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package locale
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ContextKey returns the key of a gettext message with a msgctxt.
+func ContextKey(ctxt, id string) string {
+	return ctxt + "\x04" + id
+}
+
+// catalogEntry is a message of a gettext catalogue.
+type catalogEntry struct {
+	ctxt   string
+	id     string
+	plural bool
+	strs   []string
+	fuzzy  bool
+}
+
+// store adds the translations of e to m. The plural translations are stored
+// by the forms of the lang-set in order, the last one is also the other form.
+// The fuzzy and untranslated messages are left out like msgfmt does.
+func (e *catalogEntry) store(m map[string]string, forms []PluralForm) {
+	if e.id == "" || e.fuzzy || len(e.strs) == 0 || e.strs[0] == "" {
+		return
+	}
+	key := e.id
+	if e.ctxt != "" {
+		key = ContextKey(e.ctxt, e.id)
+	}
+	m[key] = e.strs[0]
+	if !e.plural {
+		return
+	}
+	for i, f := range forms {
+		if i < len(e.strs) && e.strs[i] != "" {
+			m[key+"."+string(f)] = e.strs[i]
+		}
+	}
+	if _, ok := m[key+"."+string(Other)]; !ok {
+		m[key+"."+string(Other)] = e.strs[len(e.strs)-1]
+	}
+}
+
+// checkHeader returns an error if the Plural-Forms of the catalogue header
+// do not have one msgstr per form of the lang-set, the msgstr[i] would be
+// stored as the wrong forms. A header without Plural-Forms is accepted.
+func checkHeader(name, header string, forms []PluralForm) error {
+	if forms == nil {
+		return nil
+	}
+	for _, line := range strings.Split(header, "\n") {
+		pos := strings.IndexByte(line, ':')
+		if pos < 0 || !strings.EqualFold(strings.TrimSpace(line[:pos]), "Plural-Forms") {
+			continue
+		}
+		for _, part := range strings.Split(line[pos+1:], ";") {
+			kv := strings.SplitN(part, "=", 2)
+			if len(kv) != 2 || strings.TrimSpace(kv[0]) != "nplurals" {
+				continue
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+			if err != nil {
+				return fmt.Errorf("locale: %s: invalid Plural-Forms %s", name, strings.TrimSpace(line[pos+1:]))
+			}
+			if n != len(forms) {
+				return fmt.Errorf("locale: %s: Plural-Forms has nplurals=%d, the language has %d plural forms", name, n, len(forms))
+			}
+			return nil
+		}
+		return fmt.Errorf("locale: %s: Plural-Forms without nplurals", name)
+	}
+	return nil
+}
+
+func parseCatalog(name string, r io.Reader, forms []PluralForm) (map[string]string, error) {
+	if filepath.Ext(name) == ".mo" {
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("locale: cannot read %s: %s", name, err.Error())
+		}
+		return parseMO(name, b, forms)
+	}
+	return parsePO(name, r, forms)
+}
+
+// parsePO reads a .po catalogue.
+func parsePO(name string, r io.Reader, forms []PluralForm) (map[string]string, error) {
+	m := make(map[string]string)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+
+	var (
+		e      catalogEntry
+		target *string // the string continued by the "..." lines
+		inStr  bool    // msgstr seen, a new keyword starts a new entry
+		lineNo int
+		header string
+	)
+	errorf := func(msg string) error {
+		return fmt.Errorf("locale: %s:%d: %s", name, lineNo, msg)
+	}
+	flush := func() {
+		if e.id == "" && e.ctxt == "" && len(e.strs) > 0 {
+			header = e.strs[0]
+		}
+		e.store(m, forms)
+		e = catalogEntry{}
+		target = nil
+		inStr = false
+	}
+
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "":
+			flush()
+			continue
+		case strings.HasPrefix(line, "#,"):
+			if inStr {
+				flush()
+			}
+			for _, flag := range strings.Split(line[2:], ",") {
+				if strings.TrimSpace(flag) == "fuzzy" {
+					e.fuzzy = true
+				}
+			}
+			continue
+		case strings.HasPrefix(line, "#"):
+			// other comments and obsolete #~ entries
+			continue
+		case strings.HasPrefix(line, `"`):
+			if target == nil {
+				return nil, errorf("string without keyword")
+			}
+			s, ok := poUnquote(line)
+			if !ok {
+				return nil, errorf("invalid string " + line)
+			}
+			*target += s
+			continue
+		}
+
+		pos := strings.IndexAny(line, " \t")
+		if pos < 0 {
+			return nil, errorf("missing string after " + line)
+		}
+		keyword, rest := line[:pos], strings.TrimSpace(line[pos:])
+		s, ok := poUnquote(rest)
+		if !ok {
+			return nil, errorf("invalid string " + rest)
+		}
+
+		switch {
+		case keyword == "msgctxt" || keyword == "msgid":
+			if inStr {
+				flush()
+			}
+			if keyword == "msgctxt" {
+				e.ctxt = s
+				target = &e.ctxt
+			} else {
+				e.id = s
+				target = &e.id
+			}
+		case keyword == "msgid_plural":
+			e.plural = true
+			target = nil
+		case keyword == "msgstr" || strings.HasPrefix(keyword, "msgstr["):
+			i := 0
+			if keyword != "msgstr" {
+				var err error
+				i, err = strconv.Atoi(strings.TrimSuffix(keyword[len("msgstr["):], "]"))
+				if err != nil || i < 0 || !strings.HasSuffix(keyword, "]") {
+					return nil, errorf("invalid keyword " + keyword)
+				}
+			}
+			for len(e.strs) <= i {
+				e.strs = append(e.strs, "")
+			}
+			e.strs[i] = s
+			target = &e.strs[i]
+			inStr = true
+		default:
+			return nil, errorf("unknown keyword " + keyword)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("locale: cannot read %s: %s", name, err.Error())
+	}
+	flush()
+	if err := checkHeader(name, header, forms); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// parseMO reads a compiled .mo catalogue.
+func parseMO(name string, b []byte, forms []PluralForm) (map[string]string, error) {
+	invalid := fmt.Errorf("locale: %s: invalid .mo file", name)
+	if len(b) < 20 {
+		return nil, invalid
+	}
+	var order binary.ByteOrder
+	switch {
+	case binary.LittleEndian.Uint32(b) == 0x950412de:
+		order = binary.LittleEndian
+	case binary.BigEndian.Uint32(b) == 0x950412de:
+		order = binary.BigEndian
+	default:
+		return nil, invalid
+	}
+	n := int(order.Uint32(b[8:]))
+	origTable := int(order.Uint32(b[12:]))
+	transTable := int(order.Uint32(b[16:]))
+
+	str := func(table, i int) (string, bool) {
+		pos := table + 8*i
+		if pos < 0 || pos+8 > len(b) {
+			return "", false
+		}
+		length := int(order.Uint32(b[pos:]))
+		offset := int(order.Uint32(b[pos+4:]))
+		if offset < 0 || length < 0 || offset+length > len(b) {
+			return "", false
+		}
+		return string(b[offset : offset+length]), true
+	}
+
+	m := make(map[string]string)
+	for i := 0; i < n; i++ {
+		orig, ok1 := str(origTable, i)
+		trans, ok2 := str(transTable, i)
+		if !ok1 || !ok2 {
+			return nil, invalid
+		}
+		e := catalogEntry{strs: strings.Split(trans, "\x00")}
+		if pos := strings.IndexByte(orig, '\x04'); pos >= 0 {
+			e.ctxt, orig = orig[:pos], orig[pos+1:]
+		}
+		ids := strings.Split(orig, "\x00")
+		e.id = ids[0]
+		e.plural = len(ids) > 1
+		if e.id == "" && e.ctxt == "" {
+			if err := checkHeader(name, trans, forms); err != nil {
+				return nil, err
+			}
+		}
+		e.store(m, forms)
+	}
+	return m, nil
+}
+
+// pluralHeaders are the gettext Plural-Forms of the bundled plural rules,
+// their msgstr are in the order of pluralForms.
+var pluralHeaders = map[string]string{
+	"other": "nplurals=1; plural=0;",
+	"en":    "nplurals=2; plural=(n != 1);",
+	"fr":    "nplurals=2; plural=(n > 1);",
+	"ru":    "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<12 || n%100>14) ? 1 : 2);",
+	"pl":    "nplurals=3; plural=(n==1 ? 0 : n%10>=2 && n%10<=4 && (n%100<12 || n%100>14) ? 1 : 2);",
+	"cs":    "nplurals=3; plural=(n==1 ? 0 : n>=2 && n<=4 ? 1 : 2);",
+	"ro":    "nplurals=3; plural=(n==1 ? 0 : (n==0 || (n%100>0 && n%100<20)) ? 1 : 2);",
+	"he":    "nplurals=3; plural=(n==1 ? 0 : n==2 ? 1 : 2);",
+	"ar":    "nplurals=6; plural=(n==0 ? 0 : n==1 ? 1 : n==2 ? 2 : n%100>=3 && n%100<=10 ? 3 : n%100>=11 ? 4 : 5);",
+}
+
+// pluralHeader returns the Plural-Forms of lang, or an empty string.
+func pluralHeader(lang string) string {
+	lang = normTag(lang)
+	base := strings.SplitN(lang, "-", 2)[0]
+	switch base {
+	case "uk":
+		base = "ru"
+	case "sk":
+		base = "cs"
+	case "pt", "bn", "fa", "hi", "zu":
+		base = "fr"
+	}
+	if lang == "pt-pt" {
+		base = "en"
+	}
+	if h, ok := pluralHeaders[base]; ok {
+		return h
+	}
+	forms := pluralForms(lang)
+	switch {
+	case len(forms) == 1:
+		return pluralHeaders["other"]
+	case len(forms) == 2 && forms[0] == One && PluralRuleFor(lang)(Operands{}) == Other:
+		// one is only 1 for the integers, like English
+		return pluralHeaders["en"]
+	}
+	return ""
+}
+
+// ExportPO writes the values of a .lang file of set as a .po catalogue. The
+// keys are the msgid, the values of the default lang-set are added as
+// extracted comments for the translators. The plural values, key.one,
+// key.other etc, are written as a plural message.
+func (l *Lang) ExportPO(w io.Writer, set, file string) error {
+	m, ok := l.setOf(set)[file]
+	if !ok {
+		return fmt.Errorf("locale: %s has no file %s", set, file)
+	}
+	forms := pluralForms(set)
+	def := l.defaultSet()
+	source := l.setOf(def)[file]
+
+	// group the plural forms under their key
+	keys := make(map[string]bool)
+	plurals := make(map[string]bool)
+	for k := range m {
+		base, form := splitForm(k)
+		if form != "" {
+			plurals[base] = true
+			keys[base] = true
+		} else {
+			keys[k] = true
+		}
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var buf bytes.Buffer
+	buf.WriteString("msgid \"\"\nmsgstr \"\"\n")
+	buf.WriteString("\"Content-Type: text/plain; charset=UTF-8\\n\"\n")
+	fmt.Fprintf(&buf, "\"Language: %s\\n\"\n", strings.Replace(set, "-", "_", -1))
+	if h := pluralHeader(set); h != "" {
+		fmt.Fprintf(&buf, "\"Plural-Forms: %s\\n\"\n", h)
+	}
+
+	for _, k := range sorted {
+		buf.WriteByte('\n')
+		if def != "" && def != set {
+			if src, ok := source[k]; ok {
+				writeComment(&buf, src)
+			} else if src, ok := source[k+"."+string(Other)]; ok {
+				writeComment(&buf, src)
+			}
+		}
+		id, ctxt := k, ""
+		if pos := strings.IndexByte(k, '\x04'); pos >= 0 {
+			ctxt, id = k[:pos], k[pos+1:]
+			writePOString(&buf, "msgctxt", ctxt)
+		}
+		writePOString(&buf, "msgid", id)
+		if !plurals[k] {
+			writePOString(&buf, "msgstr", m[k])
+			continue
+		}
+		writePOString(&buf, "msgid_plural", id)
+		for i, f := range forms {
+			v, ok := m[k+"."+string(f)]
+			if !ok {
+				v = m[k+"."+string(Other)]
+			}
+			writePOString(&buf, "msgstr["+strconv.Itoa(i)+"]", v)
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// splitForm splits "items.one" into "items" and "one". form is empty if
+// the key does not end with a plural form.
+func splitForm(k string) (base, form string) {
+	pos := strings.LastIndex(k, ".")
+	if pos < 0 {
+		return k, ""
+	}
+	for _, f := range formOrder {
+		if k[pos+1:] == string(f) {
+			return k[:pos], k[pos+1:]
+		}
+	}
+	return k, ""
+}
+
+func writeComment(buf *bytes.Buffer, text string) {
+	for _, line := range strings.Split(text, "\n") {
+		buf.WriteString("#. " + line + "\n")
+	}
+}
+
+// writePOString writes a keyword and its string, split after the new lines.
+func writePOString(buf *bytes.Buffer, keyword, s string) {
+	if !strings.Contains(strings.TrimSuffix(s, "\n"), "\n") {
+		fmt.Fprintf(buf, "%s %s\n", keyword, poQuote(s))
+		return
+	}
+	fmt.Fprintf(buf, "%s \"\"\n", keyword)
+	lines := strings.SplitAfter(s, "\n")
+	for _, line := range lines {
+		if line != "" {
+			buf.WriteString(poQuote(line) + "\n")
+		}
+	}
+}
+
+// poUnquote returns the text of the quoted PO string s. It reads the C
+// escapes like msgfmt does: \" \\ \n \t \r \a \b \f \v, up to 3 octal digits
+// and \x with up to 2 hex digits. The other escapes are kept as written.
+func poUnquote(s string) (string, bool) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", false
+	}
+	s = s[1 : len(s)-1]
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '"' {
+			return "", false
+		}
+		if c != '\\' {
+			buf.WriteByte(c)
+			continue
+		}
+		i++
+		if i == len(s) {
+			// the closing quote is escaped
+			return "", false
+		}
+		switch c = s[i]; c {
+		case '"', '\\':
+			buf.WriteByte(c)
+		case 'n':
+			buf.WriteByte('\n')
+		case 't':
+			buf.WriteByte('\t')
+		case 'r':
+			buf.WriteByte('\r')
+		case 'a':
+			buf.WriteByte('\a')
+		case 'b':
+			buf.WriteByte('\b')
+		case 'f':
+			buf.WriteByte('\f')
+		case 'v':
+			buf.WriteByte('\v')
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			n := 0
+			for j := 0; j < 3 && i < len(s) && '0' <= s[i] && s[i] <= '7'; j++ {
+				n = n*8 + int(s[i]-'0')
+				i++
+			}
+			i--
+			buf.WriteByte(byte(n))
+		case 'x':
+			n, j := 0, 0
+			for ; j < 2 && i+1 < len(s); j++ {
+				d := strings.IndexByte("0123456789abcdef", s[i+1]|0x20)
+				if d < 0 {
+					break
+				}
+				n = n*16 + d
+				i++
+			}
+			if j == 0 {
+				buf.WriteString(`\x`)
+				continue
+			}
+			buf.WriteByte(byte(n))
+		default:
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String(), true
+}
+
+func poQuote(s string) string {
+	r := strings.NewReplacer("\\", `\\`, "\"", `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+	return `"` + r.Replace(s) + `"`
+}
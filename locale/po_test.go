@@ -0,0 +1,200 @@
+package locale
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const samplePO = `# Russian translation
+msgid ""
+msgstr ""
+"Language: ru\n"
+"Plural-Forms: nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<12 || n%100>14) ? 1 : 2);\n"
+
+#: index.tmpl:3
+msgid "hi"
+msgstr "Привет"
+
+msgctxt "menu"
+msgid "Open"
+msgstr "Открыть"
+#, fuzzy
+msgid "guess"
+msgstr "догадка"
+
+msgid "untranslated"
+msgstr ""
+
+msgid "multi"
+msgstr ""
+"line 1\n"
+"line 2"
+
+msgid "file"
+msgid_plural "files"
+msgstr[0] "файл"
+msgstr[1] "файла"
+msgstr[2] "файлов"
+
+#~ msgid "old"
+#~ msgstr "старый"
+`
+
+func TestParsePO(t *testing.T) {
+	m, err := parsePO("ru.po", strings.NewReader(samplePO), pluralForms("ru"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"hi":                       "Привет",
+		ContextKey("menu", "Open"): "Открыть",
+		"multi":                    "line 1\nline 2",
+		"file":                     "файл",
+		"file.one":                 "файл",
+		"file.few":                 "файла",
+		"file.many":                "файлов",
+		"file.other":               "файлов",
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("got %q\nwant %q", m, want)
+	}
+
+	_, err = parsePO("bad.po", strings.NewReader("msgid \"a\"\nmsgstr \"b\nmsgid\n"), nil)
+	if err == nil || !strings.HasPrefix(err.Error(), "locale: bad.po:2:") {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestPOUnquote(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`"plain"`, "plain"},
+		{`"a\"b\\c"`, `a"b\c`},
+		{`"\n\t\r\a\b\f\v"`, "\n\t\r\a\b\f\v"},
+		{`"\101\0\1012"`, "A\x00A2"},
+		{`"\x41\x4a2\xg"`, "AJ2\\xg"},
+		{`"it\'s \d+ \?"`, `it\'s \d+ \?`},
+		{`"привет"`, "привет"},
+	}
+	for _, test := range tests {
+		if got, ok := poUnquote(test.in); !ok || got != test.want {
+			t.Errorf("poUnquote(%s) = %q, %v, want %q", test.in, got, ok, test.want)
+		}
+	}
+	for _, in := range []string{`"a`, `a"`, `"a"b"`, `"a\"`, `"`} {
+		if got, ok := poUnquote(in); ok {
+			t.Errorf("poUnquote(%s) = %q, want an error", in, got)
+		}
+	}
+
+	m, err := parsePO("en.po", strings.NewReader("msgid \"path\"\nmsgstr \"C:\\dir \"\n\"it\\'s\"\n"), nil)
+	if err != nil || m["path"] != `C:\dir it\'s` {
+		t.Errorf("path = %q, %v", m["path"], err)
+	}
+}
+
+func TestParsePluralForms(t *testing.T) {
+	po := "msgid \"\"\nmsgstr \"\"\n\"Plural-Forms: nplurals=2; plural=(n != 1);\\n\"\n\n" +
+		"msgid \"file\"\nmsgid_plural \"files\"\nmsgstr[0] \"файл\"\nmsgstr[1] \"файлов\"\n"
+	_, err := parsePO("ru.po", strings.NewReader(po), pluralForms("ru"))
+	if err == nil || err.Error() != "locale: ru.po: Plural-Forms has nplurals=2, the language has 3 plural forms" {
+		t.Errorf("error = %v", err)
+	}
+	if _, err = parsePO("en.po", strings.NewReader(po), pluralForms("en")); err != nil {
+		t.Error(err)
+	}
+
+	b := buildMO([][2]string{{"", "Plural-Forms: nplurals=3; plural=(n==1 ? 0 : 1);\n"}})
+	if _, err = parseMO("fr.mo", b, pluralForms("fr")); err == nil {
+		t.Error("want error for nplurals=3 in a fr catalogue")
+	}
+}
+
+// buildMO returns a little endian .mo file of the messages.
+func buildMO(msgs [][2]string) []byte {
+	n := len(msgs)
+	var head, table, data bytes.Buffer
+	put := func(b *bytes.Buffer, v int) {
+		binary.Write(b, binary.LittleEndian, uint32(v))
+	}
+	start := 28 + 16*n
+	for col := 0; col < 2; col++ {
+		for _, msg := range msgs {
+			put(&table, len(msg[col]))
+			put(&table, start+data.Len())
+			data.WriteString(msg[col] + "\x00")
+		}
+	}
+	for _, v := range []int{0x950412de, 0, n, 28, 28 + 8*n, 0, 0} {
+		put(&head, v)
+	}
+	return append(append(head.Bytes(), table.Bytes()...), data.Bytes()...)
+}
+
+func TestParseMO(t *testing.T) {
+	b := buildMO([][2]string{
+		{"", "Plural-Forms: nplurals=2; plural=(n != 1);\n"},
+		{"menu\x04Open", "Ouvrir"},
+		{"file\x00files", "fichier\x00fichiers"},
+	})
+	m, err := parseMO("fr.mo", b, pluralForms("fr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		ContextKey("menu", "Open"): "Ouvrir",
+		"file":                     "fichier",
+		"file.one":                 "fichier",
+		"file.other":               "fichiers",
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("got %q\nwant %q", m, want)
+	}
+	if _, err = parseMO("bad.mo", b[:10], nil); err == nil {
+		t.Error("want error for a truncated file")
+	}
+}
+
+func TestExportPO(t *testing.T) {
+	l := testLang()
+	l.update(func(s *snapshot) {
+		s.set["ru"] = map[string]map[string]string{
+			"index.lang": {
+				"hi":         "Привет",
+				"items.one":  "товар",
+				"items.few":  "товара",
+				"items.many": "товаров",
+				"note":       "a \"quoted\"\nvalue",
+			},
+		}
+	})
+	var buf bytes.Buffer
+	if err := l.ExportPO(&buf, "ru", "index.lang"); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, s := range []string{
+		"\"Plural-Forms: nplurals=3;",
+		"#. Hello!\nmsgid \"hi\"\nmsgstr \"Привет\"\n",
+		"#. items\nmsgid \"items\"\nmsgid_plural \"items\"\nmsgstr[0] \"товар\"\nmsgstr[1] \"товара\"\nmsgstr[2] \"товаров\"\n",
+		"msgstr \"\"\n\"a \\\"quoted\\\"\\n\"\n\"value\"\n",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("missing %q in:\n%s", s, out)
+		}
+	}
+
+	m, err := parsePO("ru.po", strings.NewReader(out), pluralForms("ru"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range l.setOf("ru")["index.lang"] {
+		if m[k] != v {
+			t.Errorf("read back %s = %q, want %q", k, m[k], v)
+		}
+	}
+}
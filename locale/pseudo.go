@@ -0,0 +1,135 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package locale
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+)
+
+// PseudoSet is the usual name of the pseudo lang-set, see EnablePseudo.
+const PseudoSet = "qps-ploc"
+
+var pseudoChars = map[rune]rune{
+	'a': 'á', 'b': 'ƀ', 'c': 'ç', 'd': 'ð', 'e': 'é', 'f': 'ƒ', 'g': 'ĝ',
+	'h': 'ĥ', 'i': 'î', 'j': 'ĵ', 'k': 'ķ', 'l': 'ļ', 'm': 'ɱ', 'n': 'ñ',
+	'o': 'ö', 'p': 'þ', 'q': 'ǫ', 'r': 'ŕ', 's': 'š', 't': 'ţ', 'u': 'û',
+	'v': 'ṽ', 'w': 'ŵ', 'x': 'ẋ', 'y': 'ý', 'z': 'ž',
+	'A': 'Å', 'B': 'Ɓ', 'C': 'Ç', 'D': 'Ð', 'E': 'É', 'F': 'Ƒ', 'G': 'Ĝ',
+	'H': 'Ĥ', 'I': 'Î', 'J': 'Ĵ', 'K': 'Ķ', 'L': 'Ļ', 'M': 'Ṁ', 'N': 'Ñ',
+	'O': 'Ö', 'P': 'Þ', 'Q': 'Ǫ', 'R': 'Ŕ', 'S': 'Š', 'T': 'Ţ', 'U': 'Û',
+	'V': 'Ṽ', 'W': 'Ŵ', 'X': 'Ẋ', 'Y': 'Ý', 'Z': 'Ž',
+}
+
+// Pseudo returns s pseudo-localized: the letters are accented, the text is
+// made about 30% longer and put between brackets, like "[Ĥéļļö ~~]" for
+// "Hello". The {name} placeholders, the HTML tags and entities and the
+// printf verbs are kept as they are. The empty string is kept empty.
+func Pseudo(s string) string {
+	if s == "" {
+		return s
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	letters := 0
+	for i := 0; i < len(s); {
+		if n := pseudoSkip(s[i:]); n > 0 {
+			buf.WriteString(s[i : i+n])
+			i += n
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if p, ok := pseudoChars[r]; ok {
+			buf.WriteRune(p)
+			letters++
+		} else {
+			buf.WriteRune(r)
+		}
+		i += size
+	}
+	if pad := (letters + 2) / 3; pad > 0 {
+		buf.WriteString(" " + strings.Repeat("~", pad))
+	}
+	buf.WriteByte(']')
+	return buf.String()
+}
+
+// pseudoSkip returns the length of the placeholder, tag, entity or printf
+// verb at the start of s, or 0.
+func pseudoSkip(s string) int {
+	switch s[0] {
+	case '{':
+		if strings.HasPrefix(s, "{{") {
+			return 2
+		}
+		if end := strings.IndexByte(s, '}'); end > 0 {
+			return end + 1
+		}
+	case '}':
+		if strings.HasPrefix(s, "}}") {
+			return 2
+		}
+	case '<':
+		if end := strings.IndexByte(s, '>'); end > 0 {
+			return end + 1
+		}
+	case '&':
+		if end := strings.IndexByte(s, ';'); end > 1 && end < 10 &&
+			strings.IndexAny(s[1:end], " &<") < 0 {
+			return end + 1
+		}
+	case '%':
+		for i := 1; i < len(s); i++ {
+			c := s[i]
+			if c == '%' && i == 1 || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' {
+				return i + 1
+			}
+			if !strings.ContainsRune("+-# 0123456789.*[]", rune(c)) {
+				break
+			}
+		}
+	}
+	return 0
+}
+
+// pseudoFiles returns the pseudo-localized copy of the files of a lang-set.
+func pseudoFiles(files map[string]map[string]string) map[string]map[string]string {
+	m := make(map[string]map[string]string, len(files))
+	for file, values := range files {
+		pv := make(map[string]string, len(values))
+		for k, v := range values {
+			pv[k] = Pseudo(v)
+		}
+		m[file] = pv
+	}
+	return m
+}
+
+// EnablePseudo adds the pseudo lang-set set, PseudoSet for example, holding
+// the values of the source lang-set pseudo-localized, see Pseudo. An empty
+// source is the current default lang-set. The pseudo lang-set is generated
+// again whenever the source changes. Use it as any lang-set to find the
+// hard-coded strings and the truncated texts of the templates:
+//
+//	lang.EnablePseudo(locale.PseudoSet, "en")
+//	lang.LoadSet(locale.PseudoSet, "index.lang", "hi") // "[Ĥéļļö! ~~]"
+func (l *Lang) EnablePseudo(set, source string) {
+	l.update(func(s *snapshot) {
+		if source == "" {
+			source = s.current
+		}
+		s.pseudo[set] = source
+	})
+}
+
+// language returns the language of a lang-set, the source language for a
+// pseudo lang-set.
+func (s *snapshot) language(set string) string {
+	if source, ok := s.pseudo[set]; ok {
+		return source
+	}
+	return set
+}
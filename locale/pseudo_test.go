@@ -0,0 +1,44 @@
+package locale
+
+import (
+	"testing"
+)
+
+func TestPseudo(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"Hello", "[Ĥéļļö ~~]"},
+		{"Hi, {name}!", "[Ĥî, {name}! ~]"},
+		{"<b>Bye</b> &amp; %d {{x}}", "[<b>Ɓýé</b> &amp; %d {{ẋ}} ~~]"},
+		{"100%", "[100%]"},
+	}
+	for _, test := range tests {
+		if got := Pseudo(test.in); got != test.want {
+			t.Errorf("Pseudo(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestEnablePseudo(t *testing.T) {
+	l := testLang()
+	l.EnablePseudo(PseudoSet, "")
+	if got, want := l.LoadSet(PseudoSet, "index.lang", "hi"), "[Ĥéļļö! ~~]"; got != want {
+		t.Errorf("LoadSet = %q, want %q", got, want)
+	}
+	if got, want := l.PluralSet(PseudoSet, "index.lang", "items", 1), "[îţéɱ ~~]"; got != want {
+		t.Errorf("PluralSet = %q, want %q", got, want)
+	}
+
+	// the pseudo lang-set follows its source, even when it is the default
+	if err := l.SetDefault(PseudoSet); err != nil {
+		t.Fatal(err)
+	}
+	l.update(func(s *snapshot) {
+		s.set["en"] = map[string]map[string]string{"index.lang": {"hi": "Hi"}}
+	})
+	if got, want := l.Load("index.lang", "hi"), "[Ĥî ~]"; got != want {
+		t.Errorf("Load = %q, want %q", got, want)
+	}
+}
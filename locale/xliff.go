@@ -0,0 +1,372 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package locale
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// The XLIFF versions of ExportXLIFF.
+const (
+	XLIFF12 = "1.2"
+	XLIFF20 = "2.0"
+)
+
+// XLIFF is a XLIFF document read by ReadXLIFF.
+type XLIFF struct {
+	Version    string
+	SourceLang string
+	TargetLang string
+	Files      []XLIFFFile
+}
+
+// XLIFFFile is a file of a XLIFF document, Original is the name of the
+// .lang file.
+type XLIFFFile struct {
+	Original string
+	Units    []XLIFFUnit
+}
+
+// XLIFFUnit is a translation unit. Target is empty if the unit is not
+// translated yet.
+type XLIFFUnit struct {
+	Key    string
+	Source string
+	Target string
+	Note   string
+	Review bool
+}
+
+type xliffDoc struct {
+	XMLName xml.Name    `xml:"xliff"`
+	Xmlns   string      `xml:"xmlns,attr,omitempty"`
+	Version string      `xml:"version,attr"`
+	SrcLang string      `xml:"srcLang,attr,omitempty"`
+	TrgLang string      `xml:"trgLang,attr,omitempty"`
+	Files   []xliffFile `xml:"file"`
+}
+
+type xliffFile struct {
+	ID       string `xml:"id,attr,omitempty"`
+	Original string `xml:"original,attr"`
+	// 1.2
+	SourceLanguage string      `xml:"source-language,attr,omitempty"`
+	TargetLanguage string      `xml:"target-language,attr,omitempty"`
+	Datatype       string      `xml:"datatype,attr,omitempty"`
+	Body           *xliffBody  `xml:"body"`
+	Units          []xliffUnit `xml:"unit"` // 2.0
+}
+
+type xliffBody struct {
+	Units []xliffTransUnit `xml:"trans-unit"`
+}
+
+type xliffTransUnit struct {
+	ID      string       `xml:"id,attr"`
+	Resname string       `xml:"resname,attr,omitempty"`
+	Source  string       `xml:"source"`
+	Target  *xliffTarget `xml:"target"`
+	Notes   []string     `xml:"note"`
+}
+
+type xliffTarget struct {
+	State string `xml:"state,attr,omitempty"`
+	Text  string `xml:",chardata"`
+}
+
+type xliffUnit struct {
+	ID       string         `xml:"id,attr"`
+	Name     string         `xml:"name,attr,omitempty"`
+	Notes    *xliffNotes    `xml:"notes"`
+	Segments []xliffSegment `xml:"segment"`
+}
+
+type xliffNotes struct {
+	Notes []string `xml:"note"`
+}
+
+type xliffSegment struct {
+	State  string  `xml:"state,attr,omitempty"`
+	Source string  `xml:"source"`
+	Target *string `xml:"target"`
+}
+
+// entries returns the entries of a .lang file of a lang-set with their
+// comments, none if the file does not exist.
+func (l *Lang) entries(set, file string) ([]LangEntry, error) {
+	f, err := l.fsys.Open(path.Join(set, file))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("locale: cannot open %s/%s: %s", set, file, err.Error())
+	}
+	defer f.Close()
+	return ReadLang(path.Join(set, file), f)
+}
+
+// xliffUnits returns the units of a file to translate from source to
+// target, in the order of the source file.
+func (l *Lang) xliffUnits(source, target, file string) ([]XLIFFUnit, error) {
+	values := l.setOf(source)[file]
+	keys := make([]string, 0, len(values))
+	notes := make(map[string]string)
+	var srcEntries, trgEntries []LangEntry
+	if path.Ext(file) == ".lang" {
+		var err error
+		if srcEntries, err = l.entries(source, file); err != nil {
+			return nil, err
+		}
+		if trgEntries, err = l.entries(target, file); err != nil {
+			return nil, err
+		}
+	}
+	for _, e := range srcEntries {
+		if e.Key != "" {
+			keys = append(keys, e.Key)
+			notes[e.Key] = e.Note
+		}
+	}
+	if len(keys) != len(values) {
+		keys = keys[:0]
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+	}
+	meta := make(map[string]LangEntry)
+	for _, e := range trgEntries {
+		meta[e.Key] = e
+	}
+
+	translated := l.setOf(target)[file]
+	units := make([]XLIFFUnit, 0, len(keys))
+	for _, k := range keys {
+		u := XLIFFUnit{Key: k, Source: values[k], Note: notes[k]}
+		if v, ok := translated[k]; ok {
+			u.Target = v
+			e := meta[k]
+			u.Review = e.Review || e.Source != "" && e.Source != u.Source
+			if e.Note != "" {
+				u.Note = e.Note
+			}
+		}
+		units = append(units, u)
+	}
+	return units, nil
+}
+
+// ExportXLIFF writes the values of the files of the source lang-set and
+// their translations in the target lang-set as a XLIFF document of the
+// given version, XLIFF12 or XLIFF20. All the files of source are exported
+// if none is given.
+//
+// The notes are the comments of the entries of the target .lang file, else
+// of the source one. A translation is marked as needing review if its entry
+// has the needs-review flag, or if its source text, kept by ImportXLIFF,
+// changed since.
+func (l *Lang) ExportXLIFF(w io.Writer, version, source, target string, files ...string) error {
+	if version != XLIFF12 && version != XLIFF20 {
+		return fmt.Errorf("locale: unknown XLIFF version %s", version)
+	}
+	if l.setOf(source) == nil {
+		return fmt.Errorf("locale: no lang-set %s", source)
+	}
+	if len(files) == 0 {
+		files = l.Files(source)
+	}
+
+	doc := xliffDoc{Version: version}
+	if version == XLIFF12 {
+		doc.Xmlns = "urn:oasis:names:tc:xliff:document:1.2"
+	} else {
+		doc.Xmlns = "urn:oasis:names:tc:xliff:document:2.0"
+		doc.SrcLang, doc.TrgLang = source, target
+	}
+	for i, file := range files {
+		if _, ok := l.setOf(source)[file]; !ok {
+			return fmt.Errorf("locale: %s has no file %s", source, file)
+		}
+		units, err := l.xliffUnits(source, target, file)
+		if err != nil {
+			return err
+		}
+		f := xliffFile{Original: file}
+		if version == XLIFF12 {
+			f.SourceLanguage, f.TargetLanguage = source, target
+			f.Datatype = "plaintext"
+			f.Body = &xliffBody{}
+			for j, u := range units {
+				tu := xliffTransUnit{ID: fmt.Sprint(j + 1), Resname: u.Key, Source: u.Source}
+				tu.Target = &xliffTarget{State: "new"}
+				if u.Target != "" {
+					tu.Target.Text = u.Target
+					tu.Target.State = "translated"
+					if u.Review {
+						tu.Target.State = "needs-review-translation"
+					}
+				}
+				if u.Note != "" {
+					tu.Notes = []string{u.Note}
+				}
+				f.Body.Units = append(f.Body.Units, tu)
+			}
+		} else {
+			f.ID = fmt.Sprint("f", i+1)
+			for j, u := range units {
+				seg := xliffSegment{State: "initial", Source: u.Source}
+				if u.Target != "" {
+					t := u.Target
+					seg.Target = &t
+					if !u.Review {
+						seg.State = "translated"
+					}
+				}
+				xu := xliffUnit{ID: fmt.Sprint("u", j+1), Name: u.Key, Segments: []xliffSegment{seg}}
+				if u.Note != "" {
+					xu.Notes = &xliffNotes{[]string{u.Note}}
+				}
+				f.Units = append(f.Units, xu)
+			}
+		}
+		doc.Files = append(doc.Files, f)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// ReadXLIFF reads a XLIFF 1.2 or 2.0 document.
+func ReadXLIFF(r io.Reader) (*XLIFF, error) {
+	var doc xliffDoc
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("locale: cannot read XLIFF: %s", err.Error())
+	}
+	x := &XLIFF{Version: doc.Version, SourceLang: doc.SrcLang, TargetLang: doc.TrgLang}
+	switch {
+	case strings.HasPrefix(doc.Version, "1."):
+		for _, f := range doc.Files {
+			if x.SourceLang == "" {
+				x.SourceLang, x.TargetLang = f.SourceLanguage, f.TargetLanguage
+			}
+			file := XLIFFFile{Original: f.Original}
+			if f.Body != nil {
+				for _, tu := range f.Body.Units {
+					u := XLIFFUnit{Key: tu.Resname, Source: tu.Source}
+					if u.Key == "" {
+						u.Key = tu.ID
+					}
+					if tu.Target != nil {
+						u.Target = tu.Target.Text
+						u.Review = tu.Target.State == "new" ||
+							strings.HasPrefix(tu.Target.State, "needs-")
+					}
+					u.Note = strings.Join(tu.Notes, "\n")
+					file.Units = append(file.Units, u)
+				}
+			}
+			x.Files = append(x.Files, file)
+		}
+	case strings.HasPrefix(doc.Version, "2."):
+		for _, f := range doc.Files {
+			file := XLIFFFile{Original: f.Original}
+			for _, xu := range f.Units {
+				u := XLIFFUnit{Key: xu.Name}
+				if xu.Notes != nil {
+					u.Note = strings.Join(xu.Notes.Notes, "\n")
+				}
+				if u.Key == "" {
+					u.Key = xu.ID
+				}
+				for _, seg := range xu.Segments {
+					u.Source += seg.Source
+					if seg.Target != nil {
+						u.Target += *seg.Target
+					}
+					if seg.State == "" || seg.State == "initial" {
+						u.Review = true
+					}
+				}
+				file.Units = append(file.Units, u)
+			}
+			x.Files = append(x.Files, file)
+		}
+	default:
+		return nil, fmt.Errorf("locale: unknown XLIFF version %s", doc.Version)
+	}
+	return x, nil
+}
+
+// ImportXLIFF merges the translated units of x into the entries of the
+// .lang files of the target lang-set, and returns the entries by file name
+// to write with WriteLang. The entries not in x and the detached comments
+// are kept as they are.
+//
+// Every imported entry keeps its source text and the notes of its unit. It
+// is marked as needing review if the unit was not final, or if the value of
+// the source lang-set changed since the export.
+func (l *Lang) ImportXLIFF(x *XLIFF, source, target string) (map[string][]LangEntry, error) {
+	files := make(map[string][]LangEntry)
+	for _, f := range x.Files {
+		if path.Ext(f.Original) != ".lang" || path.Base(f.Original) != f.Original {
+			return nil, fmt.Errorf("locale: cannot import %s", f.Original)
+		}
+		entries, err := l.entries(target, f.Original)
+		if err != nil {
+			return nil, err
+		}
+		// the new entries go before the comments ending the file
+		end := len(entries)
+		for end > 0 && entries[end-1].Key == "" {
+			end--
+		}
+		trailing := append([]LangEntry(nil), entries[end:]...)
+		entries = entries[:end]
+		index := make(map[string]int, len(entries))
+		for i, e := range entries {
+			if e.Key != "" {
+				index[e.Key] = i
+			}
+		}
+
+		current := l.setOf(source)[f.Original]
+		for _, u := range f.Units {
+			if u.Target == "" {
+				continue
+			}
+			e := LangEntry{Key: u.Key, Value: u.Target, Note: u.Note, Source: u.Source}
+			if v, ok := current[u.Key]; ok && v != u.Source {
+				e.Review = true
+			}
+			e.Review = e.Review || u.Review
+			if i, ok := index[u.Key]; ok {
+				if e.Note == "" {
+					e.Note = entries[i].Note
+				}
+				entries[i] = e
+			} else {
+				index[u.Key] = len(entries)
+				entries = append(entries, e)
+			}
+		}
+		files[f.Original] = append(entries, trailing...)
+	}
+	return files, nil
+}
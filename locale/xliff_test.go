@@ -0,0 +1,144 @@
+package locale
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func xliffLang(t *testing.T) *Lang {
+	l := NewLangFS(fstest.MapFS{
+		"en/index.lang": {Data: []byte("# shown on the home page\nhi=Hello!\nbye=Goodbye\nnew=New & <b>bold</b>\n")},
+		"vi/index.lang": {Data: []byte("hi=Xin chào!\n\n#| Bye\nbye=Tạm biệt\n")},
+	})
+	if err := l.Parse("vi"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.SetDefault("en"); err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestExportXLIFF(t *testing.T) {
+	l := xliffLang(t)
+	for version, wants := range map[string][]string{
+		XLIFF12: {
+			`<file original="index.lang" source-language="en" target-language="vi" datatype="plaintext">`,
+			"<trans-unit id=\"1\" resname=\"hi\"><source>Hello!</source><target state=\"translated\">Xin chào!</target><note>shown on the home page</note>",
+			`<target state="needs-review-translation">Tạm biệt</target>`,
+			"<source>New &amp; &lt;b&gt;bold&lt;/b&gt;</source><target state=\"new\"></target>",
+		},
+		XLIFF20: {
+			`srcLang="en" trgLang="vi"`,
+			"<unit id=\"u1\" name=\"hi\"><notes><note>shown on the home page</note>",
+			"<segment state=\"initial\"><source>Goodbye</source><target>Tạm biệt</target>",
+			"<segment state=\"initial\"><source>New &amp; &lt;b&gt;bold&lt;/b&gt;</source></segment>",
+		},
+	} {
+		var buf bytes.Buffer
+		if err := l.ExportXLIFF(&buf, version, "en", "vi"); err != nil {
+			t.Fatal(err)
+		}
+		out := regexp.MustCompile(`>\s+<`).ReplaceAllString(buf.String(), "><")
+		for _, want := range wants {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s: missing %q in:%s", version, want, buf.String())
+			}
+		}
+
+		x, err := ReadXLIFF(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if x.SourceLang != "en" || x.TargetLang != "vi" || len(x.Files) != 1 || len(x.Files[0].Units) != 3 {
+			t.Fatalf("%s: read back %+v", version, x)
+		}
+		u := x.Files[0].Units[1]
+		if u.Key != "bye" || u.Source != "Goodbye" || u.Target != "Tạm biệt" || !u.Review {
+			t.Errorf("%s: read back %+v", version, u)
+		}
+	}
+	if err := l.ExportXLIFF(&bytes.Buffer{}, "3.0", "en", "vi"); err == nil {
+		t.Error("want error for an unknown version")
+	}
+}
+
+func TestImportXLIFF(t *testing.T) {
+	l := xliffLang(t)
+	x := &XLIFF{Files: []XLIFFFile{{Original: "index.lang", Units: []XLIFFUnit{
+		{Key: "bye", Source: "Goodbye", Target: "Tạm biệt nhé", Note: "informal"},
+		{Key: "new", Source: "New", Target: "Mới"},
+		{Key: "hi", Source: "Hello!"},
+	}}}}
+	files, err := l.ImportXLIFF(x, "en", "vi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = WriteLang(&buf, files["index.lang"]); err != nil {
+		t.Fatal(err)
+	}
+	want := "hi=Xin chào!\n" +
+		"\n# informal\n#| Goodbye\nbye=Tạm biệt nhé\n" +
+		"\n#, needs-review\n#| New\nnew=Mới\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	entries, err := ReadLang("vi/index.lang", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[1].Note != "informal" || entries[1].Review || !entries[2].Review {
+		t.Errorf("read back %+v", entries)
+	}
+
+	x.Files[0].Original = "../index.lang"
+	if _, err = l.ImportXLIFF(x, "en", "vi"); err == nil {
+		t.Error("want error for a file out of the lang-set")
+	}
+}
+
+func TestImportXLIFFComments(t *testing.T) {
+	l := NewLangFS(fstest.MapFS{
+		"en/index.lang": {Data: []byte("hi=Hello\nbye=Goodbye\n")},
+		"vi/index.lang": {Data: []byte("# Copyright 2013 The Toys Authors\n\nhi=Xin chào\n# trailing\n")},
+	})
+	if err := l.Parse("vi"); err != nil {
+		t.Fatal(err)
+	}
+	x := &XLIFF{Files: []XLIFFFile{{Original: "index.lang", Units: []XLIFFUnit{
+		{Key: "hi", Source: "Hello", Target: "Chào"},
+		{Key: "bye", Source: "Goodbye", Target: "Tạm biệt"},
+	}}}}
+	files, err := l.ImportXLIFF(x, "en", "vi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = WriteLang(&buf, files["index.lang"]); err != nil {
+		t.Fatal(err)
+	}
+	want := "# Copyright 2013 The Toys Authors\n\n" +
+		"#| Hello\nhi=Chào\n" +
+		"\n#| Goodbye\nbye=Tạm biệt\n" +
+		"\n# trailing\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	entries, err := ReadLang("vi/index.lang", strings.NewReader(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err = WriteLang(&buf, entries); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != want {
+		t.Errorf("written again:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
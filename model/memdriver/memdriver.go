@@ -0,0 +1,150 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package memdriver is an in-memory model.Driver, registered as "mem", to run
+and test the toys packages without a database:
+
+	import _ "github.com/kidstuff/toys/model/memdriver"
+
+	driver := model.MustLoad("mem")
+	id := driver.NewId()
+
+The ids are 64 bit numbers encoded as 16 hexadecimal digits. The registered
+driver gives sequential ids from 1, NewRandom gives random ones from a seed so
+the tests get the same ids on every run.
+
+The driver also keeps the records of model.Repository in memory, see
+Repository.
+*/
+package memdriver
+
+import (
+	"errors"
+	"fmt"
+	"github.com/kidstuff/toys/model"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+func init() {
+	model.Register("mem", NewSequential(1))
+}
+
+// Driver implements model.Driver.
+type Driver struct {
+	mux   sync.Mutex
+	next  uint64
+	rand  *rand.Rand // nil for the sequential ids
+	repos map[string]*Repository
+}
+
+var (
+	_ model.Driver           = &Driver{}
+	_ model.RepositoryOpener = &Driver{}
+)
+
+// NewSequential returns a Driver giving the ids start, start+1, start+2...
+func NewSequential(start uint64) *Driver {
+	if start == 0 {
+		start = 1
+	}
+	return &Driver{next: start}
+}
+
+// NewRandom returns a Driver giving random ids, always the same ones for a
+// seed.
+func NewRandom(seed int64) *Driver {
+	return &Driver{rand: rand.New(rand.NewSource(seed))}
+}
+
+// NewId returns a new Id.
+func (d *Driver) NewId() model.Identifier {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if d.rand == nil {
+		id := Id(d.next)
+		d.next++
+		return &id
+	}
+	for {
+		if id := Id(d.rand.Uint64()); id != 0 {
+			return &id
+		}
+	}
+}
+
+// DecodeId returns the Id of v, see Id.Decode.
+func (d *Driver) DecodeId(v interface{}) (model.Identifier, error) {
+	id := new(Id)
+	if err := id.Decode(v); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// ValidIdRep reports whether v represents a valid Id.
+func (d *Driver) ValidIdRep(v interface{}) bool {
+	_, err := d.DecodeId(v)
+	return err == nil
+}
+
+// Id implements model.Identifier, the zero Id is not valid.
+type Id uint64
+
+var _ model.Identifier = new(Id)
+
+// Decode sets the id from v: an Id, the string of Encode or a positive
+// integer.
+func (id *Id) Decode(v interface{}) error {
+	var n uint64
+	switch x := v.(type) {
+	case Id:
+		n = uint64(x)
+	case *Id:
+		if x == nil {
+			return errors.New("memdriver: nil id")
+		}
+		n = uint64(*x)
+	case string:
+		if len(x) != 16 {
+			return fmt.Errorf("memdriver: invalid id %q", x)
+		}
+		var err error
+		n, err = strconv.ParseUint(x, 16, 64)
+		if err != nil {
+			return fmt.Errorf("memdriver: invalid id %q", x)
+		}
+	default:
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if rv.Int() < 0 {
+				return fmt.Errorf("memdriver: invalid id %d", rv.Int())
+			}
+			n = uint64(rv.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n = rv.Uint()
+		default:
+			return fmt.Errorf("memdriver: cannot decode id from %T", v)
+		}
+	}
+	if n == 0 {
+		return errors.New("memdriver: invalid zero id")
+	}
+	*id = Id(n)
+	return nil
+}
+
+// Encode returns the id as 16 hexadecimal digits.
+func (id *Id) Encode() string {
+	return fmt.Sprintf("%016x", uint64(*id))
+}
+
+// Valid reports whether the id is not zero.
+func (id *Id) Valid() bool {
+	return id != nil && *id != 0
+}
@@ -0,0 +1,71 @@
+package memdriver
+
+import (
+	"github.com/kidstuff/toys/model"
+	"testing"
+)
+
+func TestRegistered(t *testing.T) {
+	d, err := model.Load("mem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.NewId().Valid() {
+		t.Error("NewId is not valid")
+	}
+}
+
+func TestSequential(t *testing.T) {
+	d := NewSequential(1)
+	for _, want := range []string{"0000000000000001", "0000000000000002", "0000000000000003"} {
+		if got := d.NewId().Encode(); got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+}
+
+func TestRandom(t *testing.T) {
+	a, b := NewRandom(42), NewRandom(42)
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		x, y := a.NewId().Encode(), b.NewId().Encode()
+		if x != y {
+			t.Fatalf("same seed, got %s and %s", x, y)
+		}
+		if seen[x] {
+			t.Fatalf("duplicated id %s", x)
+		}
+		seen[x] = true
+	}
+}
+
+func TestDecode(t *testing.T) {
+	d := NewRandom(1)
+	id := d.NewId()
+	back, err := d.DecodeId(id.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.Encode() != id.Encode() {
+		t.Errorf("round-trip got %s, want %s", back.Encode(), id.Encode())
+	}
+	if back, err = d.DecodeId(id); err != nil || back.Encode() != id.Encode() {
+		t.Errorf("DecodeId(Identifier) = %v, %v", back, err)
+	}
+
+	for _, v := range []interface{}{"000000000000002a", 42, uint8(42), Id(42)} {
+		if !d.ValidIdRep(v) {
+			t.Errorf("ValidIdRep(%#v) = false", v)
+		}
+	}
+	for _, v := range []interface{}{"", "2a", "zz00000000000000", "0000000000000000", 0, -1, 1.5, nil} {
+		if d.ValidIdRep(v) {
+			t.Errorf("ValidIdRep(%#v) = true", v)
+		}
+	}
+
+	var zero Id
+	if zero.Valid() {
+		t.Error("zero Id is valid")
+	}
+}
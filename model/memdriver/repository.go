@@ -0,0 +1,555 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package memdriver
+
+import (
+	"errors"
+	"fmt"
+	"github.com/kidstuff/toys/model"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Repository is an in-memory model.Repository. The records must be pointers
+// to structs of the type returned by newRecord, the Repository keeps deep
+// copies of them so they can be changed freely once given or returned.
+//
+// The Filter and Sort fields are compared as numbers, strings, booleans,
+// time.Time or model.Identifier, the other values only with Eq, Ne and In.
+type Repository struct {
+	driver  *Driver
+	typ     reflect.Type
+	mux     sync.RWMutex
+	records map[string]model.Record
+	// deleted keeps the sortable field values of the deleted records by
+	// key, a page can still start after them
+	deleted map[string]map[string]interface{}
+}
+
+var _ model.Repository = &Repository{}
+
+// Repository returns the Repository of the kind, the same one for all the
+// calls with the kind. The ids of the records are made by the driver.
+func (d *Driver) Repository(kind string, newRecord func() model.Record) (model.Repository, error) {
+	if newRecord == nil {
+		return nil, errors.New("memdriver: nil newRecord")
+	}
+	typ := reflect.TypeOf(newRecord())
+	if typ == nil || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("memdriver: the record of %s must be a pointer to a struct", kind)
+	}
+
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if r, ok := d.repos[kind]; ok {
+		if r.typ != typ {
+			return nil, fmt.Errorf("memdriver: %s already holds %s records", kind, r.typ)
+		}
+		return r, nil
+	}
+	if d.repos == nil {
+		d.repos = make(map[string]*Repository)
+	}
+	r := &Repository{
+		driver:  d,
+		typ:     typ,
+		records: make(map[string]model.Record),
+		deleted: make(map[string]map[string]interface{}),
+	}
+	d.repos[kind] = r
+	return r, nil
+}
+
+// Insert gives a new id to rec and stores a copy of it.
+func (r *Repository) Insert(rec model.Record) error {
+	if err := r.check(rec); err != nil {
+		return err
+	}
+	id := r.driver.NewId()
+	if err := rec.SetId(id); err != nil {
+		return err
+	}
+	r.mux.Lock()
+	r.records[id.Encode()] = copyRecord(rec)
+	r.mux.Unlock()
+	return nil
+}
+
+// Get copies the record with the id into rec.
+func (r *Repository) Get(id model.Identifier, rec model.Record) error {
+	if err := r.check(rec); err != nil {
+		return err
+	}
+	if id == nil || !id.Valid() {
+		return model.ErrNotFound
+	}
+	r.mux.RLock()
+	stored, ok := r.records[id.Encode()]
+	r.mux.RUnlock()
+	if !ok {
+		return model.ErrNotFound
+	}
+	reflect.ValueOf(rec).Elem().Set(reflect.ValueOf(copyRecord(stored)).Elem())
+	return nil
+}
+
+// Update replaces the record with the id of rec by a copy of rec.
+func (r *Repository) Update(rec model.Record) error {
+	if err := r.check(rec); err != nil {
+		return err
+	}
+	id := rec.GetId()
+	if id == nil || !id.Valid() {
+		return model.ErrNotFound
+	}
+	key := id.Encode()
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if _, ok := r.records[key]; !ok {
+		return model.ErrNotFound
+	}
+	r.records[key] = copyRecord(rec)
+	return nil
+}
+
+// Delete removes the record with the id.
+func (r *Repository) Delete(id model.Identifier) error {
+	if id == nil || !id.Valid() {
+		return model.ErrNotFound
+	}
+	key := id.Encode()
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	rec, ok := r.records[key]
+	if !ok {
+		return model.ErrNotFound
+	}
+	delete(r.records, key)
+	r.deleted[key] = sortable(rec)
+	return nil
+}
+
+// Find returns copies of the records selected by q. The page starts after
+// the record q.OffsetId in the order of q, even if that record no longer
+// matches the filters or was deleted. An unknown q.OffsetId gives
+// model.ErrNotFound if q sorts the records.
+func (r *Repository) Find(q model.Query) ([]model.Record, error) {
+	type item struct {
+		key  string
+		rec  model.Record
+		sort []interface{}
+	}
+	sortValues := func(rec model.Record) ([]interface{}, error) {
+		var values []interface{}
+		for _, s := range q.Sort {
+			v, err := field(rec, s.Field)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	}
+	// compare orders a and b by the Sort fields, then by key
+	compare := func(a, b item) (int, error) {
+		for n, s := range q.Sort {
+			c, ok := order(a.sort[n], b.sort[n])
+			if !ok {
+				return 0, fmt.Errorf("memdriver: cannot sort by %s", s.Field)
+			}
+			if c != 0 {
+				if s.Desc {
+					c = -c
+				}
+				return c, nil
+			}
+		}
+		return strings.Compare(a.key, b.key), nil
+	}
+
+	var (
+		items  []item
+		offset *item
+	)
+	r.mux.RLock()
+	if q.OffsetId != nil {
+		key := q.OffsetId.Encode()
+		offset = &item{key: key}
+		if rec, ok := r.records[key]; ok {
+			values, err := sortValues(rec)
+			if err != nil {
+				r.mux.RUnlock()
+				return nil, err
+			}
+			offset.sort = values
+		} else if values, ok := r.deleted[key]; ok {
+			for _, s := range q.Sort {
+				offset.sort = append(offset.sort, values[s.Field])
+			}
+		} else if len(q.Sort) > 0 {
+			r.mux.RUnlock()
+			return nil, model.ErrNotFound
+		}
+	}
+	for key, rec := range r.records {
+		ok, err := matches(rec, q.Filters)
+		if err != nil {
+			r.mux.RUnlock()
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		it := item{key: key, rec: rec}
+		if it.sort, err = sortValues(rec); err != nil {
+			r.mux.RUnlock()
+			return nil, err
+		}
+		if offset != nil {
+			c, err := compare(it, *offset)
+			if err != nil {
+				r.mux.RUnlock()
+				return nil, err
+			}
+			if c <= 0 {
+				continue
+			}
+		}
+		items = append(items, it)
+	}
+	r.mux.RUnlock()
+
+	var sortErr error
+	sort.Slice(items, func(i, j int) bool {
+		c, err := compare(items[i], items[j])
+		if err != nil {
+			sortErr = err
+		}
+		return c < 0
+	})
+	if sortErr != nil {
+		return nil, sortErr
+	}
+	if q.Limit > 0 && len(items) > q.Limit {
+		items = items[:q.Limit]
+	}
+
+	found := make([]model.Record, len(items))
+	for i := range items {
+		found[i] = copyRecord(items[i].rec)
+	}
+	return found, nil
+}
+
+// check returns an error if rec is not a non-nil record of the Repository
+// type.
+func (r *Repository) check(rec model.Record) error {
+	v := reflect.ValueOf(rec)
+	if !v.IsValid() || v.Type() != r.typ || v.IsNil() {
+		return fmt.Errorf("memdriver: want a non-nil %s record, got %T", r.typ, rec)
+	}
+	return nil
+}
+
+// matches reports whether rec matches all the filters.
+func matches(rec model.Record, filters []model.Filter) (bool, error) {
+	for _, f := range filters {
+		v, err := field(rec, f.Field)
+		if err != nil {
+			return false, err
+		}
+		var ok bool
+		switch f.Op {
+		case model.Eq:
+			ok = equal(v, f.Value)
+		case model.Ne:
+			ok = !equal(v, f.Value)
+		case model.In:
+			items := reflect.ValueOf(f.Value)
+			if items.Kind() != reflect.Slice && items.Kind() != reflect.Array {
+				return false, fmt.Errorf("memdriver: the value of %s in must be a slice", f.Field)
+			}
+			for i := 0; i < items.Len() && !ok; i++ {
+				ok = equal(v, items.Index(i).Interface())
+			}
+		case model.Lt, model.Le, model.Gt, model.Ge:
+			c, comparable := order(v, f.Value)
+			if !comparable {
+				return false, fmt.Errorf("memdriver: cannot compare %s with %T", f.Field, f.Value)
+			}
+			switch f.Op {
+			case model.Lt:
+				ok = c < 0
+			case model.Le:
+				ok = c <= 0
+			case model.Gt:
+				ok = c > 0
+			default:
+				ok = c >= 0
+			}
+		default:
+			return false, fmt.Errorf("memdriver: unknown operator %q", f.Op)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// sortable returns the values of the exported fields of rec which can be
+// sorted, by dotted name like field reads them, the promoted fields of the
+// embedded structs included. The nil values are left out.
+func sortable(rec model.Record) map[string]interface{} {
+	values := make(map[string]interface{})
+	var walk func(prefix string, v reflect.Value)
+	walk = func(prefix string, v reflect.Value) {
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return
+		}
+		var embedded []reflect.Value
+		for i := 0; i < v.NumField(); i++ {
+			sf := v.Type().Field(i)
+			if sf.Anonymous {
+				embedded = append(embedded, v.Field(i))
+			}
+			if sf.PkgPath != "" {
+				continue
+			}
+			name := prefix + sf.Name
+			x := v.Field(i).Interface()
+			if _, ok := order(x, x); ok && !isNil(x) {
+				if _, ok := values[name]; !ok {
+					values[name] = x
+				}
+				continue
+			}
+			walk(name+".", v.Field(i))
+		}
+		// the shallower fields shadow the promoted ones
+		for _, f := range embedded {
+			walk(prefix, f)
+		}
+	}
+	walk("", reflect.ValueOf(rec))
+	return values
+}
+
+// field returns the value of the exported field of rec with the dotted name.
+// A nil pointer on the way gives a nil value.
+func field(rec model.Record, name string) (interface{}, error) {
+	v := reflect.ValueOf(rec)
+	for _, part := range strings.Split(name, ".") {
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return nil, nil
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("memdriver: no field %s", name)
+		}
+		v = v.FieldByName(part)
+		if !v.IsValid() || !v.CanInterface() {
+			return nil, fmt.Errorf("memdriver: no field %s", name)
+		}
+	}
+	return v.Interface(), nil
+}
+
+// equal reports whether a and b are the same value, the numbers of
+// different types included.
+func equal(a, b interface{}) bool {
+	if c, ok := order(a, b); ok {
+		return c == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// order compares a and b, ok is false if they cannot be ordered. The nil
+// values come first.
+func order(a, b interface{}) (c int, ok bool) {
+	if isNil(a) || isNil(b) {
+		switch {
+		case isNil(a) && isNil(b):
+			return 0, true
+		case isNil(a):
+			return -1, true
+		}
+		return 1, true
+	}
+	if ta, ok := a.(time.Time); ok {
+		tb, ok := b.(time.Time)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case ta.Before(tb):
+			return -1, true
+		case ta.After(tb):
+			return 1, true
+		}
+		return 0, true
+	}
+	if ia, ok := a.(model.Identifier); ok {
+		ib, ok := b.(model.Identifier)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(ia.Encode(), ib.Encode()), true
+	}
+
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	switch {
+	case isInt(va) && isInt(vb):
+		switch x, y := va.Int(), vb.Int(); {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	case isUint(va) && isUint(vb):
+		switch x, y := va.Uint(), vb.Uint(); {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	case isNumber(va) && isNumber(vb):
+		switch x, y := toFloat(va), toFloat(vb); {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	case va.Kind() == reflect.String && vb.Kind() == reflect.String:
+		return strings.Compare(va.String(), vb.String()), true
+	case va.Kind() == reflect.Bool && vb.Kind() == reflect.Bool:
+		x, y := va.Bool(), vb.Bool()
+		switch {
+		case x == y:
+			return 0, true
+		case y:
+			return -1, true
+		}
+		return 1, true
+	}
+	return 0, false
+}
+
+func isNil(x interface{}) bool {
+	if x == nil {
+		return true
+	}
+	v := reflect.ValueOf(x)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+func isInt(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func isUint(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+func isNumber(v reflect.Value) bool {
+	return isInt(v) || isUint(v) || v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64
+}
+
+func toFloat(v reflect.Value) float64 {
+	switch {
+	case isInt(v):
+		return float64(v.Int())
+	case isUint(v):
+		return float64(v.Uint())
+	}
+	return v.Float()
+}
+
+// copyRecord returns a deep copy of rec.
+func copyRecord(rec model.Record) model.Record {
+	return deepCopy(reflect.ValueOf(rec)).Interface().(model.Record)
+}
+
+// deepCopy copies the pointers, maps, slices and exported struct fields of v
+// recursively, the unexported fields are copied as they are. The values must
+// not hold cycles.
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		n := reflect.New(v.Type().Elem())
+		n.Elem().Set(deepCopy(v.Elem()))
+		return n
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		n := reflect.New(v.Type()).Elem()
+		n.Set(deepCopy(v.Elem()))
+		return n
+	case reflect.Struct:
+		n := reflect.New(v.Type()).Elem()
+		n.Set(v)
+		for i := 0; i < n.NumField(); i++ {
+			if f := n.Field(i); f.CanSet() {
+				f.Set(deepCopy(v.Field(i)))
+			}
+		}
+		return n
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		n := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for _, k := range v.MapKeys() {
+			n.SetMapIndex(k, deepCopy(v.MapIndex(k)))
+		}
+		return n
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		n := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			n.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return n
+	case reflect.Array:
+		n := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			n.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return n
+	}
+	return v
+}
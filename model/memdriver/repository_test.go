@@ -0,0 +1,274 @@
+package memdriver
+
+import (
+	"github.com/kidstuff/toys/model"
+	"testing"
+	"time"
+)
+
+type person struct {
+	Id    model.Identifier
+	Name  string
+	Age   int
+	Born  time.Time
+	Tags  []string
+	Extra *struct{ City string }
+}
+
+func (p *person) GetId() model.Identifier         { return p.Id }
+func (p *person) SetId(id model.Identifier) error { p.Id = id; return nil }
+
+func newPerson() model.Record { return &person{} }
+
+func names(t *testing.T, recs []model.Record) string {
+	s := ""
+	for _, rec := range recs {
+		s += rec.(*person).Name + " "
+	}
+	return s
+}
+
+func TestRepository(t *testing.T) {
+	d := NewSequential(1)
+	repo, err := d.Repository("people", newPerson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := d.Repository("people", newPerson); again != repo {
+		t.Error("want the same Repository for the kind")
+	}
+
+	p := &person{Name: "Ann", Age: 30, Tags: []string{"a"}}
+	if err = repo.Insert(p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Id.Encode() != "0000000000000001" {
+		t.Errorf("Insert id = %s", p.Id.Encode())
+	}
+	p.Tags[0] = "changed"
+
+	got := &person{}
+	if err = repo.Get(p.Id, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "Ann" || got.Tags[0] != "a" {
+		t.Errorf("Get = %+v, want the inserted copy", got)
+	}
+
+	got.Age = 31
+	if err = repo.Update(got); err != nil {
+		t.Fatal(err)
+	}
+	repo.Get(p.Id, got)
+	if got.Age != 31 {
+		t.Errorf("Update, Age = %d", got.Age)
+	}
+
+	if err = repo.Delete(p.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err = repo.Get(p.Id, got); err != model.ErrNotFound {
+		t.Errorf("Get deleted = %v, want ErrNotFound", err)
+	}
+	if err = repo.Update(got); err != model.ErrNotFound {
+		t.Errorf("Update deleted = %v, want ErrNotFound", err)
+	}
+	if err = repo.Delete(p.Id); err != model.ErrNotFound {
+		t.Errorf("Delete deleted = %v, want ErrNotFound", err)
+	}
+
+	type other struct{ person }
+	if _, err = d.Repository("people", func() model.Record { return &other{} }); err == nil {
+		t.Error("want error for another record type")
+	}
+	if err = repo.Insert(&other{}); err == nil {
+		t.Error("want error for another record type")
+	}
+}
+
+func TestRepositoryFind(t *testing.T) {
+	repo, err := NewSequential(1).Repository("people", newPerson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []*person{
+		{Name: "Ann", Age: 30},
+		{Name: "Bob", Age: 25, Extra: &struct{ City string }{"Hanoi"}},
+		{Name: "Cid", Age: 30},
+		{Name: "Dan", Age: 41, Extra: &struct{ City string }{"Hue"}},
+	} {
+		if err = repo.Insert(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		q    model.Query
+		want string
+	}{
+		{model.Query{}, "Ann Bob Cid Dan "},
+		{model.Query{Filters: []model.Filter{{Field: "Age", Op: model.Eq, Value: int64(30)}}}, "Ann Cid "},
+		{model.Query{Filters: []model.Filter{{Field: "Age", Op: model.Ge, Value: 30.0}, {Field: "Name", Op: model.Ne, Value: "Ann"}}}, "Cid Dan "},
+		{model.Query{Filters: []model.Filter{{Field: "Name", Op: model.In, Value: []string{"Dan", "Bob"}}}}, "Bob Dan "},
+		{model.Query{Filters: []model.Filter{{Field: "Extra.City", Op: model.Eq, Value: "Hue"}}}, "Dan "},
+		{model.Query{Sort: []model.Sort{{Field: "Age", Desc: true}}}, "Dan Ann Cid Bob "},
+		{model.Query{Sort: []model.Sort{{Field: "Age"}}, Limit: 2}, "Bob Ann "},
+	}
+	for _, test := range tests {
+		recs, err := repo.Find(test.q)
+		if err != nil {
+			t.Errorf("%+v: %v", test.q, err)
+			continue
+		}
+		if got := names(t, recs); got != test.want {
+			t.Errorf("%+v = %q, want %q", test.q, got, test.want)
+		}
+	}
+
+	// walk the pages sorted by age
+	q := model.Query{Sort: []model.Sort{{Field: "Age"}}, Limit: 3}
+	var all string
+	for {
+		recs, err := repo.Find(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(recs) == 0 {
+			break
+		}
+		all += names(t, recs)
+		q.OffsetId = recs[len(recs)-1].GetId()
+	}
+	if all != "Bob Ann Cid Dan " {
+		t.Errorf("pages = %q", all)
+	}
+
+	id := Id(2)
+	if recs, err := repo.Find(model.Query{OffsetId: &id}); err != nil || names(t, recs) != "Cid Dan " {
+		t.Errorf("after id 2 = %q, %v", names(t, recs), err)
+	}
+	id = Id(99)
+	if recs, err := repo.Find(model.Query{OffsetId: &id}); err != nil || len(recs) != 0 {
+		t.Errorf("after unknown id = %d records, %v", len(recs), err)
+	}
+	if _, err = repo.Find(model.Query{Sort: q.Sort, OffsetId: &id}); err != model.ErrNotFound {
+		t.Errorf("unknown sorted offset = %v, want ErrNotFound", err)
+	}
+	for _, f := range []model.Filter{
+		{Field: "Missing", Op: model.Eq, Value: 1},
+		{Field: "Name", Op: model.Lt, Value: 1},
+		{Field: "Name", Op: "~", Value: "A"},
+		{Field: "Name", Op: model.In, Value: "Ann"},
+	} {
+		if _, err = repo.Find(model.Query{Filters: []model.Filter{f}}); err == nil {
+			t.Errorf("%+v: want error", f)
+		}
+	}
+}
+
+func TestOpenRepository(t *testing.T) {
+	repo, err := model.OpenRepository("mem", "open-people", newPerson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = repo.Insert(&person{Name: "Ann"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRepositoryFindKeyset(t *testing.T) {
+	repo, err := NewSequential(1).Repository("people", newPerson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []*person{
+		{Name: "Ann", Age: 30},
+		{Name: "Bob", Age: 25},
+		{Name: "Cid", Age: 30},
+		{Name: "Dan", Age: 41},
+		{Name: "Eve", Age: 35},
+	} {
+		if err = repo.Insert(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	q := model.Query{Sort: []model.Sort{{Field: "Age", Desc: true}}, Limit: 2}
+	recs, err := repo.Find(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(t, recs); got != "Dan Eve " {
+		t.Fatalf("page 1 = %q", got)
+	}
+
+	// the last record of the page is deleted before the next page
+	last := recs[len(recs)-1].GetId()
+	if err = repo.Delete(last); err != nil {
+		t.Fatal(err)
+	}
+	q.OffsetId = last
+	if recs, err = repo.Find(q); err != nil || names(t, recs) != "Ann Cid " {
+		t.Fatalf("page 2 after delete = %q, %v", names(t, recs), err)
+	}
+
+	// the last record of the page no longer matches the filter
+	q.OffsetId = recs[len(recs)-1].GetId()
+	q.Filters = []model.Filter{{Field: "Name", Op: model.Ne, Value: "Cid"}}
+	if recs, err = repo.Find(q); err != nil || names(t, recs) != "Bob " {
+		t.Errorf("page 3 after filter = %q, %v", names(t, recs), err)
+	}
+}
+
+func TestRepositoryFindDeletedOffset(t *testing.T) {
+	type employee struct {
+		person
+		Team string
+	}
+	repo, err := NewSequential(1).Repository("employees", func() model.Record { return &employee{} })
+	if err != nil {
+		t.Fatal(err)
+	}
+	born := time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)
+	var bob model.Identifier
+	for i, name := range []string{"Ann", "Bob", "Cid", "Dan"} {
+		e := &employee{person: person{Name: name, Born: born.AddDate(i, 0, 0), Tags: []string{"x"}}, Team: "a"}
+		if i%2 == 1 {
+			e.Extra = &struct{ City string }{City: "Hue"}
+		}
+		if err = repo.Insert(e); err != nil {
+			t.Fatal(err)
+		}
+		if name == "Bob" {
+			bob = e.Id
+		}
+	}
+
+	// Bob is deleted, the pages start after him by any sort
+	if err = repo.Delete(bob); err != nil {
+		t.Fatal(err)
+	}
+	values := repo.(*Repository).deleted[bob.Encode()]
+	if values["Name"] != "Bob" || values["Extra.City"] != "Hue" || values["Tags"] != nil {
+		t.Errorf("deleted values = %v", values)
+	}
+	tests := []struct {
+		sort []model.Sort
+		want string
+	}{
+		{[]model.Sort{{Field: "Name"}}, "Cid Dan "},
+		{[]model.Sort{{Field: "Born", Desc: true}}, "Ann "},
+		{[]model.Sort{{Field: "Extra.City"}, {Field: "Name"}}, "Dan "},
+		{[]model.Sort{{Field: "Team"}, {Field: "Age"}}, "Cid Dan "},
+	}
+	for _, test := range tests {
+		recs, err := repo.Find(model.Query{Sort: test.sort, OffsetId: bob})
+		got := ""
+		for _, rec := range recs {
+			got += rec.(*employee).Name + " "
+		}
+		if err != nil || got != test.want {
+			t.Errorf("%v: got %q, %v, want %q", test.sort, got, err, test.want)
+		}
+	}
+}
@@ -5,6 +5,22 @@
 /*
 Package model provide some interfaces to support application development
 on multiple database platforms.
+
+A Driver makes the Identifiers of a database. The drivers implementing
+RepositoryOpener also store records, so the code written over a Repository,
+like the membership managers, runs on all of them:
+
+	users, err := model.OpenRepository("mem", "users", func() model.Record {
+		return &User{}
+	})
+	err = users.Insert(&User{Email: "gopher@example.com"})
+	found, err := users.Find(model.Query{
+		Filters: []model.Filter{{Field: "Email", Op: model.Eq, Value: "gopher@example.com"}},
+		Limit:   10,
+	})
+
+For now the in-memory "mem" driver of the memdriver package is the only
+RepositoryOpener, OpenRepository returns ErrNotSupported for the others.
 */
 package model
 
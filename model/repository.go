@@ -0,0 +1,102 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package model
+
+import (
+	"errors"
+)
+
+var (
+	// ErrNotFound is returned when no record has the id.
+	ErrNotFound = errors.New("model: not found")
+	// ErrNotSupported is returned by OpenRepository when the driver has no
+	// repositories.
+	ErrNotSupported = errors.New("model: repositories not supported by the driver")
+)
+
+// Record is a value stored in a Repository, usually a pointer to a struct.
+type Record interface {
+	GetId() Identifier
+	SetId(Identifier) error
+}
+
+// Op is the comparison of a Filter.
+type Op string
+
+const (
+	Eq Op = "="
+	Ne Op = "!="
+	Lt Op = "<"
+	Le Op = "<="
+	Gt Op = ">"
+	Ge Op = ">="
+	// In matches the fields equal to one of the items of the Value slice.
+	In Op = "in"
+)
+
+// Filter matches the records whose Field compares to Value. Field is the
+// name of a struct field, a dotted name like "Info.FirstName" reaches the
+// nested ones.
+type Filter struct {
+	Field string
+	Op    Op
+	Value interface{}
+}
+
+// Sort orders the records by Field.
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// Query selects the records matching all the Filters, ordered by Sort then
+// by id. OffsetId is the id of the last record of the previous page, the
+// page starts after it; Limit is the number of records per page, 0 for all
+// of them.
+type Query struct {
+	Filters  []Filter
+	Sort     []Sort
+	OffsetId Identifier
+	Limit    int
+}
+
+// Repository stores the records of a kind, like the users or the groups.
+// It must be safe for concurrent use.
+type Repository interface {
+	// Insert gives a new id to r and stores it.
+	Insert(r Record) error
+	// Get reads the record with the id into r, or returns ErrNotFound.
+	Get(id Identifier, r Record) error
+	// Update replaces the record with the id of r, or returns ErrNotFound.
+	Update(r Record) error
+	// Delete removes the record with the id, or returns ErrNotFound.
+	Delete(id Identifier) error
+	// Find returns the records selected by q. The page starts after the
+	// record q.OffsetId in the order of q, even if that record no longer
+	// matches the filters or was deleted meanwhile.
+	Find(q Query) ([]Record, error)
+}
+
+// RepositoryOpener is implemented by the drivers which can store records.
+type RepositoryOpener interface {
+	// Repository returns the Repository of the kind, newRecord returns an
+	// empty record to read into.
+	Repository(kind string, newRecord func() Record) (Repository, error)
+}
+
+// OpenRepository returns the Repository of kind from the driver with name.
+// It returns ErrNotSupported if the driver does not implement
+// RepositoryOpener.
+func OpenRepository(name, kind string, newRecord func() Record) (Repository, error) {
+	driver, err := Load(name)
+	if err != nil {
+		return nil, err
+	}
+	opener, ok := driver.(RepositoryOpener)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return opener.Repository(kind, newRecord)
+}
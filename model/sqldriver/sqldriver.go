@@ -0,0 +1,211 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package sqldriver is a model.Driver for the database/sql databases, like
+PostgreSQL or SQLite. It is registered as "sqlint" for the int64 primary
+keys and "sqlstring" for the string ones:
+
+	import _ "github.com/kidstuff/toys/model/sqldriver"
+
+	driver := model.MustLoad("sqlint")
+
+The Identifiers implement sql.Scanner and driver.Valuer so they can be given
+to and read from the queries as they are. Store is a generic table store on
+top of database/sql using them.
+
+Driver is not a model.RepositoryOpener and Store is not a model.Repository:
+the rows are read and written by key, without the Filters, Sort and keyset
+pages of model.Query. The code written over a model.Repository, like the
+membership managers, runs on the "mem" driver only for now.
+*/
+package sqldriver
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/kidstuff/toys/model"
+	"reflect"
+	"strconv"
+)
+
+func init() {
+	model.Register("sqlint", &Driver{})
+	model.Register("sqlstring", &Driver{String: true})
+}
+
+// Driver implements model.Driver for the int64 keys, or the string keys if
+// String is true.
+type Driver struct {
+	String bool
+}
+
+var _ model.Driver = &Driver{}
+
+// NewId returns a new Identifier. An IntId is zero, so not valid, until the
+// database gives its value, see Store.Insert. A StringId holds 32 random
+// hexadecimal digits.
+func (d *Driver) NewId() model.Identifier {
+	if !d.String {
+		return new(IntId)
+	}
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("sqldriver: cannot read random bits: " + err.Error())
+	}
+	id := StringId(hex.EncodeToString(b[:]))
+	return &id
+}
+
+// DecodeId returns the IntId or StringId of v.
+func (d *Driver) DecodeId(v interface{}) (model.Identifier, error) {
+	var id model.Identifier = new(IntId)
+	if d.String {
+		id = new(StringId)
+	}
+	if err := id.Decode(v); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// ValidIdRep reports whether v represents a valid id.
+func (d *Driver) ValidIdRep(v interface{}) bool {
+	_, err := d.DecodeId(v)
+	return err == nil
+}
+
+// IntId is an int64 primary key, valid if positive.
+type IntId int64
+
+var (
+	_ model.Identifier = new(IntId)
+	_ sql.Scanner      = new(IntId)
+	_ driver.Valuer    = IntId(0)
+)
+
+// Decode sets the id from an IntId, an integer or its decimal text.
+func (id *IntId) Decode(v interface{}) error {
+	var n int64
+	switch x := v.(type) {
+	case IntId:
+		n = int64(x)
+	case *IntId:
+		if x == nil {
+			return errors.New("sqldriver: nil id")
+		}
+		n = int64(*x)
+	case string:
+		var err error
+		if n, err = strconv.ParseInt(x, 10, 64); err != nil {
+			return fmt.Errorf("sqldriver: invalid id %q", x)
+		}
+	case []byte:
+		return id.Decode(string(x))
+	default:
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n = rv.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if rv.Uint() > 1<<63-1 {
+				return fmt.Errorf("sqldriver: invalid id %d", rv.Uint())
+			}
+			n = int64(rv.Uint())
+		default:
+			return fmt.Errorf("sqldriver: cannot decode id from %T", v)
+		}
+	}
+	if n <= 0 {
+		return fmt.Errorf("sqldriver: invalid id %d", n)
+	}
+	*id = IntId(n)
+	return nil
+}
+
+// Encode returns the decimal text of the id.
+func (id *IntId) Encode() string {
+	return strconv.FormatInt(int64(*id), 10)
+}
+
+// Valid reports whether the id is positive.
+func (id *IntId) Valid() bool {
+	return id != nil && *id > 0
+}
+
+// Scan implements sql.Scanner.
+func (id *IntId) Scan(src interface{}) error {
+	if src == nil {
+		*id = 0
+		return nil
+	}
+	return id.Decode(src)
+}
+
+// Value implements driver.Valuer.
+func (id IntId) Value() (driver.Value, error) {
+	return int64(id), nil
+}
+
+// StringId is a string primary key, valid if not empty.
+type StringId string
+
+var (
+	_ model.Identifier = new(StringId)
+	_ sql.Scanner      = new(StringId)
+	_ driver.Valuer    = StringId("")
+)
+
+// Decode sets the id from a StringId, a string or bytes.
+func (id *StringId) Decode(v interface{}) error {
+	var s string
+	switch x := v.(type) {
+	case StringId:
+		s = string(x)
+	case *StringId:
+		if x == nil {
+			return errors.New("sqldriver: nil id")
+		}
+		s = string(*x)
+	case string:
+		s = x
+	case []byte:
+		s = string(x)
+	default:
+		return fmt.Errorf("sqldriver: cannot decode id from %T", v)
+	}
+	if s == "" {
+		return errors.New("sqldriver: invalid empty id")
+	}
+	*id = StringId(s)
+	return nil
+}
+
+// Encode returns the id.
+func (id *StringId) Encode() string {
+	return string(*id)
+}
+
+// Valid reports whether the id is not empty.
+func (id *StringId) Valid() bool {
+	return id != nil && *id != ""
+}
+
+// Scan implements sql.Scanner.
+func (id *StringId) Scan(src interface{}) error {
+	if src == nil {
+		*id = ""
+		return nil
+	}
+	return id.Decode(src)
+}
+
+// Value implements driver.Valuer.
+func (id StringId) Value() (driver.Value, error) {
+	return string(id), nil
+}
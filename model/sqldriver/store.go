@@ -0,0 +1,233 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqldriver
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/kidstuff/toys/model"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrNotFound is returned when no row has the id, it is model.ErrNotFound.
+var ErrNotFound = model.ErrNotFound
+
+// Dialect is the SQL differences of a database.
+type Dialect struct {
+	// Placeholder returns the placeholder of the nth argument, from 1.
+	Placeholder func(n int) string
+	// Returning tells the database gives the generated keys by a RETURNING
+	// clause rather than by sql.Result.LastInsertId.
+	Returning bool
+}
+
+// The Dialects of the common databases.
+var (
+	SQLite     = &Dialect{Placeholder: question}
+	MySQL      = &Dialect{Placeholder: question}
+	PostgreSQL = &Dialect{Placeholder: dollar, Returning: true}
+)
+
+func question(n int) string {
+	return "?"
+}
+
+func dollar(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+var identRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// Store reads and writes the rows of a table by their primary key, in the
+// order of Columns.
+type Store struct {
+	DB      *sql.DB
+	Driver  *Driver
+	Dialect *Dialect
+	Table   string
+	Key     string
+	Columns []string
+}
+
+// NewStore returns a Store of the table with the key column and the other
+// columns. The table and column names must be plain SQL identifiers.
+func NewStore(db *sql.DB, d *Driver, dialect *Dialect, table, key string, columns ...string) (*Store, error) {
+	for _, name := range append([]string{table, key}, columns...) {
+		if !identRe.MatchString(name) {
+			return nil, fmt.Errorf("sqldriver: invalid identifier %q", name)
+		}
+	}
+	if len(columns) == 0 {
+		return nil, errors.New("sqldriver: no column")
+	}
+	return &Store{DB: db, Driver: d, Dialect: dialect, Table: table, Key: key, Columns: columns}, nil
+}
+
+// placeholders returns the placeholders from the nth argument.
+func (s *Store) placeholders(from, count int) []string {
+	p := make([]string, count)
+	for i := range p {
+		p[i] = s.Dialect.Placeholder(from + i)
+	}
+	return p
+}
+
+// Insert adds a row with the values of the columns and returns its id. An
+// IntId which is not valid is generated by the database, a StringId which
+// is not valid is made by Driver.NewId.
+func (s *Store) Insert(id model.Identifier, values ...interface{}) (model.Identifier, error) {
+	if len(values) != len(s.Columns) {
+		return nil, fmt.Errorf("sqldriver: %d values for %d columns", len(values), len(s.Columns))
+	}
+	if id == nil || !id.Valid() {
+		id = s.Driver.NewId()
+	}
+
+	columns, args := s.Columns, values
+	if id.Valid() {
+		columns = append([]string{s.Key}, columns...)
+		args = append([]interface{}{id}, args...)
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "INSERT INTO %s (%s) VALUES (%s)", s.Table,
+		strings.Join(columns, ", "), strings.Join(s.placeholders(1, len(args)), ", "))
+	if id.Valid() {
+		_, err := s.DB.Exec(buf.String(), args...)
+		if err != nil {
+			return nil, err
+		}
+		return id, nil
+	}
+
+	var n int64
+	if s.Dialect.Returning {
+		buf.WriteString(" RETURNING " + s.Key)
+		if err := s.DB.QueryRow(buf.String(), args...).Scan(&n); err != nil {
+			return nil, err
+		}
+	} else {
+		res, err := s.DB.Exec(buf.String(), args...)
+		if err != nil {
+			return nil, err
+		}
+		if n, err = res.LastInsertId(); err != nil {
+			return nil, err
+		}
+	}
+	return s.Driver.DecodeId(n)
+}
+
+// Get reads the columns of the row with the id into dest, like
+// sql.Row.Scan. It returns ErrNotFound if there is no such row.
+func (s *Store) Get(id model.Identifier, dest ...interface{}) error {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s = %s", strings.Join(s.Columns, ", "),
+		s.Table, s.Key, s.Dialect.Placeholder(1))
+	err := s.DB.QueryRow(query, id).Scan(dest...)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Update sets the columns of the row with the id. It returns ErrNotFound if
+// there is no such row.
+func (s *Store) Update(id model.Identifier, values ...interface{}) error {
+	if len(values) != len(s.Columns) {
+		return fmt.Errorf("sqldriver: %d values for %d columns", len(values), len(s.Columns))
+	}
+	set := make([]string, len(s.Columns))
+	for i, c := range s.Columns {
+		set[i] = c + " = " + s.Dialect.Placeholder(i+1)
+	}
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s = %s", s.Table, strings.Join(set, ", "),
+		s.Key, s.Dialect.Placeholder(len(s.Columns)+1))
+	return s.exec(query, append(values, id)...)
+}
+
+// Delete removes the row with the id. It returns ErrNotFound if there is no
+// such row.
+func (s *Store) Delete(id model.Identifier) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s = %s", s.Table, s.Key, s.Dialect.Placeholder(1))
+	return s.exec(query, id)
+}
+
+func (s *Store) exec(query string, args ...interface{}) error {
+	res, err := s.DB.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// List returns at most limit rows with a key greater than offsetId, by key
+// order. A nil offsetId starts at the first row, a limit of 0 returns all
+// the rows. The next page starts after the id of the last row, this is how
+// the FindAll... methods page with an offsetId.
+func (s *Store) List(offsetId model.Identifier, limit int) (*Rows, error) {
+	var (
+		buf  bytes.Buffer
+		args []interface{}
+	)
+	fmt.Fprintf(&buf, "SELECT %s, %s FROM %s", s.Key, strings.Join(s.Columns, ", "), s.Table)
+	if offsetId != nil {
+		args = append(args, offsetId)
+		fmt.Fprintf(&buf, " WHERE %s > %s", s.Key, s.Dialect.Placeholder(len(args)))
+	}
+	fmt.Fprintf(&buf, " ORDER BY %s", s.Key)
+	if limit > 0 {
+		args = append(args, limit)
+		fmt.Fprintf(&buf, " LIMIT %s", s.Dialect.Placeholder(len(args)))
+	}
+
+	rows, err := s.DB.Query(buf.String(), args...)
+	if err != nil {
+		return nil, err
+	}
+	return &Rows{rows: rows, driver: s.Driver}, nil
+}
+
+// Rows is the result of Store.List.
+type Rows struct {
+	rows   *sql.Rows
+	driver *Driver
+}
+
+// Next prepares the next row for Scan, it returns false after the last row.
+func (r *Rows) Next() bool {
+	return r.rows.Next()
+}
+
+// Scan reads the columns of the current row into dest and returns its id.
+func (r *Rows) Scan(dest ...interface{}) (model.Identifier, error) {
+	var id model.Identifier = new(IntId)
+	if r.driver.String {
+		id = new(StringId)
+	}
+	if err := r.rows.Scan(append([]interface{}{id}, dest...)...); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// Err returns the error met while iterating.
+func (r *Rows) Err() error {
+	return r.rows.Err()
+}
+
+// Close closes the rows, it is called by Next after the last row.
+func (r *Rows) Close() error {
+	return r.rows.Close()
+}
@@ -0,0 +1,399 @@
+package sqldriver
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fake is an in-process stand-in for a SQL database, it runs the statements
+// of Store only.
+type fake struct {
+	mux    sync.Mutex
+	tables map[string]*fakeTable
+}
+
+type fakeTable struct {
+	key  string
+	next int64
+	rows []map[string]driver.Value
+}
+
+var (
+	fakes   = make(map[string]*fake)
+	fakeMux sync.Mutex
+)
+
+func init() {
+	sql.Register("sqldriver-fake", fakeDriver{})
+}
+
+// openFake returns a database with the tables of the given keys.
+func openFake(t *testing.T, name string, keys map[string]string) *sql.DB {
+	f := &fake{tables: make(map[string]*fakeTable)}
+	for table, key := range keys {
+		f.tables[table] = &fakeTable{key: key}
+	}
+	fakeMux.Lock()
+	fakes[name] = f
+	fakeMux.Unlock()
+	db, err := sql.Open("sqldriver-fake", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeMux.Lock()
+	defer fakeMux.Unlock()
+	f, ok := fakes[name]
+	if !ok {
+		return nil, errors.New("fake: no database " + name)
+	}
+	return &fakeConn{f}, nil
+}
+
+type fakeConn struct{ f *fake }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{c.f, query}, nil
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return nil, errors.New("fake: no transaction") }
+
+type fakeStmt struct {
+	f     *fake
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+type fakeResult struct{ id, n int64 }
+
+func (r fakeResult) LastInsertId() (int64, error) { return r.id, nil }
+func (r fakeResult) RowsAffected() (int64, error) { return r.n, nil }
+
+type fakeRows struct {
+	cols []string
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+var (
+	insertRe = regexp.MustCompile(`^INSERT INTO (\w+) \((.+)\) VALUES \(.+\)(?: RETURNING (\w+))?$`)
+	selectRe = regexp.MustCompile(`^SELECT (.+) FROM (\w+)(?: WHERE (\w+) (=|>) \S+)?(?: ORDER BY \w+)?( LIMIT \S+)?$`)
+	updateRe = regexp.MustCompile(`^UPDATE (\w+) SET (.+) WHERE \w+ = \S+$`)
+	deleteRe = regexp.MustCompile(`^DELETE FROM (\w+) WHERE \w+ = \S+$`)
+)
+
+func less(a, b driver.Value) bool {
+	switch a := a.(type) {
+	case int64:
+		b, ok := b.(int64)
+		return ok && a < b
+	case string:
+		b, ok := b.(string)
+		return ok && a < b
+	}
+	return false
+}
+
+func columns(list string) []string {
+	cols := strings.Split(list, ", ")
+	for i, c := range cols {
+		cols[i] = strings.TrimSuffix(strings.Split(c, " = ")[0], " ")
+	}
+	return cols
+}
+
+func (s *fakeStmt) table(name string) (*fakeTable, error) {
+	t, ok := s.f.tables[name]
+	if !ok {
+		return nil, errors.New("fake: no table " + name)
+	}
+	return t, nil
+}
+
+// find returns the indexes of the rows with the key.
+func (t *fakeTable) find(key driver.Value) []int {
+	for i, row := range t.rows {
+		if row[t.key] == key {
+			return []int{i}
+		}
+	}
+	return nil
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.f.mux.Lock()
+	defer s.f.mux.Unlock()
+	if m := insertRe.FindStringSubmatch(s.query); m != nil {
+		t, err := s.table(m[1])
+		if err != nil {
+			return nil, err
+		}
+		row := make(map[string]driver.Value)
+		for i, c := range columns(m[2]) {
+			row[c] = args[i]
+		}
+		if _, ok := row[t.key]; !ok {
+			t.next++
+			row[t.key] = t.next
+		}
+		if t.find(row[t.key]) != nil {
+			return nil, errors.New("fake: duplicated key")
+		}
+		t.rows = append(t.rows, row)
+		id, _ := row[t.key].(int64)
+		return fakeResult{id, 1}, nil
+	}
+	if m := updateRe.FindStringSubmatch(s.query); m != nil {
+		t, err := s.table(m[1])
+		if err != nil {
+			return nil, err
+		}
+		found := t.find(args[len(args)-1])
+		for _, i := range found {
+			for j, c := range columns(m[2]) {
+				t.rows[i][c] = args[j]
+			}
+		}
+		return fakeResult{0, int64(len(found))}, nil
+	}
+	if m := deleteRe.FindStringSubmatch(s.query); m != nil {
+		t, err := s.table(m[1])
+		if err != nil {
+			return nil, err
+		}
+		found := t.find(args[0])
+		for _, i := range found {
+			t.rows = append(t.rows[:i], t.rows[i+1:]...)
+		}
+		return fakeResult{0, int64(len(found))}, nil
+	}
+	return nil, fmt.Errorf("fake: unsupported statement %q", s.query)
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if m := insertRe.FindStringSubmatch(s.query); m != nil && m[3] != "" {
+		res, err := s.Exec(args)
+		if err != nil {
+			return nil, err
+		}
+		id, _ := res.LastInsertId()
+		return &fakeRows{cols: []string{m[3]}, rows: [][]driver.Value{{id}}}, nil
+	}
+
+	s.f.mux.Lock()
+	defer s.f.mux.Unlock()
+	m := selectRe.FindStringSubmatch(s.query)
+	if m == nil {
+		return nil, fmt.Errorf("fake: unsupported query %q", s.query)
+	}
+	t, err := s.table(m[2])
+	if err != nil {
+		return nil, err
+	}
+	var selected []map[string]driver.Value
+	for _, row := range t.rows {
+		switch m[4] {
+		case "=":
+			if row[m[3]] != args[0] {
+				continue
+			}
+		case ">":
+			if !less(args[0], row[m[3]]) {
+				continue
+			}
+		}
+		selected = append(selected, row)
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		return less(selected[i][t.key], selected[j][t.key])
+	})
+	if m[5] != "" {
+		if limit := int(args[len(args)-1].(int64)); limit < len(selected) {
+			selected = selected[:limit]
+		}
+	}
+
+	cols := columns(m[1])
+	r := &fakeRows{cols: cols}
+	for _, row := range selected {
+		values := make([]driver.Value, len(cols))
+		for i, c := range cols {
+			values[i] = row[c]
+		}
+		r.rows = append(r.rows, values)
+	}
+	return r, nil
+}
+
+func TestStoreIntKeys(t *testing.T) {
+	for _, dialect := range []*Dialect{SQLite, PostgreSQL} {
+		db := openFake(t, fmt.Sprintf("int%p", dialect), map[string]string{"users": "id"})
+		s, err := NewStore(db, &Driver{}, dialect, "users", "id", "name", "age")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var ids []string
+		for i, name := range []string{"ann", "bob", "cid"} {
+			id, err := s.Insert(nil, name, 20+i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := id.(*IntId); !ok || !id.Valid() {
+				t.Fatalf("Insert returned %#v", id)
+			}
+			ids = append(ids, id.Encode())
+		}
+		if strings.Join(ids, ",") != "1,2,3" {
+			t.Errorf("ids = %v", ids)
+		}
+
+		id, _ := s.Driver.DecodeId("2")
+		if err = s.Update(id, "bobby", 31); err != nil {
+			t.Fatal(err)
+		}
+		var (
+			name string
+			age  int
+		)
+		if err = s.Get(id, &name, &age); err != nil || name != "bobby" || age != 31 {
+			t.Errorf("Get = %q, %d, %v", name, age, err)
+		}
+
+		rows, err := s.List(id, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var page []string
+		for rows.Next() {
+			id, err := rows.Scan(&name, &age)
+			if err != nil {
+				t.Fatal(err)
+			}
+			page = append(page, id.Encode()+":"+name)
+		}
+		if err = rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(page, ",") != "3:cid" {
+			t.Errorf("List after 2 = %v", page)
+		}
+
+		if err = s.Delete(id); err != nil {
+			t.Fatal(err)
+		}
+		if err = s.Get(id, &name, &age); err != ErrNotFound {
+			t.Errorf("Get deleted = %v, want ErrNotFound", err)
+		}
+		if err = s.Delete(id); err != ErrNotFound {
+			t.Errorf("Delete deleted = %v, want ErrNotFound", err)
+		}
+		db.Close()
+	}
+}
+
+func TestStoreStringKeys(t *testing.T) {
+	db := openFake(t, "string", map[string]string{"groups": "code"})
+	defer db.Close()
+	s, err := NewStore(db, &Driver{String: true}, SQLite, "groups", "code", "title")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	random, err := s.Insert(nil, "random")
+	if err != nil || len(random.Encode()) != 32 {
+		t.Fatalf("Insert = %v, %v", random, err)
+	}
+	// the random keys are hexadecimal, so they sort before these ones
+	for _, code := range []string{"y", "x", "z"} {
+		id := StringId(code)
+		if _, err = s.Insert(&id, "group "+code); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dup := StringId("x")
+	if _, err = s.Insert(&dup, "again"); err == nil {
+		t.Error("want error for a duplicated key")
+	}
+
+	rows, err := s.List(nil, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var page []string
+	for rows.Next() {
+		var title string
+		id, err := rows.Scan(&title)
+		if err != nil {
+			t.Fatal(err)
+		}
+		page = append(page, id.Encode())
+	}
+	if strings.Join(page, ",") != random.Encode()+",x" {
+		t.Errorf("List = %v", page)
+	}
+}
+
+func TestNewStore(t *testing.T) {
+	if _, err := NewStore(nil, &Driver{}, SQLite, "users; DROP TABLE users", "id", "name"); err == nil {
+		t.Error("want error for an invalid table name")
+	}
+	if _, err := NewStore(nil, &Driver{}, SQLite, "users", "id"); err == nil {
+		t.Error("want error without column")
+	}
+}
+
+func TestIds(t *testing.T) {
+	d := &Driver{}
+	for _, v := range []interface{}{"42", []byte("42"), 42, int64(42), uint16(42), IntId(42)} {
+		id, err := d.DecodeId(v)
+		if err != nil || id.Encode() != "42" {
+			t.Errorf("DecodeId(%#v) = %v, %v", v, id, err)
+		}
+	}
+	for _, v := range []interface{}{"", "x", 0, -1, 1.5, nil} {
+		if d.ValidIdRep(v) {
+			t.Errorf("ValidIdRep(%#v) = true", v)
+		}
+	}
+	if (&Driver{String: true}).ValidIdRep("") {
+		t.Error("empty StringId is valid")
+	}
+
+	var id IntId
+	if err := id.Scan(int64(7)); err != nil || id != 7 {
+		t.Errorf("Scan = %d, %v", id, err)
+	}
+	if v, err := id.Value(); err != nil || v != int64(7) {
+		t.Errorf("Value = %#v, %v", v, err)
+	}
+	var sid StringId
+	if err := sid.Scan([]byte("abc")); err != nil || sid != "abc" {
+		t.Errorf("Scan = %q, %v", sid, err)
+	}
+}
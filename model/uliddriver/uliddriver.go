@@ -0,0 +1,214 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package uliddriver is a model.Driver whose ids are ULIDs, registered as
+"ulid":
+
+	import _ "github.com/kidstuff/toys/model/uliddriver"
+
+	driver := model.MustLoad("ulid")
+
+The ULIDs of a Driver are monotonic, an id is always greater than the ids
+generated before it, so the encoded ids sort by creation time and can be
+used as the offsetId of the FindAll... methods.
+*/
+package uliddriver
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/kidstuff/toys/model"
+	"io"
+	"sync"
+	"time"
+)
+
+func init() {
+	model.Register("ulid", New())
+}
+
+// Driver implements model.Driver.
+type Driver struct {
+	now  func() time.Time
+	rand io.Reader
+	mux  sync.Mutex
+	last ULID
+}
+
+var _ model.Driver = &Driver{}
+
+// New returns a new Driver.
+func New() *Driver {
+	return NewWith(time.Now, rand.Reader)
+}
+
+// NewWith likes New but reads the time from now and the random bits from r,
+// the tests can give a fixed clock and a seeded source to get the same ids
+// on every run.
+func NewWith(now func() time.Time, r io.Reader) *Driver {
+	return &Driver{now: now, rand: r}
+}
+
+// NewId returns a new ULID, it panics if the random source fails.
+func (d *Driver) NewId() model.Identifier {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	var u ULID
+	ms := uint64(d.now().UnixNano() / int64(time.Millisecond))
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], ms)
+	copy(u[:6], ts[2:])
+	if bytes.Compare(u[:6], d.last[:6]) <= 0 {
+		// same millisecond or clock going back, take the next id
+		u = d.last
+		u.increment()
+	} else if _, err := io.ReadFull(d.rand, u[6:]); err != nil {
+		panic("uliddriver: cannot read random bits: " + err.Error())
+	}
+	d.last = u
+	return &u
+}
+
+// DecodeId returns the ULID of v, see ULID.Decode.
+func (d *Driver) DecodeId(v interface{}) (model.Identifier, error) {
+	u := new(ULID)
+	if err := u.Decode(v); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// ValidIdRep reports whether v represents a valid ULID.
+func (d *Driver) ValidIdRep(v interface{}) bool {
+	_, err := d.DecodeId(v)
+	return err == nil
+}
+
+// ULID implements model.Identifier, the zero ULID is not valid.
+type ULID [16]byte
+
+var _ model.Identifier = new(ULID)
+
+// crockford is the base32 alphabet of the ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var decoding [256]byte
+
+func init() {
+	for i := range decoding {
+		decoding[i] = 0xff
+	}
+	for i := 0; i < len(crockford); i++ {
+		decoding[crockford[i]] = byte(i)
+		decoding[crockford[i]|0x20] = byte(i) // lower case
+	}
+	for _, alias := range []struct{ c, d byte }{{'I', '1'}, {'L', '1'}, {'O', '0'}} {
+		decoding[alias.c] = decoding[alias.d]
+		decoding[alias.c|0x20] = decoding[alias.d]
+	}
+}
+
+// increment adds one to the random bits, or to the timestamp on overflow.
+func (u *ULID) increment() {
+	for i := 15; i >= 0; i-- {
+		u[i]++
+		if u[i] != 0 {
+			return
+		}
+	}
+}
+
+// Decode sets the ULID from v: a ULID, a [16]byte, the 16 raw bytes or the
+// 26 characters of the text in upper or lower case. I and L are read as 1
+// and O as 0.
+func (u *ULID) Decode(v interface{}) error {
+	switch x := v.(type) {
+	case ULID:
+		*u = x
+	case *ULID:
+		if x == nil {
+			return errors.New("uliddriver: nil id")
+		}
+		*u = *x
+	case [16]byte:
+		*u = x
+	case []byte:
+		if len(x) == 16 {
+			copy(u[:], x)
+			break
+		}
+		if err := u.parse(string(x)); err != nil {
+			return err
+		}
+	case string:
+		if err := u.parse(x); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("uliddriver: cannot decode id from %T", v)
+	}
+	if !u.Valid() {
+		return errors.New("uliddriver: invalid zero ULID")
+	}
+	return nil
+}
+
+func (u *ULID) parse(s string) error {
+	// the first character holds 3 bits only
+	if len(s) != 26 || decoding[s[0]] > 7 {
+		return fmt.Errorf("uliddriver: invalid ULID %q", s)
+	}
+	var b ULID
+	for i := 0; i < len(s); i++ {
+		d := decoding[s[i]]
+		if d == 0xff {
+			return fmt.Errorf("uliddriver: invalid ULID %q", s)
+		}
+		// shift the 130 bits number left by 5 and add d
+		carry := uint16(d)
+		for j := 15; j >= 0; j-- {
+			n := uint16(b[j])<<5 | carry
+			b[j] = byte(n)
+			carry = n >> 8
+		}
+	}
+	*u = b
+	return nil
+}
+
+// Encode returns the 26 characters of the ULID in upper case, like
+// 01H8XGJWBWBAQ4Z8Y6Z5Q3V3K1.
+func (u *ULID) Encode() string {
+	var buf [26]byte
+	n := *u
+	for i := 25; i >= 0; i-- {
+		// divide the number by 32, the remainder is the digit
+		var rem uint16
+		for j := 0; j < 16; j++ {
+			cur := rem<<8 | uint16(n[j])
+			n[j] = byte(cur >> 5)
+			rem = cur & 31
+		}
+		buf[i] = crockford[rem]
+	}
+	return string(buf[:])
+}
+
+// Valid reports whether the ULID is not zero.
+func (u *ULID) Valid() bool {
+	return u != nil && *u != ULID{}
+}
+
+// Time returns the creation time of the ULID.
+func (u *ULID) Time() time.Time {
+	var ts [8]byte
+	copy(ts[2:], u[:6])
+	ms := int64(binary.BigEndian.Uint64(ts[:]))
+	return time.Unix(ms/1000, ms%1000*int64(time.Millisecond))
+}
@@ -0,0 +1,91 @@
+package uliddriver
+
+import (
+	"github.com/kidstuff/toys/model"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestRegistered(t *testing.T) {
+	d, err := model.Load("ulid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := d.NewId(); !id.Valid() || len(id.Encode()) != 26 {
+		t.Errorf("NewId = %s", id.Encode())
+	}
+}
+
+func TestDecode(t *testing.T) {
+	d := New()
+	want := "01ARZ3NDEKTSV4RRFFQ69G5FAV"
+	for _, v := range []interface{}{
+		want,
+		"01arz3ndektsv4rrffq69g5fav",
+		"0LARZ3NDEKTSV4RRFFQ69G5FAV",
+		[]byte(want),
+	} {
+		id, err := d.DecodeId(v)
+		if err != nil {
+			t.Errorf("DecodeId(%q): %v", v, err)
+			continue
+		}
+		if id.Encode() != want {
+			t.Errorf("DecodeId(%q) = %s", v, id.Encode())
+		}
+	}
+
+	id, _ := d.DecodeId(want)
+	u := id.(*ULID)
+	if got := u.Time().UnixNano() / int64(time.Millisecond); got != 1469922850259 {
+		t.Errorf("Time = %d", got)
+	}
+	raw, err := d.DecodeId(u[:])
+	if err != nil || raw.Encode() != want {
+		t.Errorf("DecodeId(raw) = %v, %v", raw, err)
+	}
+
+	for _, v := range []interface{}{
+		"", "01ARZ3NDEKTSV4RRFFQ69G5FA", "01ARZ3NDEKTSV4RRFFQ69G5FAU",
+		"81ARZ3NDEKTSV4RRFFQ69G5FAV", "00000000000000000000000000", 42,
+	} {
+		if d.ValidIdRep(v) {
+			t.Errorf("ValidIdRep(%v) = true", v)
+		}
+	}
+}
+
+func TestMonotonic(t *testing.T) {
+	now := time.Date(2013, 3, 7, 10, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	a := NewWith(clock, rand.New(rand.NewSource(1)))
+	b := NewWith(clock, rand.New(rand.NewSource(1)))
+
+	var ids []string
+	for i := 0; i < 1000; i++ {
+		switch i {
+		case 500:
+			now = now.Add(time.Millisecond)
+		case 700:
+			now = now.Add(-time.Second)
+		}
+		id := a.NewId()
+		if id.Encode() != b.NewId().Encode() {
+			t.Fatal("not deterministic")
+		}
+		ids = append(ids, id.Encode())
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Error("ids are not sorted")
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] == ids[i-1] {
+			t.Fatalf("duplicated id %s", ids[i])
+		}
+	}
+	if got := a.NewId().(*ULID).Time(); !got.Equal(now.Add(time.Second)) {
+		t.Errorf("Time = %v, want the last time %v", got, now.Add(time.Second))
+	}
+}
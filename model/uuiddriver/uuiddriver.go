@@ -0,0 +1,250 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package uuiddriver is a model.Driver whose ids are UUIDs, registered as
+"uuid4" for the random UUIDs and "uuid7" for the time ordered ones:
+
+	import _ "github.com/kidstuff/toys/model/uuiddriver"
+
+	driver := model.MustLoad("uuid7")
+
+The UUIDv7 of a Driver are monotonic, an id is always greater than the ids
+generated before it, so the encoded ids sort by creation time and can be
+used as the offsetId of the FindAll... methods.
+*/
+package uuiddriver
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/kidstuff/toys/model"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	model.Register("uuid4", New(4))
+	model.Register("uuid7", New(7))
+}
+
+// Driver implements model.Driver for the UUIDs of a version.
+type Driver struct {
+	version int
+	now     func() time.Time
+	rand    io.Reader
+	mux     sync.Mutex
+	last    UUID // the last UUIDv7
+}
+
+var _ model.Driver = &Driver{}
+
+// New returns a Driver of the UUID version 4 or 7. It panics for the other
+// versions.
+func New(version int) *Driver {
+	return NewWith(version, time.Now, rand.Reader)
+}
+
+// NewWith likes New but reads the time from now and the random bits from r,
+// the tests can give a fixed clock and a seeded source to get the same ids
+// on every run.
+func NewWith(version int, now func() time.Time, r io.Reader) *Driver {
+	if version != 4 && version != 7 {
+		panic(fmt.Sprintf("uuiddriver: unsupported version %d", version))
+	}
+	return &Driver{version: version, now: now, rand: r}
+}
+
+// NewId returns a new UUID, it panics if the random source fails.
+func (d *Driver) NewId() model.Identifier {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	var u UUID
+	if _, err := io.ReadFull(d.rand, u[:]); err != nil {
+		panic("uuiddriver: cannot read random bits: " + err.Error())
+	}
+	if d.version == 7 {
+		ms := uint64(d.now().UnixNano() / int64(time.Millisecond))
+		var ts [8]byte
+		binary.BigEndian.PutUint64(ts[:], ms)
+		copy(u[:6], ts[2:])
+		u.setVersion(7)
+		if bytes.Compare(u[:], d.last[:]) <= 0 {
+			// same millisecond or clock going back, take the next id
+			u = d.last
+			u.increment()
+		}
+		d.last = u
+	} else {
+		u.setVersion(4)
+	}
+	return &u
+}
+
+// DecodeId returns the UUID of v, see UUID.Decode. The UUID must be of the
+// version of d.
+func (d *Driver) DecodeId(v interface{}) (model.Identifier, error) {
+	u := new(UUID)
+	if err := u.Decode(v); err != nil {
+		return nil, err
+	}
+	if u.Version() != d.version {
+		return nil, fmt.Errorf("uuiddriver: %s is not a UUIDv%d", u.Encode(), d.version)
+	}
+	return u, nil
+}
+
+// ValidIdRep reports whether v represents a valid UUID of the version of d.
+func (d *Driver) ValidIdRep(v interface{}) bool {
+	_, err := d.DecodeId(v)
+	return err == nil
+}
+
+// UUID implements model.Identifier.
+type UUID [16]byte
+
+var _ model.Identifier = new(UUID)
+
+func (u *UUID) setVersion(v byte) {
+	u[6] = u[6]&0x0f | v<<4
+	u[8] = u[8]&0x3f | 0x80
+}
+
+// increment adds one to the random bits of a UUIDv7, or to its timestamp on
+// overflow.
+func (u *UUID) increment() {
+	for i := 15; i >= 0; i-- {
+		switch i {
+		case 8:
+			// 6 random bits after the variant
+			if u[8]&0x3f != 0x3f {
+				u[8]++
+				return
+			}
+			u[8] &^= 0x3f
+			continue
+		case 6:
+			// 4 random bits after the version
+			if u[6]&0x0f != 0x0f {
+				u[6]++
+				return
+			}
+			u[6] &^= 0x0f
+			continue
+		}
+		u[i]++
+		if u[i] != 0 {
+			return
+		}
+	}
+}
+
+// Decode sets the UUID from v: a UUID, a [16]byte, the 16 raw bytes or the
+// text of a UUID, canonical or without the hyphens, in upper or lower case,
+// between braces or after urn:uuid:.
+func (u *UUID) Decode(v interface{}) error {
+	var text string
+	switch x := v.(type) {
+	case UUID:
+		*u = x
+	case *UUID:
+		if x == nil {
+			return errors.New("uuiddriver: nil id")
+		}
+		*u = *x
+	case [16]byte:
+		*u = x
+	case []byte:
+		if len(x) == 16 {
+			copy(u[:], x)
+			break
+		}
+		text = string(x)
+	case string:
+		text = x
+	default:
+		return fmt.Errorf("uuiddriver: cannot decode id from %T", v)
+	}
+	if text != "" {
+		if err := u.parse(text); err != nil {
+			return err
+		}
+	}
+	if !u.Valid() {
+		return fmt.Errorf("uuiddriver: invalid UUID %v", v)
+	}
+	return nil
+}
+
+func (u *UUID) parse(s string) error {
+	t := strings.TrimPrefix(strings.ToLower(s), "urn:uuid:")
+	if strings.HasPrefix(t, "{") && strings.HasSuffix(t, "}") {
+		t = t[1 : len(t)-1]
+	}
+	if len(t) == 36 {
+		if t[8] != '-' || t[13] != '-' || t[18] != '-' || t[23] != '-' {
+			return fmt.Errorf("uuiddriver: invalid UUID %q", s)
+		}
+		t = t[:8] + t[9:13] + t[14:18] + t[19:23] + t[24:]
+	}
+	if len(t) != 32 {
+		return fmt.Errorf("uuiddriver: invalid UUID %q", s)
+	}
+	var b UUID
+	if _, err := hex.Decode(b[:], []byte(t)); err != nil {
+		return fmt.Errorf("uuiddriver: invalid UUID %q", s)
+	}
+	*u = b
+	return nil
+}
+
+// Encode returns the canonical lowercase form of the UUID, like
+// 0189c3b2-7a1e-7c3d-9f2a-5b1e0d4c3a21.
+func (u *UUID) Encode() string {
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:])
+}
+
+// Valid reports whether the UUID has the RFC 4122 variant and the version
+// 4 or 7.
+func (u *UUID) Valid() bool {
+	if u == nil || u[8]&0xc0 != 0x80 {
+		return false
+	}
+	v := u.Version()
+	return v == 4 || v == 7
+}
+
+// Version returns the version of the UUID.
+func (u *UUID) Version() int {
+	return int(u[6] >> 4)
+}
+
+// Time returns the creation time of a UUIDv7, the zero time for the other
+// versions.
+func (u *UUID) Time() time.Time {
+	if u.Version() != 7 {
+		return time.Time{}
+	}
+	var ts [8]byte
+	copy(ts[2:], u[:6])
+	ms := int64(binary.BigEndian.Uint64(ts[:]))
+	return time.Unix(ms/1000, ms%1000*int64(time.Millisecond))
+}
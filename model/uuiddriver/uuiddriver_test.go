@@ -0,0 +1,97 @@
+package uuiddriver
+
+import (
+	"github.com/kidstuff/toys/model"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestRegistered(t *testing.T) {
+	for name, version := range map[string]int{"uuid4": 4, "uuid7": 7} {
+		d, err := model.Load(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id := d.NewId()
+		if !id.Valid() || id.(*UUID).Version() != version {
+			t.Errorf("%s: NewId = %s", name, id.Encode())
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	d := New(7)
+	want := "0189c3b2-7a1e-7c3d-9f2a-5b1e0d4c3a21"
+	for _, v := range []interface{}{
+		want,
+		"0189C3B2-7A1E-7C3D-9F2A-5B1E0D4C3A21",
+		"{0189c3b2-7a1e-7c3d-9f2a-5b1e0d4c3a21}",
+		"urn:uuid:0189c3b2-7a1e-7c3d-9f2a-5b1e0d4c3a21",
+		"0189c3b27a1e7c3d9f2a5b1e0d4c3a21",
+		[]byte(want),
+		[]byte{0x01, 0x89, 0xc3, 0xb2, 0x7a, 0x1e, 0x7c, 0x3d, 0x9f, 0x2a, 0x5b, 0x1e, 0x0d, 0x4c, 0x3a, 0x21},
+	} {
+		id, err := d.DecodeId(v)
+		if err != nil {
+			t.Errorf("DecodeId(%q): %v", v, err)
+			continue
+		}
+		if id.Encode() != want {
+			t.Errorf("DecodeId(%q) = %s", v, id.Encode())
+		}
+	}
+	if got := d.NewId().(*UUID).Time(); time.Since(got) > time.Minute {
+		t.Errorf("Time = %v", got)
+	}
+
+	for _, v := range []interface{}{
+		"", "0189c3b2-7a1e-7c3d-9f2a", "0189c3b2_7a1e_7c3d_9f2a_5b1e0d4c3a21",
+		"zz89c3b2-7a1e-7c3d-9f2a-5b1e0d4c3a21",
+		"00000000-0000-0000-0000-000000000000",
+		"0189c3b2-7a1e-4c3d-9f2a-5b1e0d4c3a21", // version 4
+		"0189c3b2-7a1e-7c3d-1f2a-5b1e0d4c3a21", // not the RFC variant
+		[]byte{1, 2, 3}, 42,
+	} {
+		if d.ValidIdRep(v) {
+			t.Errorf("ValidIdRep(%v) = true", v)
+		}
+	}
+	if !New(4).ValidIdRep("0189c3b2-7a1e-4c3d-9f2a-5b1e0d4c3a21") {
+		t.Error("UUIDv4 not valid for uuid4")
+	}
+}
+
+func TestMonotonic(t *testing.T) {
+	now := time.Date(2013, 3, 7, 10, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	a := NewWith(7, clock, rand.New(rand.NewSource(1)))
+	b := NewWith(7, clock, rand.New(rand.NewSource(1)))
+
+	var ids []string
+	for i := 0; i < 1000; i++ {
+		switch i {
+		case 500:
+			now = now.Add(time.Millisecond)
+		case 700:
+			now = now.Add(-time.Second) // clock going back
+		}
+		id := a.NewId()
+		if id.Encode() != b.NewId().Encode() {
+			t.Fatal("not deterministic")
+		}
+		if id.(*UUID).Version() != 7 || !id.Valid() {
+			t.Fatalf("invalid id %s", id.Encode())
+		}
+		ids = append(ids, id.Encode())
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Error("ids are not sorted")
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] == ids[i-1] {
+			t.Fatalf("duplicated id %s", ids[i])
+		}
+	}
+}
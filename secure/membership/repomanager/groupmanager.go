@@ -0,0 +1,106 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package repomanager
+
+import (
+	"github.com/kidstuff/toys/model"
+	"github.com/kidstuff/toys/secure/membership"
+	"sync"
+)
+
+// GroupManager implements membership.GroupManager over a repository of
+// Group records. The group names are unique among the groups added by the
+// GroupManager.
+type GroupManager struct {
+	repo model.Repository
+	mux  sync.Mutex // serializes the name checks of AddGroupDetail
+}
+
+var _ membership.GroupManager = &GroupManager{}
+
+// NewGroupManager returns a GroupManager storing the groups in repo, opened
+// with NewGroup.
+func NewGroupManager(repo model.Repository) *GroupManager {
+	return &GroupManager{repo: repo}
+}
+
+// AddGroupDetail adds a group, it returns membership.ErrDuplicateName if a
+// group has the name.
+func (m *GroupManager) AddGroupDetail(name string, info membership.GroupInfo, pri map[string]bool) (membership.Grouper, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if _, err := m.FindGroupByName(name); err == nil {
+		return nil, membership.ErrDuplicateName
+	} else if err != model.ErrNotFound {
+		return nil, err
+	}
+
+	g := &Group{}
+	g.Name = name
+	g.Info = info
+	g.Privilege = pri
+	if err := m.repo.Insert(g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// UpdateInfo changes the information of the group with the id.
+func (m *GroupManager) UpdateInfo(id model.Identifier, info membership.GroupInfo) error {
+	g := &Group{}
+	if err := m.repo.Get(id, g); err != nil {
+		return err
+	}
+	g.Info = info
+	return m.repo.Update(g)
+}
+
+// UpdatePrivilege changes the privilege of the group with the id.
+func (m *GroupManager) UpdatePrivilege(id model.Identifier, pri map[string]bool) error {
+	g := &Group{}
+	if err := m.repo.Get(id, g); err != nil {
+		return err
+	}
+	g.Privilege = pri
+	return m.repo.Update(g)
+}
+
+// FindGroup returns the group with the id, or model.ErrNotFound.
+func (m *GroupManager) FindGroup(id model.Identifier) (membership.Grouper, error) {
+	g := &Group{}
+	if err := m.repo.Get(id, g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// FindGroupByName returns the group with the name, or model.ErrNotFound.
+func (m *GroupManager) FindGroupByName(name string) (membership.Grouper, error) {
+	found, err := m.repo.Find(model.Query{
+		Filters: []model.Filter{{Field: "Name", Op: model.Eq, Value: name}},
+		Limit:   1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return nil, model.ErrNotFound
+	}
+	return found[0].(*Group), nil
+}
+
+// FindAllGroup returns limit groups after the one with offsetId, in the
+// order of their ids. offsetId is nil for the first page.
+func (m *GroupManager) FindAllGroup(offsetId model.Identifier, limit int) ([]membership.Grouper, error) {
+	found, err := m.repo.Find(model.Query{OffsetId: offsetId, Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]membership.Grouper, len(found))
+	for i, rec := range found {
+		groups[i] = rec.(*Group)
+	}
+	return groups, nil
+}
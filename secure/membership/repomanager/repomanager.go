@@ -0,0 +1,82 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package repomanager implements membership.UserManager and
+membership.GroupManager over model.Repository, so they run on every model
+driver implementing model.RepositoryOpener:
+
+	import _ "github.com/kidstuff/toys/model/memdriver"
+
+	groups, err := model.OpenRepository("mem", "groups", repomanager.NewGroup)
+	users, err := model.OpenRepository("mem", "users", repomanager.NewUser)
+
+	gm := repomanager.NewGroupManager(groups)
+	um := repomanager.NewUserManager(model.MustLoad("mem"), users, sess)
+	um.SetGroupManager(gm)
+
+The UserManager of a request keeps the logged user in the session given by
+sess, a sessions.Provider.
+*/
+package repomanager
+
+import (
+	"github.com/kidstuff/toys/model"
+	"github.com/kidstuff/toys/secure/membership"
+)
+
+// Group is the record of a group.
+type Group struct {
+	Id model.Identifier
+	membership.Group
+}
+
+var (
+	_ membership.Grouper = &Group{}
+	_ model.Record       = &Group{}
+)
+
+// NewGroup returns an empty Group, to open the repository of the groups.
+func NewGroup() model.Record {
+	return &Group{}
+}
+
+func (g *Group) GetId() model.Identifier {
+	return g.Id
+}
+
+func (g *Group) SetId(id model.Identifier) error {
+	g.Id = id
+	return nil
+}
+
+// User is the record of a user.
+type User struct {
+	Id model.Identifier
+	membership.Account
+	BriefGroups []membership.BriefGroup
+}
+
+var (
+	_ membership.User = &User{}
+	_ model.Record    = &User{}
+)
+
+// NewUser returns an empty User, to open the repository of the users.
+func NewUser() model.Record {
+	return &User{}
+}
+
+func (u *User) GetId() model.Identifier {
+	return u.Id
+}
+
+func (u *User) SetId(id model.Identifier) error {
+	u.Id = id
+	return nil
+}
+
+func (u *User) GetBriefGroups() []membership.BriefGroup {
+	return u.BriefGroups
+}
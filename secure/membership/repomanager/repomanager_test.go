@@ -0,0 +1,265 @@
+package repomanager
+
+import (
+	"fmt"
+	"github.com/kidstuff/toys/model"
+	"github.com/kidstuff/toys/model/memdriver"
+	"github.com/kidstuff/toys/secure/membership"
+	"github.com/kidstuff/toys/secure/membership/sessions"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSession implements the parts of sessions.Provider used by UserManager.
+type fakeSession struct {
+	sessions.Provider
+	data map[string]interface{}
+}
+
+func (s *fakeSession) Set(name string, val interface{}) error {
+	s.data[name] = val
+	return nil
+}
+
+func (s *fakeSession) GetString(name string) string {
+	v, _ := s.data[name].(string)
+	return v
+}
+
+func (s *fakeSession) GetInt(name string) int {
+	v, _ := s.data[name].(int)
+	return v
+}
+
+func (s *fakeSession) Delete(names ...string) error {
+	for _, name := range names {
+		delete(s.data, name)
+	}
+	return nil
+}
+
+func managers(t *testing.T) (*UserManager, *GroupManager, *fakeSession) {
+	d := memdriver.NewSequential(1)
+	users, err := d.Repository("users", NewUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups, err := d.Repository("groups", NewGroup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess := &fakeSession{data: make(map[string]interface{})}
+	gm := NewGroupManager(groups)
+	um := NewUserManager(d, users, sess)
+	um.SetGroupManager(gm)
+	return um, gm, sess
+}
+
+func TestGroupManager(t *testing.T) {
+	_, gm, _ := managers(t)
+	for _, name := range []string{"admin", "editor", "guest"} {
+		if _, err := gm.AddGroupDetail(name, membership.GroupInfo{}, map[string]bool{name: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := gm.AddGroupDetail("admin", membership.GroupInfo{}, nil); err != membership.ErrDuplicateName {
+		t.Errorf("duplicated name = %v", err)
+	}
+
+	g, err := gm.FindGroupByName("editor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = gm.UpdateInfo(g.GetId(), membership.GroupInfo{Description: "writes"}); err != nil {
+		t.Fatal(err)
+	}
+	if g, _ = gm.FindGroup(g.GetId()); g.GetInfomation().Description != "writes" {
+		t.Errorf("UpdateInfo, got %+v", g.GetInfomation())
+	}
+	if _, err = gm.FindGroupByName("nobody"); err != model.ErrNotFound {
+		t.Errorf("unknown name = %v", err)
+	}
+
+	page, err := gm.FindAllGroup(nil, 2)
+	if err != nil || len(page) != 2 {
+		t.Fatalf("FindAllGroup = %v, %v", page, err)
+	}
+	page, err = gm.FindAllGroup(page[1].GetId(), 2)
+	if err != nil || len(page) != 1 || page[0].GetName() != "guest" {
+		t.Errorf("second page = %v, %v", page, err)
+	}
+}
+
+func TestUserManager(t *testing.T) {
+	um, gm, sess := managers(t)
+	u, err := um.AddUser("gopher@example.com", "secret", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = um.AddUser("gopher@example.com", "other", false, true); err != membership.ErrDuplicateEmail {
+		t.Errorf("duplicated email = %v", err)
+	}
+
+	if _, err = um.ValidateUser("gopher@example.com", "wrong"); err != membership.ErrInvalidPassword {
+		t.Errorf("wrong password = %v", err)
+	}
+	if _, err = um.ValidateUser("nobody@example.com", "secret"); err != membership.ErrInvalidEmail {
+		t.Errorf("unknown email = %v", err)
+	}
+	if _, err = um.ValidateUser("gopher@example.com", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	code := u.GetConfirmCodes()[ActivateCode]
+	if ok, _ := um.ValidConfirmCode(u.GetId(), ActivateCode, "bad", false, true); ok {
+		t.Error("bad code is valid")
+	}
+	if ok, err := um.ValidConfirmCode(u.GetId(), ActivateCode, code, false, true); !ok || err != nil {
+		t.Fatalf("ValidConfirmCode = %v, %v", ok, err)
+	}
+	if u, _ = um.FindUser(u.GetId()); !u.IsApproved() || len(u.GetConfirmCodes()) != 0 {
+		t.Errorf("activated user %+v", u)
+	}
+
+	if err = um.ChangePassword(u.GetId(), "newer", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = um.ValidateUser("gopher@example.com", "newer"); err != nil {
+		t.Errorf("new password: %v", err)
+	}
+
+	if _, err = um.GetUser(); err != ErrNotLoggedIn {
+		t.Errorf("GetUser before Login = %v", err)
+	}
+	if err = um.Login(u.GetId(), 0); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := um.GetUser(); err != nil || got.GetEmail() != "gopher@example.com" {
+		t.Errorf("GetUser = %v, %v", got, err)
+	}
+	if n := um.CountUserOnline(); n != 1 {
+		t.Errorf("CountUserOnline = %d", n)
+	}
+	um.SetOnlineThreshold(time.Minute)
+	sess.data[RememberKey] = int(time.Now().Unix()) - 1
+	if _, err = um.GetUser(); err != ErrNotLoggedIn {
+		t.Errorf("GetUser after the remember time = %v", err)
+	}
+
+	g, err := gm.AddGroupDetail("editor", membership.GroupInfo{}, map[string]bool{"write": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = um.JoinGroup(u.GetId(), g); err != nil {
+		t.Fatal(err)
+	}
+	if err = um.UpdatePrivilege(u.GetId(), map[string]bool{"delete": false}, false); err != nil {
+		t.Fatal(err)
+	}
+	u, _ = um.FindUser(u.GetId())
+	if !um.Can(u, "write") || um.Can(u, "delete") || um.Can(u, "admin") {
+		t.Error("wrong privileges")
+	}
+
+	if err = um.DeleteUser(u.GetId()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = um.FindUserByEmail("gopher@example.com"); err != model.ErrNotFound {
+		t.Errorf("deleted user = %v", err)
+	}
+}
+
+func TestFindAllUser(t *testing.T) {
+	um, _, _ := managers(t)
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		if _, err := um.AddUser(email, "secret", false, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var all string
+	var offset model.Identifier
+	for {
+		page, err := um.FindAllUser(offset, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		for _, u := range page {
+			all += u.GetEmail() + " "
+		}
+		offset = page[len(page)-1].GetId()
+	}
+	if all != "a@example.com b@example.com c@example.com " {
+		t.Errorf("pages = %q", all)
+	}
+}
+
+// slowRepository lets the other goroutines run between a Get and the next
+// Update.
+type slowRepository struct {
+	model.Repository
+}
+
+func (r slowRepository) Get(id model.Identifier, rec model.Record) error {
+	err := r.Repository.Get(id, rec)
+	time.Sleep(time.Millisecond)
+	return err
+}
+
+func TestUserManagerConcurrentUpdates(t *testing.T) {
+	um, gm, _ := managers(t)
+	um.repo = slowRepository{um.repo}
+	u, err := um.AddUser("gopher@example.com", "secret", false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = um.Login(u.GetId(), 0); err != nil {
+		t.Fatal(err)
+	}
+	var groups []membership.Grouper
+	for i := 0; i < 50; i++ {
+		g, err := gm.AddGroupDetail(fmt.Sprint("group", i), membership.GroupInfo{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		groups = append(groups, g)
+	}
+
+	var wg sync.WaitGroup
+	for _, g := range groups {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := um.GetUser(); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func(g membership.Grouper) {
+			defer wg.Done()
+			if err := um.JoinGroup(u.GetId(), g); err != nil {
+				t.Error(err)
+			}
+		}(g)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := um.ChangePassword(u.GetId(), "new secret", false); err != nil {
+			t.Error(err)
+		}
+	}()
+	wg.Wait()
+
+	if u, err = um.ValidateUser("gopher@example.com", "new secret"); err != nil {
+		t.Fatalf("new password after concurrent updates: %v", err)
+	}
+	if n := len(u.GetBriefGroups()); n != len(groups) {
+		t.Errorf("joined %d groups, want %d", n, len(groups))
+	}
+	if n := len(um.locks); n != 0 {
+		t.Errorf("%d user locks left", n)
+	}
+}
@@ -0,0 +1,507 @@
+// Copyright 2012 The Toys Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package repomanager
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"github.com/kidstuff/toys/model"
+	"github.com/kidstuff/toys/secure"
+	"github.com/kidstuff/toys/secure/membership"
+	"github.com/kidstuff/toys/secure/membership/sessions"
+	"hash"
+	"sync"
+	"time"
+)
+
+const (
+	// ActivateCode is the confirm code key of the users waiting to be
+	// approved, a valid code approves the user.
+	ActivateCode = "activate"
+	// SessionKey is the session entry holding the id of the logged user.
+	SessionKey = "repomanager-user"
+	// RememberKey is the session entry holding the Unix time the login
+	// lasts until, if Login was given a remember time.
+	RememberKey = "repomanager-remember"
+)
+
+var (
+	// ErrNoSession is returned by the login methods of a UserManager
+	// without session.
+	ErrNoSession = errors.New("repomanager: no session")
+	// ErrNotLoggedIn is returned by GetUser if no user is logged in.
+	ErrNotLoggedIn = errors.New("repomanager: not logged in")
+)
+
+// UserManager implements membership.UserManager over a repository of User
+// records. The emails are unique among the users added by the UserManager.
+//
+// The session Provider sets its cookies itself, the path and the domain
+// given to the UserManager are only kept for the callers.
+//
+// The changes of a user are serialized by the UserManager, so a concurrent
+// GetUser does not undo a ChangePassword. The UserManagers sharing a
+// repository do not see each other's changes in progress.
+type UserManager struct {
+	driver    model.Driver
+	repo      model.Repository
+	sess      sessions.Provider
+	groups    membership.GroupManager
+	path      string
+	domain    string
+	threshold time.Duration
+	notif     membership.Notificater
+	checker   membership.FormatChecker
+	hash      hash.Hash
+	hashMux   sync.Mutex
+	addMux    sync.Mutex // serializes the email checks of AddUserDetail
+	locksMux  sync.Mutex
+	locks     map[string]*userLock
+}
+
+// userLock serializes the changes of a user, n counts its holders and
+// waiters.
+type userLock struct {
+	sync.Mutex
+	n int
+}
+
+var _ membership.UserManager = &UserManager{}
+
+// NewUserManager returns a UserManager storing the users in repo, opened
+// with NewUser, driver decodes the ids kept in the session sess. sess may be
+// nil if the UserManager does not log users in. The passwords are hashed
+// with SHA-256, the emails and passwords are not checked until a
+// FormatChecker is set.
+func NewUserManager(driver model.Driver, repo model.Repository, sess sessions.Provider) *UserManager {
+	return &UserManager{
+		driver: driver,
+		repo:   repo,
+		sess:   sess,
+		hash:   sha256.New(),
+	}
+}
+
+func (m *UserManager) SetPath(p string) {
+	m.path = p
+}
+
+func (m *UserManager) SetDomain(d string) {
+	m.domain = d
+}
+
+func (m *UserManager) SetGroupManager(g membership.GroupManager) {
+	m.groups = g
+}
+
+func (m *UserManager) GroupManager() membership.GroupManager {
+	return m.groups
+}
+
+func (m *UserManager) SetOnlineThreshold(t time.Duration) {
+	m.threshold = t
+}
+
+func (m *UserManager) SetHashFunc(h hash.Hash) {
+	m.hashMux.Lock()
+	m.hash = h
+	m.hashMux.Unlock()
+}
+
+func (m *UserManager) SetNotificater(n membership.Notificater) {
+	m.notif = n
+}
+
+func (m *UserManager) SetFormatChecker(c membership.FormatChecker) {
+	m.checker = c
+}
+
+func (m *UserManager) AddUser(email, pwd string, notif, app bool) (membership.User, error) {
+	return m.AddUserDetail(email, pwd, membership.UserInfo{}, nil, notif, app)
+}
+
+// AddUserDetail adds a user. A user not approved gets an ActivateCode
+// confirm code.
+func (m *UserManager) AddUserDetail(email, pwd string, info membership.UserInfo, pri map[string]bool, notif, app bool) (membership.User, error) {
+	if m.checker != nil {
+		if !m.checker.EmailValidate(email) {
+			return nil, membership.ErrInvalidEmail
+		}
+		if !m.checker.PasswordValidate(pwd) {
+			return nil, membership.ErrInvalidPassword
+		}
+	}
+
+	m.addMux.Lock()
+	defer m.addMux.Unlock()
+	if _, err := m.FindUserByEmail(email); err == nil {
+		return nil, membership.ErrDuplicateEmail
+	} else if err != model.ErrNotFound {
+		return nil, err
+	}
+
+	u := &User{}
+	u.Email = email
+	u.Pwd = m.GeneratePassword(pwd)
+	u.Info = info
+	if u.Info.JoinDay.IsZero() {
+		u.Info.JoinDay = time.Now()
+	}
+	u.Privilege = pri
+	u.Approved = app
+	if !app {
+		u.ConfirmCodes = map[string]string{ActivateCode: newCode()}
+	}
+	if err := m.repo.Insert(u); err != nil {
+		return nil, err
+	}
+	if notif && m.notif != nil {
+		if err := m.notif.AccountAdded(u); err != nil {
+			return u, err
+		}
+	}
+	return u, nil
+}
+
+func (m *UserManager) UpdateInfo(id model.Identifier, info membership.UserInfo, notif bool) error {
+	return m.update(id, func(u *User) {
+		u.Info = info
+	}, notif, m.notifier().AccountInfoChanged)
+}
+
+func (m *UserManager) UpdatePrivilege(id model.Identifier, pri map[string]bool, notif bool) error {
+	return m.update(id, func(u *User) {
+		u.Privilege = pri
+	}, notif, m.notifier().AccountPrivilegeChanged)
+}
+
+// ChangePassword changes the password of the user, the current one becomes
+// the old one.
+func (m *UserManager) ChangePassword(id model.Identifier, password string, notif bool) error {
+	if m.checker != nil && !m.checker.PasswordValidate(password) {
+		return membership.ErrInvalidPassword
+	}
+	return m.update(id, func(u *User) {
+		u.OldPwd = u.Pwd
+		u.Pwd = m.GeneratePassword(password)
+	}, notif, m.notifier().PasswordChanged)
+}
+
+// JoinGroup adds the group to the groups of the user, used by Can.
+func (m *UserManager) JoinGroup(id model.Identifier, g membership.Grouper) error {
+	return m.update(id, func(u *User) {
+		for _, bg := range u.BriefGroups {
+			if bg.Id != nil && bg.Id.Encode() == g.GetId().Encode() {
+				return
+			}
+		}
+		u.BriefGroups = append(u.BriefGroups, membership.BriefGroup{
+			Id:   g.GetId(),
+			Name: g.GetName(),
+		})
+	}, false, nil)
+}
+
+func (m *UserManager) DeleteUser(id model.Identifier) error {
+	return m.repo.Delete(id)
+}
+
+// GetUser returns the logged user and updates its LastActivity. The login
+// ends after the remember time given to Login or, without it, when the user
+// was not active during the online threshold.
+func (m *UserManager) GetUser() (membership.User, error) {
+	if m.sess == nil {
+		return nil, ErrNoSession
+	}
+	rep := m.sess.GetString(SessionKey)
+	if rep == "" {
+		return nil, ErrNotLoggedIn
+	}
+	id, err := m.driver.DecodeId(rep)
+	if err != nil {
+		return nil, err
+	}
+	defer m.lock(id)()
+	u := &User{}
+	if err = m.repo.Get(id, u); err != nil {
+		if err == model.ErrNotFound {
+			m.Logout()
+			return nil, ErrNotLoggedIn
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	until := int64(m.sess.GetInt(RememberKey))
+	if (until > 0 && now.Unix() > until) ||
+		(until <= 0 && m.threshold > 0 && now.Sub(u.LastActivity) > m.threshold) {
+		m.Logout()
+		return nil, ErrNotLoggedIn
+	}
+	u.LastActivity = now
+	if err = m.repo.Update(u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// FindUser returns the user with the id, or model.ErrNotFound.
+func (m *UserManager) FindUser(id model.Identifier) (membership.User, error) {
+	u := &User{}
+	if err := m.repo.Get(id, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// FindUserByEmail returns the user with the email, or model.ErrNotFound.
+func (m *UserManager) FindUserByEmail(email string) (membership.User, error) {
+	found, err := m.repo.Find(model.Query{
+		Filters: []model.Filter{{Field: "Email", Op: model.Eq, Value: email}},
+		Limit:   1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return nil, model.ErrNotFound
+	}
+	return found[0].(*User), nil
+}
+
+// FindAllUser returns limit users after the one with offsetId, in the order
+// of their ids. offsetId is nil for the first page.
+func (m *UserManager) FindAllUser(offsetId model.Identifier, limit int) ([]membership.User, error) {
+	return m.find(model.Query{OffsetId: offsetId, Limit: limit})
+}
+
+// FindAllUserOnline is like FindAllUser for the users active during the
+// online threshold, or ever active if there is no threshold.
+func (m *UserManager) FindAllUserOnline(offsetId model.Identifier, limit int) ([]membership.User, error) {
+	q := m.onlineQuery()
+	q.OffsetId = offsetId
+	q.Limit = limit
+	return m.find(q)
+}
+
+// CountUserOnline counts the users of FindAllUserOnline, it returns 0 if
+// they cannot be found.
+func (m *UserManager) CountUserOnline() int {
+	found, err := m.repo.Find(m.onlineQuery())
+	if err != nil {
+		return 0
+	}
+	return len(found)
+}
+
+// ValidateUser returns the user with the email and password. It returns
+// membership.ErrInvalidEmail for an unknown email and
+// membership.ErrInvalidPassword for a wrong password.
+func (m *UserManager) ValidateUser(email string, password string) (membership.User, error) {
+	u, err := m.FindUserByEmail(email)
+	if err == model.ErrNotFound {
+		return nil, membership.ErrInvalidEmail
+	}
+	if err != nil {
+		return nil, err
+	}
+	pwd := u.GetPassword()
+	if subtle.ConstantTimeCompare(m.hashPassword(pwd.Salt, password), pwd.Hashed) != 1 {
+		return nil, membership.ErrInvalidPassword
+	}
+	return u, nil
+}
+
+// Login logs the user in, for remember seconds if remember > 0.
+func (m *UserManager) Login(id model.Identifier, remember int) error {
+	if m.sess == nil {
+		return ErrNoSession
+	}
+	if id == nil || !id.Valid() {
+		return membership.ErrInvalidId
+	}
+	now := time.Now()
+	if err := m.update(id, func(u *User) {
+		u.LastActivity = now
+	}, false, nil); err != nil {
+		return err
+	}
+	if err := m.sess.Set(SessionKey, id.Encode()); err != nil {
+		return err
+	}
+	if remember > 0 {
+		return m.sess.Set(RememberKey, int(now.Unix())+remember)
+	}
+	return m.sess.Delete(RememberKey)
+}
+
+func (m *UserManager) Logout() error {
+	if m.sess == nil {
+		return ErrNoSession
+	}
+	return m.sess.Delete(SessionKey, RememberKey)
+}
+
+// ValidConfirmCode reports whether code is the confirm code of the user for
+// key. A valid ActivateCode approves the user.
+func (m *UserManager) ValidConfirmCode(id model.Identifier, key, code string, regen, del bool) (bool, error) {
+	defer m.lock(id)()
+	u := &User{}
+	if err := m.repo.Get(id, u); err != nil {
+		return false, err
+	}
+	want, ok := u.ConfirmCodes[key]
+	if !ok || subtle.ConstantTimeCompare([]byte(want), []byte(code)) != 1 {
+		return false, nil
+	}
+	switch {
+	case regen:
+		u.ConfirmCodes[key] = newCode()
+	case del:
+		delete(u.ConfirmCodes, key)
+	}
+	if key == ActivateCode {
+		u.Approved = true
+	}
+	return true, m.repo.Update(u)
+}
+
+// GeneratePassword hashes the password with a new salt, an empty password
+// is replaced by a random one.
+func (m *UserManager) GeneratePassword(password string) membership.Password {
+	if password == "" {
+		password = secure.RandomString(16)
+	}
+	salt := secure.RandomToken(32)
+	return membership.Password{
+		Hashed: m.hashPassword(salt, password),
+		Salt:   salt,
+		InitAt: time.Now(),
+	}
+}
+
+// Can reports whether the user has the privilege, its own privilege comes
+// before the ones of its groups.
+func (m *UserManager) Can(user membership.User, do string) bool {
+	if user == nil {
+		return false
+	}
+	if can, ok := user.GetPrivilege()[do]; ok {
+		return can
+	}
+	if m.groups == nil {
+		return false
+	}
+	for _, bg := range user.GetBriefGroups() {
+		g, err := m.groups.FindGroup(bg.Id)
+		if err == nil && g.GetPrivilege()[do] {
+			return true
+		}
+	}
+	return false
+}
+
+// update changes the user with the id by fn, then sends the notification
+// send if notif is true.
+func (m *UserManager) update(id model.Identifier, fn func(u *User), notif bool, send func(membership.User) error) error {
+	unlock := m.lock(id)
+	u := &User{}
+	if err := m.repo.Get(id, u); err != nil {
+		unlock()
+		return err
+	}
+	fn(u)
+	err := m.repo.Update(u)
+	unlock()
+	if err != nil {
+		return err
+	}
+	if notif && m.notif != nil {
+		return send(u)
+	}
+	return nil
+}
+
+// lock locks the changes of the user with the id and returns the func
+// unlocking them.
+func (m *UserManager) lock(id model.Identifier) func() {
+	if id == nil || !id.Valid() {
+		return func() {}
+	}
+	key := id.Encode()
+	m.locksMux.Lock()
+	l, ok := m.locks[key]
+	if !ok {
+		if m.locks == nil {
+			m.locks = make(map[string]*userLock)
+		}
+		l = &userLock{}
+		m.locks[key] = l
+	}
+	l.n++
+	m.locksMux.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		m.locksMux.Lock()
+		if l.n--; l.n == 0 {
+			delete(m.locks, key)
+		}
+		m.locksMux.Unlock()
+	}
+}
+
+// notifier returns the Notificater, or one doing nothing.
+func (m *UserManager) notifier() membership.Notificater {
+	if m.notif == nil {
+		return noNotificater{}
+	}
+	return m.notif
+}
+
+func (m *UserManager) find(q model.Query) ([]membership.User, error) {
+	found, err := m.repo.Find(q)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]membership.User, len(found))
+	for i, rec := range found {
+		users[i] = rec.(*User)
+	}
+	return users, nil
+}
+
+func (m *UserManager) onlineQuery() model.Query {
+	since := time.Time{}
+	if m.threshold > 0 {
+		since = time.Now().Add(-m.threshold)
+	}
+	return model.Query{Filters: []model.Filter{
+		{Field: "LastActivity", Op: model.Gt, Value: since},
+	}}
+}
+
+func (m *UserManager) hashPassword(salt []byte, password string) []byte {
+	m.hashMux.Lock()
+	defer m.hashMux.Unlock()
+	m.hash.Reset()
+	m.hash.Write(salt)
+	m.hash.Write([]byte(password))
+	return m.hash.Sum(nil)
+}
+
+func newCode() string {
+	return base64.URLEncoding.EncodeToString(secure.RandomToken(24))
+}
+
+type noNotificater struct{}
+
+func (noNotificater) AccountAdded(membership.User) error            { return nil }
+func (noNotificater) PasswordChanged(membership.User) error         { return nil }
+func (noNotificater) AccountInfoChanged(membership.User) error      { return nil }
+func (noNotificater) AccountPrivilegeChanged(membership.User) error { return nil }
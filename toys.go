@@ -6,6 +6,7 @@ package toys
 
 import (
 	"fmt"
+	"github.com/kidstuff/toys/locale"
 	"html/template"
 	"net/http"
 	"net/url"
@@ -28,6 +29,7 @@ type Context struct {
 	http.ResponseWriter
 	inf  map[InfoKey]string
 	path string
+	lc   *locale.Localizer
 }
 
 // Init initial the Context given a http.ResponseWriter and *http.Request. You must call Init
@@ -41,6 +43,19 @@ func (c *Context) Init(w http.ResponseWriter, r *http.Request) {
 	c.inf[RequestPath] = r.URL.Path
 	c.inf[RequestQuery] = r.URL.RawQuery
 	c.inf[RemoteAddress] = r.RemoteAddr
+	c.lc = nil
+}
+
+// SetLocalizer sets the Localizer of the request, usually the one returned by
+// locale.Negotiator.Localizer.
+func (c *Context) SetLocalizer(lc *locale.Localizer) {
+	c.lc = lc
+}
+
+// Localizer returns the Localizer of the request, nil if none was set. Pass it
+// to view.View.LoadLocalized to render in the language of the request.
+func (c *Context) Localizer() *locale.Localizer {
+	return c.lc
 }
 
 // Redirect send the redirect header with the url destination and the status code.
@@ -34,6 +34,9 @@ There is some rules for "xyz.tmpl" files:
 The "layout.tmpl" in shared folder is the main layout. The content of "xyz.tmpl" files should be
 embedded in this file. You must put {{template "page" .}} some where in this file.
 
+NewViewFS reads the view-sets from an fs.FS instead, like an embed.FS, with
+the same layout. Watch must be false for such a View.
+
 For more detail, see the tutorial.
 */
 package view
@@ -46,23 +49,29 @@ import (
 	"github.com/kidstuff/toys/locale"
 	"html/template"
 	"io"
-	"io/ioutil"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"reflect"
 	"sync"
+	"time"
 )
 
 // View manages the whole template system.
 type View struct {
-	root           string
+	root           string // empty if the source is not a folder
+	fsys           fs.FS
 	set            map[string]map[string]*template.Template
 	current        string
 	funcsMap       template.FuncMap
+	local          map[string]map[string]*template.Template
 	ResourcePrefix string
-	Watch          bool
-	watcher        *fsnotify.Watcher
-	mux            struct {
+	// Watch parses a view-set again when its files change, it is only
+	// supported by a View created by NewView.
+	Watch   bool
+	watcher *fsnotify.Watcher
+	mux     struct {
 		set     sync.RWMutex
 		current sync.RWMutex
 		watcher sync.Mutex
@@ -71,9 +80,19 @@ type View struct {
 
 // NewView returns a new View with the given location of the template folder.
 func NewView(root string) *View {
-	v := &View{}
+	v := NewViewFS(os.DirFS(root))
 	v.root = root
+	return v
+}
+
+// NewViewFS returns a new View reading the view-sets from fsys, like an
+// embed.FS, with the same layout as the template folder. Such a View cannot
+// Watch its source.
+func NewViewFS(fsys fs.FS) *View {
+	v := &View{}
+	v.fsys = fsys
 	v.set = make(map[string]map[string]*template.Template)
+	v.local = make(map[string]map[string]*template.Template)
 	v.funcsMap = template.FuncMap{}
 	v.funcsMap["resource"] = func(uri string) string {
 		return v.ResourcePrefix + uri
@@ -138,19 +157,18 @@ func (v *View) SetDefault(set string) error {
 // Parse parses the view-set you want to use. You may call Parse for all view-set you have and then
 // switching beetwen them by call SetDefault.
 func (v *View) Parse(set string) error {
-	setFolder := filepath.Join(v.root, set)
+	if v.Watch && v.root == "" {
+		return errors.New("view: the template source cannot be watched")
+	}
+	if !fs.ValidPath(set) || set == "." {
+		return errors.New("view: invalid view-set name")
+	}
 
 	tmpl := template.Must(template.New("layout.tmpl").Funcs(v.funcsMap).
-		ParseGlob(filepath.Join(setFolder, "shared", "*.tmpl")))
+		ParseFS(v.fsys, path.Join(set, "shared", "*.tmpl")))
 	vs := make(map[string]*template.Template)
 	//parse page
-	setroot, err := os.Open(setFolder)
-	if err != nil {
-		return err
-	}
-	defer setroot.Close()
-
-	files, err := setroot.Readdir(-1)
+	files, err := fs.ReadDir(v.fsys, set)
 	if err != nil {
 		return err
 	}
@@ -161,7 +179,7 @@ func (v *View) Parse(set string) error {
 				continue
 			}
 			//read file
-			b, err := ioutil.ReadFile(filepath.Join(setFolder, file.Name()))
+			b, err := fs.ReadFile(v.fsys, path.Join(set, file.Name()))
 			if err != nil {
 				continue
 			}
@@ -180,9 +198,11 @@ func (v *View) Parse(set string) error {
 
 	v.mux.set.Lock()
 	v.set[set] = vs
+	delete(v.local, set)
 	v.mux.set.Unlock()
 
 	if v.Watch {
+		setFolder := filepath.Join(v.root, set)
 		v.mux.watcher.Lock()
 		if v.watcher != nil {
 			v.watcher.Close()
@@ -211,30 +231,180 @@ func (v *View) Parse(set string) error {
 
 // Load render the template you specific with name and write it to the Writer.W
 func (v *View) Load(w io.Writer, pageName string, data interface{}) error {
+	return v.LoadLocalized(w, pageName, data, nil)
+}
+
+// LoadLocalized likes Load but the lang, langn and langf commands of the
+// template translate with lc, so each request can be rendered in its own
+// language. A nil lc uses the Lang given to SetLang.
+func (v *View) LoadLocalized(w io.Writer, pageName string, data interface{}, lc *locale.Localizer) error {
 	v.mux.current.RLock()
 	setName := v.current
 	v.mux.current.RUnlock()
 
+	p, err := v.page(setName, pageName, lc)
+	if err != nil {
+		fmt.Fprintf(w, "%#v", data)
+		return err
+	}
+	return p.ExecuteTemplate(w, "layout.tmpl", data)
+}
+
+// page returns the template to execute for the page. The parsed templates
+// are never executed so they can be cloned with the funcs of a Localizer,
+// the clones are cached by lang-set.
+func (v *View) page(setName, pageName string, lc *locale.Localizer) (*template.Template, error) {
+	key := pageName
+	if lc != nil {
+		key += "\x00" + lc.Set()
+	}
+
 	v.mux.set.RLock()
-	p, ok := v.set[setName][pageName]
+	p, ok := v.local[setName][key]
+	master, found := v.set[setName][pageName]
 	v.mux.set.RUnlock()
-
 	if ok {
-		return p.ExecuteTemplate(w, "layout.tmpl", data)
+		return p, nil
+	}
+	if !found {
+		return nil, errors.New("view: cannot load template")
+	}
+
+	p, err := master.Clone()
+	if err != nil {
+		return nil, err
+	}
+	if lc != nil {
+		p.Funcs(localizerFuncs(lc))
+	}
+
+	v.mux.set.Lock()
+	defer v.mux.set.Unlock()
+	if v.set[setName][pageName] != master {
+		// parsed again meanwhile, do not cache the old page
+		return p, nil
+	}
+	if v.local[setName] == nil {
+		v.local[setName] = make(map[string]*template.Template)
+	}
+	v.local[setName][key] = p
+	return p, nil
+}
+
+func localizerFuncs(lc *locale.Localizer) template.FuncMap {
+	funcs := formatFuncs(lc)
+	funcs["lang"] = lc.Load
+	funcs["langn"] = lc.Plural
+	funcs["langf"] = func(file, key string, args ...interface{}) (string, error) {
+		a, err := formatArgs(args)
+		if err != nil {
+			return "", err
+		}
+		return lc.Format(file, key, a)
+	}
+	return funcs
+}
+
+// formatter is implemented by locale.Lang and locale.Localizer.
+type formatter interface {
+	Number(n interface{}, frac int) string
+	Currency(amount interface{}, code string) string
+	Date(t time.Time, style locale.DateStyle) string
+	Time(t time.Time, style locale.DateStyle) string
+}
+
+// formatFuncs returns the number, currency, date and time funcs of f. The
+// number of fraction digits and the style are optional.
+func formatFuncs(f formatter) template.FuncMap {
+	style := func(s []string) locale.DateStyle {
+		if len(s) == 0 {
+			return locale.DateMedium
+		}
+		return locale.ParseDateStyle(s[0])
+	}
+	return template.FuncMap{
+		"number": func(n interface{}, frac ...int) string {
+			if len(frac) == 0 {
+				return f.Number(n, -1)
+			}
+			return f.Number(n, frac[0])
+		},
+		"currency": f.Currency,
+		"date": func(t time.Time, s ...string) string {
+			return f.Date(t, style(s))
+		},
+		"time": func(t time.Time, s ...string) string {
+			return f.Time(t, style(s))
+		},
 	}
-	fmt.Fprintf(w, "%#v", data)
-	return errors.New("view: cannot load template")
 }
 
 // SetLang set the language use with the current template system. The method must be call before Parse.
+// Use LoadLocalized to render a page in the language of a request.
 // After call this method you can use these command in your .tmpl files:
 // 	{{lang "filename.lang" "key"}}
 // 	{{langset "set" "filename.lang" "key"}}
+// 	{{langn "filename.lang" "key" .Count}}
+// 	{{langsetn "set" "filename.lang" "key" .Count}}
+// 	{{langf "filename.lang" "key" .}}
+// 	{{langf "filename.lang" "key" "name" .User.Name "count" 3}}
+// 	{{langsetf "set" "filename.lang" "key" .}}
+// 	{{number .Total 2}}
+// 	{{currency .Total "VND"}}
+// 	{{date .Created "long"}}
+// 	{{time .Created "short"}}
+// langf and langsetf fill the placeholders of the value, see locale.Format.
+// They take a single map or struct, or pairs of names and values. number,
+// currency, date and time format in the conventions of the language, see
+// locale.Conventions, the fraction digits and the style are optional.
 func (v *View) SetLang(l *locale.Lang) {
+	for name, f := range formatFuncs(l) {
+		v.funcsMap[name] = f
+	}
 	v.funcsMap["lang"] = func(file, key string) string {
 		return l.Load(file, key)
 	}
 	v.funcsMap["langset"] = func(set, file, key string) string {
 		return l.LoadSet(set, file, key)
 	}
+	v.funcsMap["langn"] = func(file, key string, n interface{}) string {
+		return l.Plural(file, key, n)
+	}
+	v.funcsMap["langsetn"] = func(set, file, key string, n interface{}) string {
+		return l.PluralSet(set, file, key, n)
+	}
+	v.funcsMap["langf"] = func(file, key string, args ...interface{}) (string, error) {
+		a, err := formatArgs(args)
+		if err != nil {
+			return "", err
+		}
+		return l.Format(file, key, a)
+	}
+	v.funcsMap["langsetf"] = func(set, file, key string, args ...interface{}) (string, error) {
+		a, err := formatArgs(args)
+		if err != nil {
+			return "", err
+		}
+		return l.FormatSet(set, file, key, a)
+	}
+}
+
+// formatArgs returns the single argument, or a map of the name and value
+// pairs.
+func formatArgs(args []interface{}) (interface{}, error) {
+	if len(args) == 1 {
+		return args[0], nil
+	}
+	if len(args)%2 != 0 {
+		return nil, errors.New("view: langf requires a single argument or name and value pairs")
+	}
+	m := make(map[string]interface{}, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		name, ok := args[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("view: langf argument name must be a string, got %T", args[i])
+		}
+		m[name] = args[i+1]
+	}
+	return m, nil
 }
@@ -0,0 +1,176 @@
+package view
+
+import (
+	"bytes"
+	"github.com/kidstuff/toys/locale"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadLocalized(t *testing.T) {
+	root, err := ioutil.TempDir("", "view")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	writeFiles(t, root, map[string]string{
+		"lang/en/index.lang":              "hi=Hello {name}\nitems.one=item\nitems.other=items\n",
+		"lang/vi/index.lang":              "hi=Xin chào {name}\nitems=mục\n",
+		"tmpl/default/shared/layout.tmpl": `{{template "page" .}}`,
+		"tmpl/default/index.tmpl":         `{{define "page"}}{{langf "index.lang" "hi" .}} {{.N}} {{langn "index.lang" "items" .N}}{{end}}`,
+	})
+
+	l := locale.NewLang(filepath.Join(root, "lang"))
+	if err = l.Parse("vi"); err != nil {
+		t.Fatal(err)
+	}
+	if err = l.SetDefault("en"); err != nil {
+		t.Fatal(err)
+	}
+	v := NewView(filepath.Join(root, "tmpl"))
+	v.SetLang(l)
+	if err = v.Parse("default"); err != nil {
+		t.Fatal(err)
+	}
+
+	data := map[string]interface{}{"name": "Gopher", "N": 1}
+	want := map[string]string{
+		"":   "Hello Gopher 1 item",
+		"en": "Hello Gopher 1 item",
+		"vi": "Xin chào Gopher 1 mục",
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		for set, text := range want {
+			wg.Add(1)
+			go func(set, text string) {
+				defer wg.Done()
+				var lc *locale.Localizer
+				if set != "" {
+					lc = l.Localizer(set)
+				}
+				var buf bytes.Buffer
+				if err := v.LoadLocalized(&buf, "index.tmpl", data, lc); err != nil {
+					t.Error(err)
+				} else if buf.String() != text {
+					t.Errorf("%q: got %q, want %q", set, buf.String(), text)
+				}
+			}(set, text)
+		}
+	}
+	wg.Wait()
+}
+
+func TestNewViewFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"lang/en/index.lang":              {Data: []byte("hi=Hello\n")},
+		"tmpl/default/shared/layout.tmpl": {Data: []byte(`<p>{{template "page" .}}</p>`)},
+		"tmpl/default/index.tmpl":         {Data: []byte(`{{define "page"}}{{lang "index.lang" "hi"}} {{.}}{{end}}`)},
+	}
+	sub, err := fs.Sub(fsys, "lang")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := locale.NewLangFS(sub)
+	if err = l.SetDefault("en"); err != nil {
+		t.Fatal(err)
+	}
+	sub, err = fs.Sub(fsys, "tmpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := NewViewFS(sub)
+	v.SetLang(l)
+	if err = v.SetDefault("default"); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = v.Load(&buf, "index.tmpl", "Gopher"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "<p>Hello Gopher</p>"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	v.Watch = true
+	if err = v.Parse("default"); err == nil {
+		t.Error("want error watching an fs.FS")
+	}
+}
+
+func TestFormatFuncs(t *testing.T) {
+	fsys := fstest.MapFS{
+		"lang/en/index.lang":              {Data: []byte("hi=Hello\n")},
+		"lang/vi/index.lang":              {Data: []byte("hi=Xin chào\n")},
+		"tmpl/default/shared/layout.tmpl": {Data: []byte(`{{template "page" .}}`)},
+		"tmpl/default/index.tmpl": {Data: []byte(`{{define "page"}}` +
+			`{{number .N}}|{{number .N 2}}|{{currency .N "USD"}}|{{currency .N "VND"}}|` +
+			`{{date .T}}|{{date .T "short"}}|{{date .T "full"}}|{{date .T "bogus"}}|` +
+			`{{time .T}}|{{time .T "short"}}{{end}}`)},
+	}
+	sub, err := fs.Sub(fsys, "lang")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := locale.NewLangFS(sub)
+	if err = l.Parse("vi"); err != nil {
+		t.Fatal(err)
+	}
+	if err = l.SetDefault("en"); err != nil {
+		t.Fatal(err)
+	}
+	sub, err = fs.Sub(fsys, "tmpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := NewViewFS(sub)
+	v.SetLang(l)
+	if err = v.SetDefault("default"); err != nil {
+		t.Fatal(err)
+	}
+
+	data := map[string]interface{}{
+		"N": 1234.5,
+		"T": time.Date(2013, 3, 7, 15, 4, 5, 0, time.UTC),
+	}
+	tests := []struct {
+		set, want string
+	}{
+		{"", "1,234.5|1,234.50|$1,234.50|₫1,234|" +
+			"Mar 7, 2013|3/7/13|Thursday, March 7, 2013|Mar 7, 2013|" +
+			"3:04:05 PM|3:04 PM"},
+		{"vi", "1.234,5|1.234,50|1.234,50\u00a0$|1.234\u00a0₫|" +
+			"7 thg 3, 2013|07/03/2013|Thứ Năm, 7 tháng 3, 2013|7 thg 3, 2013|" +
+			"15:04:05|15:04"},
+	}
+	for _, test := range tests {
+		var lc *locale.Localizer
+		if test.set != "" {
+			lc = l.Localizer(test.set)
+		}
+		var buf bytes.Buffer
+		if err = v.LoadLocalized(&buf, "index.tmpl", data, lc); err != nil {
+			t.Errorf("%q: %v", test.set, err)
+		} else if buf.String() != test.want {
+			t.Errorf("%q: got %q\nwant %q", test.set, buf.String(), test.want)
+		}
+	}
+}